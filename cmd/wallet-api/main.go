@@ -10,10 +10,14 @@ import (
 	"syscall"
 	"time"
 	"wallet-service/internal/config"
+	"wallet-service/internal/exchange"
 	"wallet-service/internal/handler"
+	"wallet-service/internal/publisher"
 	"wallet-service/internal/repository/postgres"
 	"wallet-service/internal/router"
 	"wallet-service/internal/service"
+	"wallet-service/internal/webhook"
+	"wallet-service/internal/worker"
 )
 
 func main() {
@@ -36,12 +40,46 @@ func main() {
 	}
 	defer db.Close()
 
+	serviceOpts := []service.Option{
+		service.WithHoldTTL(cfg.HoldTTL),
+		service.WithMaxBatchItems(cfg.BatchMaxItems),
+	}
+	if cfg.UniqueWalletPerOwner {
+		serviceOpts = append(serviceOpts, service.WithUniqueWalletPerOwner())
+	}
+	if cfg.RatesFile != "" {
+		rates, err := exchange.LoadFile(cfg.RatesFile)
+		if err != nil {
+			slog.Error("failed to load exchange rates", "error", err)
+			os.Exit(1)
+		}
+		serviceOpts = append(serviceOpts, service.WithRateProvider(rates))
+	}
+
 	repository := postgres.NewRepository(db, logger)
-	service := service.NewService(repository, logger)
+	service := service.NewService(repository, logger, serviceOpts...)
 	handler := handler.NewHandler(service, logger)
 
 	app := router.SetupRouter(*handler)
 
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go worker.NewHoldSweeper(repository, cfg.HoldSweepInterval, logger).Run(workerCtx)
+	go worker.NewWebhookDispatcher(repository, webhook.NewSender(cfg.WebhookTimeout), cfg.WebhookInterval, logger).Run(workerCtx)
+	go worker.NewScheduleRunner(repository, service, cfg.ScheduleInterval, logger).Run(workerCtx)
+
+	if cfg.ReconcileInterval > 0 {
+		go worker.NewReconciliationJob(service, cfg.ReconcileInterval, cfg.ReconcileFix, logger).Run(workerCtx)
+	}
+
+	events, err := publisher.NewFilePublisher(cfg.EventsFile)
+	if err != nil {
+		slog.Error("failed to open events file", "error", err)
+		os.Exit(1)
+	}
+	defer events.Close()
+	go worker.NewOutboxRelay(repository, events, cfg.OutboxRelayInterval, logger).Run(workerCtx)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
@@ -55,6 +93,7 @@ func main() {
 	slog.Info(fmt.Sprintf("Server started on port %s", cfg.Port))
 	<-quit
 	slog.Info("Shutting down server...")
+	stopWorkers()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,64 @@
+// Command wallet-reconcile compares every wallet's stored balance with the sum
+// of its operations once, records the run and prints it as JSON. It exits
+// with status 2 when a mismatch is left unfixed, so it can alert from cron.
+// Balances are only overwritten with -fix.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"wallet-service/internal/config"
+	"wallet-service/internal/repository/postgres"
+	"wallet-service/internal/service"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run does the work of main and returns the exit status, so that deferred
+// cleanup happens before the process exits.
+func run() int {
+	fix := flag.Bool("fix", false, "overwrite mismatched balances with the ledger value")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+	slog.SetDefault(logger)
+
+	cfg, err := config.NewConfig()
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		return 1
+	}
+
+	db, err := postgres.NewDB(cfg.DBConnStr)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		return 1
+	}
+	defer db.Close()
+
+	service := service.NewService(postgres.NewRepository(db, logger), logger)
+
+	report, err := service.Reconcile(context.Background(), *fix)
+	if err != nil {
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		slog.Error("failed to write report", "error", err)
+	}
+
+	for _, m := range report.Mismatches {
+		if !m.Fixed {
+			return 2
+		}
+	}
+	return 0
+}
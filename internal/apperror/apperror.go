@@ -0,0 +1,109 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code is a stable, machine-readable identifier of an error returned to API clients.
+type Code string
+
+const (
+	CodeInvalidRequest      Code = "INVALID_REQUEST"
+	CodeValidation          Code = "VALIDATION_FAILED"
+	CodeWalletNotFound      Code = "WALLET_NOT_FOUND"
+	CodeHoldNotFound        Code = "HOLD_NOT_FOUND"
+	CodeOperationNotFound   Code = "OPERATION_NOT_FOUND"
+	CodeHoldNotActive       Code = "HOLD_NOT_ACTIVE"
+	CodeInsufficientFunds   Code = "INSUFFICIENT_FUNDS"
+	CodeCurrencyMismatch    Code = "CURRENCY_MISMATCH"
+	CodeRefundExceeded      Code = "REFUND_EXCEEDS_ORIGINAL"
+	CodeLimitPolicyNotFound Code = "LIMIT_POLICY_NOT_FOUND"
+	CodeWebhookNotFound     Code = "WEBHOOK_NOT_FOUND"
+	CodeDeliveryNotFound    Code = "WEBHOOK_DELIVERY_NOT_FOUND"
+	CodeRunNotFound         Code = "RECONCILIATION_RUN_NOT_FOUND"
+	CodeFeeRuleNotFound     Code = "FEE_RULE_NOT_FOUND"
+	CodeScheduleNotFound    Code = "SCHEDULE_NOT_FOUND"
+	CodeScheduleNotActive   Code = "SCHEDULE_NOT_ACTIVE"
+	CodeRateUnavailable     Code = "RATE_UNAVAILABLE"
+	CodeIdempotencyConflict Code = "IDEMPOTENCY_KEY_CONFLICT"
+	CodeVersionMismatch     Code = "WALLET_VERSION_MISMATCH"
+	CodeWalletExists        Code = "WALLET_ALREADY_EXISTS"
+	CodeWalletFrozen        Code = "WALLET_FROZEN"
+	CodeWalletClosed        Code = "WALLET_CLOSED"
+	CodeInvalidTransition   Code = "INVALID_STATUS_TRANSITION"
+	CodeBalanceNotZero      Code = "WALLET_BALANCE_NOT_ZERO"
+	CodeCreditLimitInUse    Code = "CREDIT_LIMIT_IN_USE"
+	CodeBatchAborted        Code = "BATCH_ABORTED"
+	CodeImportRejected      Code = "IMPORT_REJECTED"
+	CodeInternal            Code = "INTERNAL_ERROR"
+)
+
+// Limit codes name the limit of the wallet's LimitPolicy that an operation would break.
+const (
+	CodeMaxBalanceExceeded        Code = "MAX_BALANCE_EXCEEDED"
+	CodeSingleAmountExceeded      Code = "SINGLE_AMOUNT_LIMIT_EXCEEDED"
+	CodeDailyDepositExceeded      Code = "DAILY_DEPOSIT_LIMIT_EXCEEDED"
+	CodeDailyWithdrawalExceeded   Code = "DAILY_WITHDRAWAL_LIMIT_EXCEEDED"
+	CodeMonthlyDepositExceeded    Code = "MONTHLY_DEPOSIT_LIMIT_EXCEEDED"
+	CodeMonthlyWithdrawalExceeded Code = "MONTHLY_WITHDRAWAL_LIMIT_EXCEEDED"
+)
+
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func Errorf(code Code, format string, args ...any) *Error {
+	return New(code, fmt.Sprintf(format, args...))
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches any *Error with the same code, so errors.Is(err, ErrValidation)
+// holds for every validation error regardless of its message.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrInvalidRequest         = New(CodeInvalidRequest, "invalid request format")
+	ErrValidation             = New(CodeValidation, "validation failed")
+	ErrWalletNotFound         = New(CodeWalletNotFound, "wallet not found")
+	ErrHoldNotFound           = New(CodeHoldNotFound, "hold not found")
+	ErrOperationNotFound      = New(CodeOperationNotFound, "operation not found")
+	ErrHoldNotActive          = New(CodeHoldNotActive, "hold is no longer active")
+	ErrInsufficientFunds      = New(CodeInsufficientFunds, "insufficient funds")
+	ErrCurrencyMismatch       = New(CodeCurrencyMismatch, "operation currency does not match wallet currency")
+	ErrRefundExceeded         = New(CodeRefundExceeded, "refund exceeds the amount left on the original operation")
+	ErrLimitPolicyNotFound    = New(CodeLimitPolicyNotFound, "limit policy not found")
+	ErrWebhookNotFound        = New(CodeWebhookNotFound, "webhook subscription not found")
+	ErrDeliveryNotFound       = New(CodeDeliveryNotFound, "webhook delivery not found")
+	ErrRunNotFound            = New(CodeRunNotFound, "reconciliation run not found")
+	ErrFeeRuleNotFound        = New(CodeFeeRuleNotFound, "fee rule not found")
+	ErrScheduleNotFound       = New(CodeScheduleNotFound, "schedule not found")
+	ErrScheduleNotActive      = New(CodeScheduleNotActive, "schedule is no longer active")
+	ErrRateUnavailable        = New(CodeRateUnavailable, "exchange rate is unavailable")
+	ErrIdempotencyKeyConflict = New(CodeIdempotencyConflict, "idempotency key was already used with a different request")
+	ErrVersionMismatch        = New(CodeVersionMismatch, "wallet has changed since the version in If-Match")
+	ErrWalletExists           = New(CodeWalletExists, "owner already has a wallet in this currency")
+	ErrWalletFrozen           = New(CodeWalletFrozen, "wallet is frozen")
+	ErrWalletClosed           = New(CodeWalletClosed, "wallet is closed")
+	ErrBalanceNotZero         = New(CodeBalanceNotZero, "wallet can only be closed at zero balance")
+)
+
+// CodeOf returns the code of the first *Error in err's chain, or CodeInternal.
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
@@ -0,0 +1,23 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIs(t *testing.T) {
+	err := fmt.Errorf("get balance: %w", Errorf(CodeValidation, "amount must be positive"))
+
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.NotErrorIs(t, err, ErrWalletNotFound)
+	assert.Equal(t, "get balance: amount must be positive", err.Error())
+}
+
+func TestCodeOf(t *testing.T) {
+	assert.Equal(t, CodeWalletNotFound, CodeOf(fmt.Errorf("wrapped: %w", ErrWalletNotFound)))
+	assert.Equal(t, CodeInternal, CodeOf(errors.New("boom")))
+	assert.Equal(t, CodeInternal, CodeOf(nil))
+}
@@ -4,15 +4,49 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DBConnStr string
-	Port      string
+	DBConnStr         string
+	Port              string
+	RatesFile         string
+	HoldTTL           time.Duration
+	HoldSweepInterval time.Duration
+	// UniqueWalletPerOwner limits every owner to one open wallet per currency.
+	UniqueWalletPerOwner bool
+	// EventsFile is where the outbox relay appends published events.
+	EventsFile          string
+	OutboxRelayInterval time.Duration
+	// WebhookInterval is how often queued webhooks are sent; WebhookTimeout
+	// bounds a single request to a receiver.
+	WebhookInterval time.Duration
+	WebhookTimeout  time.Duration
+	// BatchMaxItems is the largest batch POST api/v1/wallet/batch accepts.
+	BatchMaxItems int
+	// ReconcileInterval is how often stored balances are compared with the
+	// ledger; zero disables the periodic job. ReconcileFix lets the job
+	// overwrite mismatched balances instead of only reporting them.
+	ReconcileInterval time.Duration
+	ReconcileFix      bool
+	// ScheduleInterval is how often due scheduled operations are run.
+	ScheduleInterval time.Duration
 }
 
+const (
+	defaultHoldTTL           = 15 * time.Minute
+	defaultHoldSweepInterval = time.Minute
+	defaultOutboxInterval    = time.Second
+	defaultEventsFile        = "events.jsonl"
+	defaultWebhookInterval   = 5 * time.Second
+	defaultWebhookTimeout    = 10 * time.Second
+	defaultBatchMaxItems     = 1000
+	defaultScheduleInterval  = 30 * time.Second
+)
+
 func NewConfig() (*Config, error) {
 	err := godotenv.Load("config.env")
 	if err != nil {
@@ -39,8 +73,115 @@ func NewConfig() (*Config, error) {
 	}
 	port = ":" + port
 
+	holdTTL, err := durationEnv("HOLD_TTL", defaultHoldTTL)
+	if err != nil {
+		return nil, err
+	}
+	holdSweepInterval, err := durationEnv("HOLD_SWEEP_INTERVAL", defaultHoldSweepInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	uniqueWalletPerOwner, err := boolEnv("WALLET_UNIQUE_PER_OWNER")
+	if err != nil {
+		return nil, err
+	}
+
+	outboxRelayInterval, err := durationEnv("OUTBOX_RELAY_INTERVAL", defaultOutboxInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	webhookInterval, err := durationEnv("WEBHOOK_DISPATCH_INTERVAL", defaultWebhookInterval)
+	if err != nil {
+		return nil, err
+	}
+	webhookTimeout, err := durationEnv("WEBHOOK_TIMEOUT", defaultWebhookTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	batchMaxItems, err := intEnv("BATCH_MAX_ITEMS", defaultBatchMaxItems)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unlike the other jobs, reconciliation is off unless asked for.
+	reconcileInterval, err := durationEnv("RECONCILE_INTERVAL", 0)
+	if err != nil {
+		return nil, err
+	}
+	reconcileFix, err := boolEnv("RECONCILE_FIX")
+	if err != nil {
+		return nil, err
+	}
+
+	scheduleInterval, err := durationEnv("SCHEDULE_INTERVAL", defaultScheduleInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	eventsFile := os.Getenv("EVENTS_FILE")
+	if eventsFile == "" {
+		eventsFile = defaultEventsFile
+	}
+
 	return &Config{
-		DBConnStr: connStr,
-		Port:      port,
+		DBConnStr:            connStr,
+		Port:                 port,
+		RatesFile:            os.Getenv("RATES_FILE"),
+		HoldTTL:              holdTTL,
+		HoldSweepInterval:    holdSweepInterval,
+		UniqueWalletPerOwner: uniqueWalletPerOwner,
+		EventsFile:           eventsFile,
+		OutboxRelayInterval:  outboxRelayInterval,
+		WebhookInterval:      webhookInterval,
+		WebhookTimeout:       webhookTimeout,
+		BatchMaxItems:        batchMaxItems,
+		ReconcileInterval:    reconcileInterval,
+		ReconcileFix:         reconcileFix,
+		ScheduleInterval:     scheduleInterval,
 	}, nil
 }
+
+// durationEnv parses the environment variable name as a time.Duration such
+// as "15m", falling back to def when it is unset.
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, v)
+	}
+	return d, nil
+}
+
+// intEnv parses the environment variable name as a positive integer, falling
+// back to def when it is unset.
+func intEnv(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, v)
+	}
+	return n, nil
+}
+
+// boolEnv parses the environment variable name with strconv.ParseBool,
+// treating an unset variable as false.
+func boolEnv(name string) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %q", name, v)
+	}
+	return b, nil
+}
@@ -0,0 +1,153 @@
+// Package cron parses the five-field schedules of crontab(5) and works out
+// when they fire next.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr is a parsed schedule: the minutes, hours, days of the month, months and
+// weekdays it fires on, each kept as a bit set.
+type Expr struct {
+	minute, hour, dom, month, dow uint64
+	// As in crontab(5), a day matches either field when both are restricted,
+	// and only the restricted one when the other is a star.
+	domStar, dowStar bool
+}
+
+// macros are the shorthands crontab(5) accepts in place of the five fields.
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// horizon bounds the search for the next time an expression fires, so that
+// one that never does, such as "0 0 30 2 *", cannot loop forever.
+const horizon = 5 * 366 * 24 * time.Hour
+
+// Parse reads "minute hour day-of-month month day-of-week", where each field
+// is "*", a number, a range "a-b" or a comma-separated list of them, any of
+// which may be stepped with "/n". Weekdays run from 0 (Sunday) to 7 (Sunday
+// again).
+func Parse(spec string) (Expr, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := macros[spec]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Expr{}, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	var e Expr
+	var err error
+	if e.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return Expr{}, fmt.Errorf("minute: %w", err)
+	}
+	if e.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return Expr{}, fmt.Errorf("hour: %w", err)
+	}
+	if e.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return Expr{}, fmt.Errorf("day of month: %w", err)
+	}
+	if e.month, err = parseField(fields[3], 1, 12); err != nil {
+		return Expr{}, fmt.Errorf("month: %w", err)
+	}
+	if e.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return Expr{}, fmt.Errorf("day of week: %w", err)
+	}
+	if e.dow&(1<<7) != 0 {
+		e.dow |= 1
+	}
+	e.domStar = strings.HasPrefix(fields[2], "*")
+	e.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return e, nil
+}
+
+func parseField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		span, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			span, step = part[:i], n
+		}
+
+		from, to := lo, hi
+		switch i := strings.IndexByte(span, '-'); {
+		case span == "*":
+		case i >= 0:
+			var err error
+			if from, err = strconv.Atoi(span[:i]); err != nil {
+				return 0, fmt.Errorf("invalid range %q", span)
+			}
+			if to, err = strconv.Atoi(span[i+1:]); err != nil {
+				return 0, fmt.Errorf("invalid range %q", span)
+			}
+		default:
+			n, err := strconv.Atoi(span)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", span)
+			}
+			// "5/15" means every 15 starting at 5.
+			from, to = n, n
+			if step > 1 {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, lo, hi)
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time after t that e fires, in the location of t, or
+// the zero time if it does not fire within five years.
+func (e Expr) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+
+	limit := t.Add(horizon)
+	for t.Before(limit) {
+		switch {
+		case e.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !e.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case e.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case e.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (e Expr) dayMatches(t time.Time) bool {
+	dom := e.dom&(1<<uint(t.Day())) != 0
+	dow := e.dow&(1<<uint(t.Weekday())) != 0
+	if e.domStar || e.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
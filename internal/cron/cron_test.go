@@ -0,0 +1,66 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2026, 10, 17, 14, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 17, 14, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 17, 14, 45, 0, 0, time.UTC)},
+		{"5/15 * * * *", time.Date(2026, 10, 17, 14, 35, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 8 * * 1-5", time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0,12 1,15 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		// Both days restricted: either one matches, so Monday the 19th wins
+		// over the 1st.
+		{"0 0 1 * 1", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			e, err := Parse(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, e.Next(from))
+		})
+	}
+
+	t.Run("never fires", func(t *testing.T) {
+		e, err := Parse("0 0 30 2 *")
+		require.NoError(t, err)
+		assert.True(t, e.Next(from).IsZero())
+	})
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@often",
+	} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,101 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+	"wallet-service/internal/apperror"
+
+	"github.com/shopspring/decimal"
+)
+
+// Rate is the price of one unit of From expressed in To, as quoted at Timestamp.
+type Rate struct {
+	From      string
+	To        string
+	Value     decimal.Decimal
+	Timestamp time.Time
+}
+
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (Rate, error)
+}
+
+// crossRatePrecision is the number of decimal places kept when a rate is
+// derived from two quotes against the base currency.
+const crossRatePrecision = 10
+
+// StaticProvider serves rates from a fixed table quoted against a single base
+// currency and never reaches out to the network.
+type StaticProvider struct {
+	base      string
+	rates     map[string]decimal.Decimal
+	timestamp time.Time
+}
+
+// NewStaticProvider builds a provider from rates, where rates[c] is the
+// amount of c that one unit of base buys.
+func NewStaticProvider(base string, rates map[string]decimal.Decimal, timestamp time.Time) *StaticProvider {
+	table := make(map[string]decimal.Decimal, len(rates)+1)
+	for currency, rate := range rates {
+		table[currency] = rate
+	}
+	table[base] = decimal.NewFromInt(1)
+
+	return &StaticProvider{
+		base:      base,
+		rates:     table,
+		timestamp: timestamp,
+	}
+}
+
+type rateFile struct {
+	Base      string                     `json:"base"`
+	Timestamp time.Time                  `json:"timestamp"`
+	Rates     map[string]decimal.Decimal `json:"rates"`
+}
+
+// LoadFile reads a JSON rate table such as
+//
+//	{"base": "USD", "timestamp": "2025-01-01T00:00:00Z", "rates": {"EUR": "0.92"}}
+func LoadFile(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rates file: %w", err)
+	}
+
+	var file rateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse rates file: %w", err)
+	}
+	if file.Base == "" {
+		return nil, fmt.Errorf("parse rates file: base currency is required")
+	}
+	for currency, rate := range file.Rates {
+		if !rate.IsPositive() {
+			return nil, fmt.Errorf("parse rates file: rate for %s must be positive", currency)
+		}
+	}
+
+	return NewStaticProvider(file.Base, file.Rates, file.Timestamp), nil
+}
+
+func (p *StaticProvider) Rate(ctx context.Context, from, to string) (Rate, error) {
+	fromRate, ok := p.rates[from]
+	if !ok {
+		return Rate{}, apperror.Errorf(apperror.CodeRateUnavailable, "no exchange rate for %s", from)
+	}
+	toRate, ok := p.rates[to]
+	if !ok {
+		return Rate{}, apperror.Errorf(apperror.CodeRateUnavailable, "no exchange rate for %s", to)
+	}
+
+	return Rate{
+		From:      from,
+		To:        to,
+		Value:     toRate.DivRound(fromRate, crossRatePrecision),
+		Timestamp: p.timestamp,
+	}, nil
+}
@@ -0,0 +1,81 @@
+package exchange
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"wallet-service/internal/apperror"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticProvider(t *testing.T) {
+	timestamp := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	provider := NewStaticProvider("USD", map[string]decimal.Decimal{
+		"EUR": decimal.RequireFromString("0.8"),
+		"JPY": decimal.RequireFromString("150"),
+	}, timestamp)
+	ctx := context.Background()
+
+	t.Run("from base", func(t *testing.T) {
+		rate, err := provider.Rate(ctx, "USD", "EUR")
+		require.NoError(t, err)
+		assert.True(t, decimal.RequireFromString("0.8").Equal(rate.Value))
+		assert.Equal(t, timestamp, rate.Timestamp)
+	})
+
+	t.Run("to base", func(t *testing.T) {
+		rate, err := provider.Rate(ctx, "EUR", "USD")
+		require.NoError(t, err)
+		assert.True(t, decimal.RequireFromString("1.25").Equal(rate.Value))
+	})
+
+	t.Run("cross rate", func(t *testing.T) {
+		rate, err := provider.Rate(ctx, "EUR", "JPY")
+		require.NoError(t, err)
+		assert.True(t, decimal.RequireFromString("187.5").Equal(rate.Value))
+		assert.Equal(t, "EUR", rate.From)
+		assert.Equal(t, "JPY", rate.To)
+	})
+
+	t.Run("unknown currency", func(t *testing.T) {
+		_, err := provider.Rate(ctx, "USD", "GBP")
+		assert.ErrorIs(t, err, apperror.ErrRateUnavailable)
+	})
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("success", func(t *testing.T) {
+		path := filepath.Join(dir, "rates.json")
+		content := `{"base": "USD", "timestamp": "2025-01-01T00:00:00Z", "rates": {"EUR": "0.92"}}`
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		provider, err := LoadFile(path)
+		require.NoError(t, err)
+
+		rate, err := provider.Rate(context.Background(), "USD", "EUR")
+		require.NoError(t, err)
+		assert.True(t, decimal.RequireFromString("0.92").Equal(rate.Value))
+		assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), rate.Timestamp)
+	})
+
+	t.Run("non-positive rate", func(t *testing.T) {
+		path := filepath.Join(dir, "zero.json")
+		content := `{"base": "USD", "rates": {"EUR": "0"}}`
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		_, err := LoadFile(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadFile(filepath.Join(dir, "missing.json"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+)
+
+var operationCSVHeader = []string{
+	"id", "wallet_id", "operation_type", "amount", "currency", "balance_after", "transfer_id", "reference_id", "created_at",
+}
+
+// ExportOperations streams the operations of a wallet, or of every wallet when
+// walletId is omitted, as CSV, newest first.
+func (h *Handler) ExportOperations(c *fiber.Ctx) error {
+	filter := model.OperationFilter{
+		WalletId:      c.Query("walletId"),
+		OperationType: c.Query("operationType"),
+	}
+
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		return err
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		return err
+	}
+
+	if err := model.ValidateExportFilter(filter); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+	if filter.WalletId != "" {
+		if _, err := h.service.GetWalletByUuid(c.Context(), filter.WalletId); err != nil {
+			return err
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="operations.csv"`)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The stream is written after the handler returns and its request
+		// context is recycled, so the export runs on a context of its own and
+		// ends as soon as the client stops reading.
+		if err := h.writeOperations(context.Background(), w, filter); err != nil {
+			h.logger.Error("failed to export operations", slog.Any("error", err))
+		}
+	})
+
+	return nil
+}
+
+// writeOperations writes the CSV export of filter to w, flushing it after the
+// header and every page. A failed flush means the client has gone away, so it
+// stops the export before another page is read.
+func (h *Handler) writeOperations(ctx context.Context, w *bufio.Writer, filter model.OperationFilter) error {
+	out := csv.NewWriter(w)
+	flush := func() error {
+		out.Flush()
+		if err := out.Error(); err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+
+	if err := out.Write(operationCSVHeader); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return h.service.ExportOperations(ctx, filter, func(operations []model.Operation) error {
+		for _, op := range operations {
+			if err := out.Write(operationRecord(op)); err != nil {
+				return err
+			}
+		}
+		return flush()
+	})
+}
+
+func operationRecord(op model.Operation) []string {
+	return []string{
+		op.Id,
+		op.WalletId,
+		op.OperationType,
+		op.Amount.String(),
+		op.Currency,
+		op.BalanceAfter.String(),
+		op.TransferId,
+		op.ReferenceId,
+		op.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// importRow is a CSV line that passed validation.
+type importRow struct {
+	line        int
+	transaction model.Transaction
+}
+
+type importLineError struct {
+	Line  int           `json:"line"`
+	Error string        `json:"error"`
+	Code  apperror.Code `json:"code"`
+}
+
+// ImportOperations applies deposits and withdrawals listed in a CSV body with
+// the columns wallet_id, operation_type, amount and an optional currency; a
+// first line starting with "wallet_id" is taken as a header.
+//
+// The import is all or nothing: every line is validated first, and the rows
+// are applied as one atomic batch only if none of them failed. With
+// ?dryRun=true the rows are applied and rolled back, so the report also shows
+// the lines the wallets would reject. An Idempotency-Key header is extended
+// with the line number for each row, as in Batch.
+func (h *Handler) ImportOperations(c *fiber.Ctx) error {
+	ctx := c.Context()
+	dryRun := c.QueryBool("dryRun")
+
+	key := c.Get(IdempotencyKeyHeader)
+	rows, lineErrors := parseImport(c.Body(), key)
+	total := len(rows) + len(lineErrors)
+	if total == 0 {
+		return apperror.New(apperror.CodeValidation, "the file has no operations")
+	}
+	if len(rows) > 0 {
+		if err := model.ValidateItemKey(key, rows[len(rows)-1].line); err != nil {
+			h.logger.Warn("validation failed", slog.Any("error", err))
+			return err
+		}
+	}
+
+	if len(rows) > 0 && (dryRun || len(lineErrors) == 0) {
+		transactions := make([]model.Transaction, len(rows))
+		for i, row := range rows {
+			transactions[i] = row.transaction
+		}
+
+		results, err := h.service.Batch(ctx, transactions, model.BatchOptions{Atomic: !dryRun, DryRun: dryRun})
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			// An atomic batch reports every other row as aborted; only the
+			// row that failed it is worth listing.
+			if result.Err == nil || apperror.CodeOf(result.Err) == apperror.CodeBatchAborted {
+				continue
+			}
+			item := h.batchItem(i, result)
+			lineErrors = append(lineErrors, importLineError{Line: rows[i].line, Error: item.Error, Code: item.Code})
+		}
+		slices.SortFunc(lineErrors, func(a, b importLineError) int { return a.Line - b.Line })
+	}
+
+	body := fiber.Map{
+		"dryRun":  dryRun,
+		"applied": !dryRun && len(lineErrors) == 0,
+		"rows":    total,
+		"errors":  lineErrors,
+	}
+	if !dryRun && len(lineErrors) > 0 {
+		body["error"] = fmt.Sprintf("import rejected: %d of %d lines failed", len(lineErrors), total)
+		body["code"] = apperror.CodeImportRejected
+		return c.Status(statusOf(apperror.CodeImportRejected)).JSON(body)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(body)
+}
+
+// parseImport reads the rows of an import. Lines that cannot be read or do
+// not validate are returned as errors rather than stopping the parse.
+func parseImport(data []byte, key string) ([]importRow, []importLineError) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows := []importRow{}
+	lineErrors := []importLineError{}
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				break
+			}
+			lineErrors = append(lineErrors, importLineError{Line: parseErr.Line, Error: parseErr.Err.Error(), Code: apperror.CodeValidation})
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "wallet_id") {
+			continue
+		}
+
+		transaction, err := importTransaction(record)
+		if err != nil {
+			lineErrors = append(lineErrors, importLineError{Line: line, Error: err.Error(), Code: apperror.CodeOf(err)})
+			continue
+		}
+		if key != "" {
+			transaction.IdempotencyKey = key + "/" + strconv.Itoa(line)
+		}
+		rows = append(rows, importRow{line: line, transaction: transaction})
+	}
+
+	return rows, lineErrors
+}
+
+func importTransaction(record []string) (model.Transaction, error) {
+	if len(record) != 3 && len(record) != 4 {
+		return model.Transaction{}, apperror.Errorf(apperror.CodeValidation, "expected 3 or 4 columns, got %d", len(record))
+	}
+
+	amount, err := decimal.NewFromString(strings.TrimSpace(record[2]))
+	if err != nil {
+		return model.Transaction{}, apperror.New(apperror.CodeValidation, "invalid amount format")
+	}
+
+	transaction := model.Transaction{
+		Uuid:          strings.TrimSpace(record[0]),
+		OperationType: strings.ToUpper(strings.TrimSpace(record[1])),
+		Amount:        amount,
+	}
+	if len(record) == 4 {
+		transaction.Currency = strings.ToUpper(strings.TrimSpace(record[3]))
+	}
+
+	if err := model.ValidateTransaction(transaction); err != nil {
+		return model.Transaction{}, err
+	}
+	return transaction, nil
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"wallet-service/internal/apperror"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+var statusByCode = map[apperror.Code]int{
+	apperror.CodeInvalidRequest:      fiber.StatusBadRequest,
+	apperror.CodeValidation:          fiber.StatusBadRequest,
+	apperror.CodeWalletNotFound:      fiber.StatusNotFound,
+	apperror.CodeHoldNotFound:        fiber.StatusNotFound,
+	apperror.CodeOperationNotFound:   fiber.StatusNotFound,
+	apperror.CodeLimitPolicyNotFound: fiber.StatusNotFound,
+	apperror.CodeWebhookNotFound:     fiber.StatusNotFound,
+	apperror.CodeDeliveryNotFound:    fiber.StatusNotFound,
+	apperror.CodeRunNotFound:         fiber.StatusNotFound,
+	apperror.CodeFeeRuleNotFound:     fiber.StatusNotFound,
+	apperror.CodeScheduleNotFound:    fiber.StatusNotFound,
+	apperror.CodeIdempotencyConflict: fiber.StatusConflict,
+	apperror.CodeHoldNotActive:       fiber.StatusConflict,
+	apperror.CodeScheduleNotActive:   fiber.StatusConflict,
+	apperror.CodeWalletExists:        fiber.StatusConflict,
+	apperror.CodeWalletFrozen:        fiber.StatusConflict,
+	apperror.CodeWalletClosed:        fiber.StatusConflict,
+	apperror.CodeInvalidTransition:   fiber.StatusConflict,
+	apperror.CodeBalanceNotZero:      fiber.StatusConflict,
+	apperror.CodeCreditLimitInUse:    fiber.StatusConflict,
+	apperror.CodeBatchAborted:        fiber.StatusConflict,
+	apperror.CodeVersionMismatch:     fiber.StatusPreconditionFailed,
+	apperror.CodeInsufficientFunds:   fiber.StatusUnprocessableEntity,
+	apperror.CodeCurrencyMismatch:    fiber.StatusUnprocessableEntity,
+	apperror.CodeRefundExceeded:      fiber.StatusUnprocessableEntity,
+	apperror.CodeRateUnavailable:     fiber.StatusUnprocessableEntity,
+	apperror.CodeImportRejected:      fiber.StatusUnprocessableEntity,
+	apperror.CodeInternal:            fiber.StatusInternalServerError,
+
+	apperror.CodeMaxBalanceExceeded:        fiber.StatusUnprocessableEntity,
+	apperror.CodeSingleAmountExceeded:      fiber.StatusUnprocessableEntity,
+	apperror.CodeDailyDepositExceeded:      fiber.StatusUnprocessableEntity,
+	apperror.CodeDailyWithdrawalExceeded:   fiber.StatusUnprocessableEntity,
+	apperror.CodeMonthlyDepositExceeded:    fiber.StatusUnprocessableEntity,
+	apperror.CodeMonthlyWithdrawalExceeded: fiber.StatusUnprocessableEntity,
+}
+
+// ErrorHandler is the Fiber error handler for the API. Domain errors are
+// reported with their code and message; anything else is logged and hidden
+// behind a generic 500 so that driver errors never reach the client.
+func (h *Handler) ErrorHandler(c *fiber.Ctx, err error) error {
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		return c.Status(statusOf(appErr.Code)).JSON(fiber.Map{"error": appErr.Message, "code": appErr.Code})
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		code := strings.ToUpper(strings.ReplaceAll(utils.StatusMessage(fiberErr.Code), " ", "_"))
+		return c.Status(fiberErr.Code).JSON(fiber.Map{"error": fiberErr.Message, "code": code})
+	}
+
+	h.logger.Error("request failed", slog.String("path", c.Path()), slog.Any("error", err))
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "internal server error", "code": apperror.CodeInternal})
+}
+
+// statusOf returns the HTTP status reported for code.
+func statusOf(code apperror.Code) int {
+	if status, ok := statusByCode[code]; ok {
+		return status
+	}
+	return fiber.StatusInternalServerError
+}
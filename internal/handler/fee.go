@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"log/slog"
+	"strings"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+)
+
+// UpsertFeeRule sets the fee on an operation type in a currency, both taken
+// from the path, e.g. PUT /fee-rules/WITHDRAW/USD.
+func (h *Handler) UpsertFeeRule(c *fiber.Ctx) error {
+	ctx := c.Context()
+	var rule model.FeeRule
+	if err := c.BodyParser(&rule); err != nil {
+		h.logger.Error("failed to parse request", slog.Any("error", err))
+		return apperror.ErrInvalidRequest
+	}
+	rule.OperationType = strings.ToUpper(c.Params("operationType"))
+	rule.Currency = strings.ToUpper(c.Params("currency"))
+
+	if err := model.ValidateFeeRule(rule); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	result, err := h.service.UpsertFeeRule(ctx, rule)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) ListFeeRules(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	rules, err := h.service.ListFeeRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"rules": rules})
+}
+
+func (h *Handler) DeleteFeeRule(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	operationType := strings.ToUpper(c.Params("operationType"))
+	currency := strings.ToUpper(c.Params("currency"))
+	if err := h.service.DeleteFeeRule(ctx, operationType, currency); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// QuoteFee returns the fee an operation on the wallet would be charged, e.g.
+// GET /wallet/:uuid/fee-quote?operationType=WITHDRAW&amount=100.
+func (h *Handler) QuoteFee(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	amount, err := decimal.NewFromString(c.Query("amount"))
+	if err != nil {
+		return apperror.New(apperror.CodeValidation, "invalid amount format")
+	}
+
+	quote := model.FeeQuote{
+		WalletId:      c.Params("uuid"),
+		OperationType: strings.ToUpper(c.Query("operationType")),
+		Amount:        amount,
+	}
+	if err := model.ValidateFeeQuote(quote); err != nil {
+		return err
+	}
+
+	quote, err = h.service.QuoteFee(ctx, quote)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(quote)
+}
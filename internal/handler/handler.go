@@ -1,7 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"wallet-service/internal/apperror"
 	"wallet-service/internal/model"
 	"wallet-service/internal/service"
 
@@ -9,6 +14,8 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 type Handler struct {
 	service service.Service
 	logger  *slog.Logger
@@ -23,13 +30,65 @@ func NewHandler(service service.Service, logger *slog.Logger) *Handler {
 
 func (h *Handler) CreateWallet(c *fiber.Ctx) error {
 	ctx := c.Context()
+	var req model.CreateWalletRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			h.logger.Error("failed to parse request", slog.Any("error", err))
+			return apperror.ErrInvalidRequest
+		}
+	}
+
+	wallet := model.Wallet{
+		Currency: strings.ToUpper(req.Currency),
+		OwnerId:  req.OwnerId,
+		Label:    req.Label,
+		Metadata: req.Metadata,
+	}
+	if wallet.Currency == "" {
+		wallet.Currency = model.DefaultCurrency
+	}
 
-	uuid, err := h.service.CreateWallet(ctx)
+	if err := model.ValidateWallet(wallet); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	wallet, err := h.service.CreateWallet(ctx, wallet)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to create wallet"})
+		if errors.Is(err, apperror.ErrWalletExists) {
+			return err
+		}
+		return apperror.New(apperror.CodeInternal, "failed to create wallet")
+	}
+
+	resp := fiber.Map{"uuid": wallet.Id, "currency": wallet.Currency}
+	if wallet.OwnerId != "" {
+		resp["ownerId"] = wallet.OwnerId
+	}
+	if wallet.Label != "" {
+		resp["label"] = wallet.Label
+	}
+	if len(wallet.Metadata) > 0 {
+		resp["metadata"] = wallet.Metadata
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"uuid": uuid})
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func (h *Handler) ListWallets(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	ownerId := c.Query("ownerId")
+	if ownerId == "" {
+		return apperror.New(apperror.CodeValidation, "ownerId is required")
+	}
+
+	wallets, err := h.service.ListWalletsByOwner(ctx, ownerId)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"wallets": wallets})
 }
 
 func (h *Handler) Transaction(c *fiber.Ctx) error {
@@ -37,41 +96,681 @@ func (h *Handler) Transaction(c *fiber.Ctx) error {
 	var req model.TransactionRequest
 	if err := c.BodyParser(&req); err != nil {
 		h.logger.Error("failed to parse request", slog.Any("error", err))
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request format"})
+		return apperror.ErrInvalidRequest
 	}
 
 	amount, err := decimal.NewFromString(req.Amount)
 	if err != nil {
 		h.logger.Warn("invalid amount format", slog.Any("error", err))
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid amount format"})
+		return apperror.New(apperror.CodeValidation, "invalid amount format")
+	}
+
+	version, err := parseIfMatch(c)
+	if err != nil {
+		return err
 	}
 
 	transaction := model.Transaction{
-		Uuid:          req.ValletId,
-		OperationType: req.OperationType,
-		Amount:        amount,
+		Uuid:            req.ValletId,
+		OperationType:   req.OperationType,
+		Amount:          amount,
+		Currency:        strings.ToUpper(req.Currency),
+		IdempotencyKey:  c.Get(IdempotencyKeyHeader),
+		ExpectedVersion: version,
 	}
 
 	if err := model.ValidateTransaction(transaction); err != nil {
 		h.logger.Warn("validation failed", slog.Any("error", err))
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
 
-	if err := h.service.Transaction(ctx, transaction); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	op, err := h.service.Transaction(ctx, transaction)
+	if err != nil {
+		return err
+	}
+
+	fee := decimal.Zero
+	if op.Fee != nil {
+		fee = op.Fee.Amount
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "OK"})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":       "OK",
+		"transactionId": op.Id,
+		"balance":       op.FinalBalance().String(),
+		"fee":           fee.String(),
+		"currency":      op.Currency,
+	})
 }
 
+func (h *Handler) Refund(c *fiber.Ctx) error {
+	ctx := c.Context()
+	var req model.RefundRequest
+	if err := c.BodyParser(&req); err != nil {
+		h.logger.Error("failed to parse request", slog.Any("error", err))
+		return apperror.ErrInvalidRequest
+	}
+
+	amount := decimal.Zero
+	if req.Amount != "" {
+		var err error
+		amount, err = decimal.NewFromString(req.Amount)
+		if err != nil {
+			h.logger.Warn("invalid amount format", slog.Any("error", err))
+			return apperror.New(apperror.CodeValidation, "invalid amount format")
+		}
+		if !amount.IsPositive() {
+			return apperror.New(apperror.CodeValidation, "amount must be positive")
+		}
+	}
+
+	transaction := model.Transaction{
+		OperationType:  model.TransactionRefund,
+		Amount:         amount,
+		ReferenceId:    req.OperationId,
+		IdempotencyKey: c.Get(IdempotencyKeyHeader),
+	}
+
+	if err := model.ValidateTransaction(transaction); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	op, err := h.service.Transaction(ctx, transaction)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message":       "OK",
+		"transactionId": op.Id,
+		"referenceId":   op.ReferenceId,
+		"amount":        op.Amount.String(),
+		"balance":       op.BalanceAfter.String(),
+		"currency":      op.Currency,
+	})
+}
+
+func (h *Handler) Transfer(c *fiber.Ctx) error {
+	ctx := c.Context()
+	var req model.TransferRequest
+	if err := c.BodyParser(&req); err != nil {
+		h.logger.Error("failed to parse request", slog.Any("error", err))
+		return apperror.ErrInvalidRequest
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		h.logger.Warn("invalid amount format", slog.Any("error", err))
+		return apperror.New(apperror.CodeValidation, "invalid amount format")
+	}
+
+	transfer := model.Transfer{
+		FromUuid: req.FromWalletId,
+		ToUuid:   req.ToWalletId,
+		Amount:   amount,
+	}
+
+	if err := model.ValidateTransfer(transfer); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	result, err := h.service.Transfer(ctx, transfer)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// GetWallet returns the current balances of a wallet, or with ?asOf= the
+// balance it held at that moment.
 func (h *Handler) GetWallet(c *fiber.Ctx) error {
 	ctx := c.Context()
 	uuid := c.Params("uuid")
 
-	balance, err := h.service.GetBalanceByUuid(ctx, uuid)
+	asOf, err := parseTimeQuery(c, "asOf")
+	if err != nil {
+		return err
+	}
+	if !asOf.IsZero() {
+		balance, err := h.service.GetBalanceAsOf(ctx, uuid, asOf)
+		if err != nil {
+			return err
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"balance":  balance.Balance.String(),
+			"currency": balance.Currency,
+			"asOf":     balance.AsOf,
+		})
+	}
+
+	wallet, err := h.service.GetWalletByUuid(ctx, uuid)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderETag, walletETag(wallet.Version))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"balance":         wallet.Balance.String(),
+		"available":       wallet.Available().String(),
+		"total":           wallet.Balance.String(),
+		"currency":        wallet.Currency,
+		"status":          wallet.Status,
+		"creditLimit":     wallet.CreditLimit.String(),
+		"availableCredit": wallet.AvailableCredit().String(),
+	})
+}
+
+func (h *Handler) FreezeWallet(c *fiber.Ctx) error {
+	return h.changeWalletStatus(c, model.WalletFrozen)
+}
+
+func (h *Handler) UnfreezeWallet(c *fiber.Ctx) error {
+	return h.changeWalletStatus(c, model.WalletActive)
+}
+
+func (h *Handler) CloseWallet(c *fiber.Ctx) error {
+	return h.changeWalletStatus(c, model.WalletClosed)
+}
+
+func (h *Handler) UpsertLimitPolicy(c *fiber.Ctx) error {
+	ctx := c.Context()
+	var policy model.LimitPolicy
+	if err := c.BodyParser(&policy); err != nil {
+		h.logger.Error("failed to parse request", slog.Any("error", err))
+		return apperror.ErrInvalidRequest
+	}
+	policy.Name = c.Params("name")
+	policy.Currency = strings.ToUpper(policy.Currency)
+
+	if err := model.ValidateLimitPolicy(policy); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	result, err := h.service.UpsertLimitPolicy(ctx, policy)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) SetWalletLimitPolicy(c *fiber.Ctx) error {
+	ctx := c.Context()
+	var req model.AssignLimitPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		h.logger.Error("failed to parse request", slog.Any("error", err))
+		return apperror.ErrInvalidRequest
+	}
+
+	wallet, err := h.service.SetWalletLimitPolicy(ctx, c.Params("uuid"), req.Policy)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(wallet)
+}
+
+func (h *Handler) SetWalletCreditLimit(c *fiber.Ctx) error {
+	ctx := c.Context()
+	var req model.CreditLimitRequest
+	if err := c.BodyParser(&req); err != nil {
+		h.logger.Error("failed to parse request", slog.Any("error", err))
+		return apperror.ErrInvalidRequest
+	}
+
+	if err := model.ValidateCreditLimit(req.CreditLimit); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	wallet, err := h.service.SetWalletCreditLimit(ctx, c.Params("uuid"), req.CreditLimit)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(wallet)
+}
+
+func (h *Handler) changeWalletStatus(c *fiber.Ctx, status string) error {
+	ctx := c.Context()
+	var req model.StatusChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		h.logger.Error("failed to parse request", slog.Any("error", err))
+		return apperror.ErrInvalidRequest
+	}
+
+	change := model.StatusChange{
+		WalletId:      c.Params("uuid"),
+		Status:        status,
+		Reason:        req.Reason,
+		Actor:         req.Actor,
+		AllowDeposits: req.AllowDeposits,
+	}
+
+	if err := model.ValidateStatusChange(change); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	result, err := h.service.ChangeWalletStatus(ctx, change)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) AuthorizeHold(c *fiber.Ctx) error {
+	ctx := c.Context()
+	var req model.HoldRequest
+	if err := c.BodyParser(&req); err != nil {
+		h.logger.Error("failed to parse request", slog.Any("error", err))
+		return apperror.ErrInvalidRequest
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		h.logger.Warn("invalid amount format", slog.Any("error", err))
+		return apperror.New(apperror.CodeValidation, "invalid amount format")
+	}
+
+	hold := model.Hold{
+		WalletId: c.Params("uuid"),
+		Amount:   amount,
+	}
+
+	if err := model.ValidateHold(hold); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	result, err := h.service.AuthorizeHold(ctx, hold)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) CaptureHold(c *fiber.Ctx) error {
+	ctx := c.Context()
+	var req model.CaptureRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			h.logger.Error("failed to parse request", slog.Any("error", err))
+			return apperror.ErrInvalidRequest
+		}
+	}
+
+	amount := decimal.Zero
+	if req.Amount != "" {
+		var err error
+		amount, err = decimal.NewFromString(req.Amount)
+		if err != nil {
+			h.logger.Warn("invalid amount format", slog.Any("error", err))
+			return apperror.New(apperror.CodeValidation, "invalid amount format")
+		}
+		if !amount.IsPositive() {
+			return apperror.New(apperror.CodeValidation, "amount must be positive")
+		}
 	}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"balance": balance.String()})
+	hold, err := h.service.CaptureHold(ctx, c.Params("id"), amount)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(hold)
+}
+
+func (h *Handler) VoidHold(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	hold, err := h.service.VoidHold(ctx, c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(hold)
+}
+
+func (h *Handler) ListTransactions(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	filter := model.OperationFilter{
+		WalletId:      c.Params("uuid"),
+		OperationType: c.Query("operationType"),
+		Limit:         model.DefaultOperationsLimit,
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return apperror.New(apperror.CodeValidation, "invalid limit")
+		}
+		filter.Limit = n
+	}
+
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		return err
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		return err
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		decoded, err := model.DecodeOperationCursor(cursor)
+		if err != nil {
+			return err
+		}
+		filter.Cursor = &decoded
+	}
+
+	if err := model.ValidateOperationFilter(filter); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	page, err := h.service.ListOperations(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(page)
+}
+
+func parseTimeQuery(c *fiber.Ctx, name string) (time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, apperror.Errorf(apperror.CodeValidation, "invalid %s time, expected RFC3339", name)
+	}
+	return t, nil
+}
+
+// walletETag renders a wallet version as a strong entity tag.
+func walletETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch reads the wallet version an operation is conditional on from
+// the If-Match header, as returned in the ETag of GET api/v1/wallet/:uuid.
+// Without the header, or with "*", it returns zero and any version matches.
+func parseIfMatch(c *fiber.Ctx) (int64, error) {
+	v := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if v == "" || v == "*" {
+		return 0, nil
+	}
+	unquoted, ok := strings.CutPrefix(v, `"`)
+	if ok {
+		unquoted, ok = strings.CutSuffix(unquoted, `"`)
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if !ok || err != nil || version <= 0 {
+		return 0, apperror.Errorf(apperror.CodeValidation, "invalid If-Match header, expected a wallet ETag such as \"3\"")
+	}
+	return version, nil
+}
+
+func (h *Handler) CreateWebhook(c *fiber.Ctx) error {
+	ctx := c.Context()
+	var sub model.WebhookSubscription
+	if err := c.BodyParser(&sub); err != nil {
+		h.logger.Error("failed to parse request", slog.Any("error", err))
+		return apperror.ErrInvalidRequest
+	}
+	sub.Id = ""
+
+	if err := model.ValidateWebhookSubscription(sub); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	result, err := h.service.CreateWebhookSubscription(ctx, sub)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
+func (h *Handler) ListWebhooks(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	subs, err := h.service.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"webhooks": subs})
+}
+
+func (h *Handler) DeleteWebhook(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	if err := h.service.DeleteWebhookSubscription(ctx, c.Params("id")); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *Handler) ListWebhookDeliveries(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	status := strings.ToUpper(c.Query("status"))
+	if status != "" && !model.IsDeliveryStatus(status) {
+		return apperror.Errorf(apperror.CodeValidation, "invalid delivery status: %s", status)
+	}
+
+	deliveries, err := h.service.ListWebhookDeliveries(ctx, c.Params("id"), status)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"deliveries": deliveries})
+}
+
+func (h *Handler) RedeliverWebhook(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	delivery, err := h.service.RedeliverWebhook(ctx, c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(delivery)
+}
+
+// RunReconciliation compares every wallet's stored balance with its ledger.
+// Mismatched balances are only overwritten with ?fix=true.
+func (h *Handler) RunReconciliation(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	run, err := h.service.Reconcile(ctx, c.QueryBool("fix"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(run)
+}
+
+func (h *Handler) ListReconciliationRuns(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	runs, err := h.service.ListReconciliationRuns(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"runs": runs})
+}
+
+func (h *Handler) GetReconciliationRun(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return apperror.ErrRunNotFound
+	}
+
+	run, err := h.service.GetReconciliationRun(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(run)
+}
+
+// TrialBalance sums the ledger per currency and account. Every currency
+// nets to zero when the books balance.
+func (h *Handler) TrialBalance(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	balance, err := h.service.TrialBalance(ctx)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(balance)
+}
+
+type batchItemResponse struct {
+	Index         int           `json:"index"`
+	Status        string        `json:"status"`
+	TransactionId string        `json:"transactionId,omitempty"`
+	Balance       string        `json:"balance,omitempty"`
+	Fee           string        `json:"fee,omitempty"`
+	Currency      string        `json:"currency,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	Code          apperror.Code `json:"code,omitempty"`
+}
+
+// Batch applies a list of transactions. An Idempotency-Key header covers the
+// whole batch: item i is sent with the key "<key>/<i>", so a retried batch
+// skips the items that were already applied.
+func (h *Handler) Batch(c *fiber.Ctx) error {
+	ctx := c.Context()
+	var req model.BatchRequest
+	if err := c.BodyParser(&req); err != nil {
+		h.logger.Error("failed to parse request", slog.Any("error", err))
+		return apperror.ErrInvalidRequest
+	}
+	req.Mode = strings.ToUpper(req.Mode)
+
+	if err := model.ValidateBatch(req); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+	atomic := req.Mode == model.BatchAtomic
+	key := c.Get(IdempotencyKeyHeader)
+	if err := model.ValidateItemKey(key, len(req.Items)-1); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	items := make([]batchItemResponse, len(req.Items))
+	transactions := make([]model.Transaction, 0, len(req.Items))
+	indexes := make([]int, 0, len(req.Items))
+	for i, item := range req.Items {
+		transaction, err := batchTransaction(item, key, i)
+		if err != nil {
+			if atomic {
+				return apperror.Errorf(apperror.CodeValidation, "item %d: %s", i, err.Error())
+			}
+			items[i] = h.batchItem(i, model.BatchItemResult{Err: err})
+			continue
+		}
+		transactions = append(transactions, transaction)
+		indexes = append(indexes, i)
+	}
+
+	if len(transactions) > 0 {
+		results, err := h.service.Batch(ctx, transactions, model.BatchOptions{Atomic: atomic})
+		if err != nil {
+			return err
+		}
+		for j, result := range results {
+			items[indexes[j]] = h.batchItem(indexes[j], result)
+		}
+	}
+
+	succeeded := 0
+	for _, item := range items {
+		if item.Status == "OK" {
+			succeeded++
+		}
+	}
+	body := fiber.Map{
+		"mode":      req.Mode,
+		"succeeded": succeeded,
+		"failed":    len(items) - succeeded,
+		"items":     items,
+	}
+
+	// A failed atomic batch takes the status of the item that failed it.
+	if atomic && succeeded < len(items) {
+		for _, item := range items {
+			if item.Code != apperror.CodeBatchAborted {
+				body["error"] = item.Error
+				body["code"] = item.Code
+				return c.Status(statusOf(item.Code)).JSON(body)
+			}
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(body)
+}
+
+func batchTransaction(item model.TransactionRequest, key string, i int) (model.Transaction, error) {
+	amount, err := decimal.NewFromString(item.Amount)
+	if err != nil {
+		return model.Transaction{}, apperror.New(apperror.CodeValidation, "invalid amount format")
+	}
+
+	transaction := model.Transaction{
+		Uuid:          item.ValletId,
+		OperationType: item.OperationType,
+		Amount:        amount,
+		Currency:      strings.ToUpper(item.Currency),
+	}
+	if key != "" {
+		transaction.IdempotencyKey = key + "/" + strconv.Itoa(i)
+	}
+
+	if err := model.ValidateTransaction(transaction); err != nil {
+		return model.Transaction{}, err
+	}
+	return transaction, nil
+}
+
+func (h *Handler) batchItem(i int, result model.BatchItemResult) batchItemResponse {
+	if result.Err == nil {
+		item := batchItemResponse{
+			Index:         i,
+			Status:        "OK",
+			TransactionId: result.Operation.Id,
+			Balance:       result.Operation.FinalBalance().String(),
+			Currency:      result.Operation.Currency,
+		}
+		if result.Operation.Fee != nil {
+			item.Fee = result.Operation.Fee.Amount.String()
+		}
+		return item
+	}
+
+	var appErr *apperror.Error
+	if !errors.As(result.Err, &appErr) {
+		h.logger.Error("batch item failed", slog.Int("index", i), slog.Any("error", result.Err))
+		appErr = apperror.New(apperror.CodeInternal, "internal server error")
+	}
+	return batchItemResponse{Index: i, Status: "FAILED", Error: appErr.Message, Code: appErr.Code}
 }
@@ -1,15 +1,21 @@
 package handler
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
+	"wallet-service/internal/apperror"
 	"wallet-service/internal/model"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,21 +24,176 @@ import (
 )
 
 type MockService struct {
-	CreateWalletFn     func(ctx context.Context) (string, error)
-	TransactionFn      func(ctx context.Context, transaction model.Transaction) error
-	GetBalanceByUuidFn func(ctx context.Context, uuid string) (decimal.Decimal, error)
+	CreateWalletFn    func(ctx context.Context, wallet model.Wallet) (model.Wallet, error)
+	TransactionFn     func(ctx context.Context, transaction model.Transaction) (model.Operation, error)
+	GetWalletByUuidFn func(ctx context.Context, uuid string) (model.Wallet, error)
+	ListWalletsFn     func(ctx context.Context, ownerId string) ([]model.Wallet, error)
+	ListOperationsFn  func(ctx context.Context, filter model.OperationFilter) (model.OperationPage, error)
+	ExportFn          func(ctx context.Context, filter model.OperationFilter, fn func([]model.Operation) error) error
+	BalanceAsOfFn     func(ctx context.Context, uuid string, at time.Time) (model.HistoricalBalance, error)
+	GetStatementFn    func(ctx context.Context, walletId string, from, to time.Time) (model.Statement, error)
+	TransferFn        func(ctx context.Context, transfer model.Transfer) (model.Transfer, error)
+	BatchFn           func(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) ([]model.BatchItemResult, error)
+	AuthorizeHoldFn   func(ctx context.Context, hold model.Hold) (model.Hold, error)
+	CaptureHoldFn     func(ctx context.Context, id string, amount decimal.Decimal) (model.Hold, error)
+	VoidHoldFn        func(ctx context.Context, id string) (model.Hold, error)
+	ChangeStatusFn    func(ctx context.Context, change model.StatusChange) (model.StatusChange, error)
+	UpsertPolicyFn    func(ctx context.Context, policy model.LimitPolicy) (model.LimitPolicy, error)
+	SetPolicyFn       func(ctx context.Context, walletId, name string) (model.Wallet, error)
+	SetCreditLimitFn  func(ctx context.Context, walletId string, limit decimal.Decimal) (model.Wallet, error)
+	CreateWebhookFn   func(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error)
+	ListWebhooksFn    func(ctx context.Context) ([]model.WebhookSubscription, error)
+	DeleteWebhookFn   func(ctx context.Context, id string) error
+	ListDeliveriesFn  func(ctx context.Context, subscriptionId, status string) ([]model.WebhookDelivery, error)
+	RedeliverFn       func(ctx context.Context, id string) (model.WebhookDelivery, error)
+	ReconcileFn       func(ctx context.Context, fix bool) (model.ReconciliationRun, error)
+	ListRunsFn        func(ctx context.Context) ([]model.ReconciliationRun, error)
+	GetRunFn          func(ctx context.Context, id int64) (model.ReconciliationRun, error)
+	TrialBalanceFn    func(ctx context.Context) (model.TrialBalance, error)
+	UpsertFeeRuleFn   func(ctx context.Context, rule model.FeeRule) (model.FeeRule, error)
+	ListFeeRulesFn    func(ctx context.Context) ([]model.FeeRule, error)
+	DeleteFeeRuleFn   func(ctx context.Context, operationType, currency string) error
+	QuoteFeeFn        func(ctx context.Context, quote model.FeeQuote) (model.FeeQuote, error)
+	CreateScheduleFn  func(ctx context.Context, schedule model.Schedule) (model.Schedule, error)
+	GetScheduleFn     func(ctx context.Context, id string) (model.Schedule, error)
+	ListSchedulesFn   func(ctx context.Context, walletId, status string) ([]model.Schedule, error)
+	CancelScheduleFn  func(ctx context.Context, id string) (model.Schedule, error)
 }
 
-func (m *MockService) CreateWallet(ctx context.Context) (string, error) {
-	return m.CreateWalletFn(ctx)
+func (m *MockService) Reconcile(ctx context.Context, fix bool) (model.ReconciliationRun, error) {
+	return m.ReconcileFn(ctx, fix)
 }
 
-func (m *MockService) Transaction(ctx context.Context, transaction model.Transaction) error {
+func (m *MockService) ListReconciliationRuns(ctx context.Context) ([]model.ReconciliationRun, error) {
+	return m.ListRunsFn(ctx)
+}
+
+func (m *MockService) GetReconciliationRun(ctx context.Context, id int64) (model.ReconciliationRun, error) {
+	return m.GetRunFn(ctx, id)
+}
+
+func (m *MockService) TrialBalance(ctx context.Context) (model.TrialBalance, error) {
+	return m.TrialBalanceFn(ctx)
+}
+
+func (m *MockService) UpsertFeeRule(ctx context.Context, rule model.FeeRule) (model.FeeRule, error) {
+	return m.UpsertFeeRuleFn(ctx, rule)
+}
+
+func (m *MockService) ListFeeRules(ctx context.Context) ([]model.FeeRule, error) {
+	return m.ListFeeRulesFn(ctx)
+}
+
+func (m *MockService) DeleteFeeRule(ctx context.Context, operationType, currency string) error {
+	return m.DeleteFeeRuleFn(ctx, operationType, currency)
+}
+
+func (m *MockService) QuoteFee(ctx context.Context, quote model.FeeQuote) (model.FeeQuote, error) {
+	return m.QuoteFeeFn(ctx, quote)
+}
+
+func (m *MockService) CreateSchedule(ctx context.Context, schedule model.Schedule) (model.Schedule, error) {
+	return m.CreateScheduleFn(ctx, schedule)
+}
+
+func (m *MockService) GetSchedule(ctx context.Context, id string) (model.Schedule, error) {
+	return m.GetScheduleFn(ctx, id)
+}
+
+func (m *MockService) ListSchedules(ctx context.Context, walletId, status string) ([]model.Schedule, error) {
+	return m.ListSchedulesFn(ctx, walletId, status)
+}
+
+func (m *MockService) CancelSchedule(ctx context.Context, id string) (model.Schedule, error) {
+	return m.CancelScheduleFn(ctx, id)
+}
+
+func (m *MockService) CreateWallet(ctx context.Context, wallet model.Wallet) (model.Wallet, error) {
+	return m.CreateWalletFn(ctx, wallet)
+}
+
+func (m *MockService) Transaction(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
 	return m.TransactionFn(ctx, transaction)
 }
 
-func (m *MockService) GetBalanceByUuid(ctx context.Context, uuid string) (decimal.Decimal, error) {
-	return m.GetBalanceByUuidFn(ctx, uuid)
+func (m *MockService) GetWalletByUuid(ctx context.Context, uuid string) (model.Wallet, error) {
+	return m.GetWalletByUuidFn(ctx, uuid)
+}
+
+func (m *MockService) GetBalanceAsOf(ctx context.Context, uuid string, at time.Time) (model.HistoricalBalance, error) {
+	return m.BalanceAsOfFn(ctx, uuid, at)
+}
+
+func (m *MockService) ListWalletsByOwner(ctx context.Context, ownerId string) ([]model.Wallet, error) {
+	return m.ListWalletsFn(ctx, ownerId)
+}
+
+func (m *MockService) ListOperations(ctx context.Context, filter model.OperationFilter) (model.OperationPage, error) {
+	return m.ListOperationsFn(ctx, filter)
+}
+
+func (m *MockService) ExportOperations(ctx context.Context, filter model.OperationFilter, fn func([]model.Operation) error) error {
+	return m.ExportFn(ctx, filter, fn)
+}
+
+func (m *MockService) GetStatement(ctx context.Context, walletId string, from, to time.Time) (model.Statement, error) {
+	return m.GetStatementFn(ctx, walletId, from, to)
+}
+
+func (m *MockService) Transfer(ctx context.Context, transfer model.Transfer) (model.Transfer, error) {
+	return m.TransferFn(ctx, transfer)
+}
+
+func (m *MockService) Batch(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) ([]model.BatchItemResult, error) {
+	return m.BatchFn(ctx, transactions, opts)
+}
+
+func (m *MockService) AuthorizeHold(ctx context.Context, hold model.Hold) (model.Hold, error) {
+	return m.AuthorizeHoldFn(ctx, hold)
+}
+
+func (m *MockService) CaptureHold(ctx context.Context, id string, amount decimal.Decimal) (model.Hold, error) {
+	return m.CaptureHoldFn(ctx, id, amount)
+}
+
+func (m *MockService) VoidHold(ctx context.Context, id string) (model.Hold, error) {
+	return m.VoidHoldFn(ctx, id)
+}
+
+func (m *MockService) UpsertLimitPolicy(ctx context.Context, policy model.LimitPolicy) (model.LimitPolicy, error) {
+	return m.UpsertPolicyFn(ctx, policy)
+}
+
+func (m *MockService) SetWalletLimitPolicy(ctx context.Context, walletId, name string) (model.Wallet, error) {
+	return m.SetPolicyFn(ctx, walletId, name)
+}
+
+func (m *MockService) SetWalletCreditLimit(ctx context.Context, walletId string, limit decimal.Decimal) (model.Wallet, error) {
+	return m.SetCreditLimitFn(ctx, walletId, limit)
+}
+
+func (m *MockService) CreateWebhookSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+	return m.CreateWebhookFn(ctx, sub)
+}
+
+func (m *MockService) ListWebhookSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	return m.ListWebhooksFn(ctx)
+}
+
+func (m *MockService) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	return m.DeleteWebhookFn(ctx, id)
+}
+
+func (m *MockService) ListWebhookDeliveries(ctx context.Context, subscriptionId, status string) ([]model.WebhookDelivery, error) {
+	return m.ListDeliveriesFn(ctx, subscriptionId, status)
+}
+
+func (m *MockService) RedeliverWebhook(ctx context.Context, id string) (model.WebhookDelivery, error) {
+	return m.RedeliverFn(ctx, id)
+}
+
+func (m *MockService) ChangeWalletStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error) {
+	return m.ChangeStatusFn(ctx, change)
 }
 
 func TestCreateWallet(t *testing.T) {
@@ -40,13 +201,14 @@ func TestCreateWallet(t *testing.T) {
 
 	t.Run("Success", func(t *testing.T) {
 		mockService := &MockService{
-			CreateWalletFn: func(ctx context.Context) (string, error) {
-				return "test-uuid", nil
+			CreateWalletFn: func(ctx context.Context, wallet model.Wallet) (model.Wallet, error) {
+				wallet.Id = "test-uuid"
+				return wallet, nil
 			},
 		}
 		h := NewHandler(mockService, logger)
 
-		app := fiber.New()
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
 		app.Post("/wallets", h.CreateWallet)
 
 		req := httptest.NewRequest(http.MethodPost, "/wallets", nil)
@@ -57,17 +219,110 @@ func TestCreateWallet(t *testing.T) {
 		var body map[string]string
 		json.NewDecoder(resp.Body).Decode(&body)
 		assert.Equal(t, "test-uuid", body["uuid"])
+		assert.Equal(t, model.DefaultCurrency, body["currency"])
+	})
+
+	t.Run("Success with currency", func(t *testing.T) {
+		mockService := &MockService{
+			CreateWalletFn: func(ctx context.Context, wallet model.Wallet) (model.Wallet, error) {
+				wallet.Id = "test-uuid"
+				return wallet, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallets", h.CreateWallet)
+
+		req := httptest.NewRequest(http.MethodPost, "/wallets", bytes.NewBufferString(`{"currency": "eur"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "EUR", body["currency"])
+	})
+
+	t.Run("Success with owner", func(t *testing.T) {
+		var got model.Wallet
+		mockService := &MockService{
+			CreateWalletFn: func(ctx context.Context, wallet model.Wallet) (model.Wallet, error) {
+				got = wallet
+				wallet.Id = "test-uuid"
+				return wallet, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallets", h.CreateWallet)
+
+		reqBody := `{"ownerId": "user-1", "label": "Main", "metadata": {"tier": "gold"}}`
+		req := httptest.NewRequest(http.MethodPost, "/wallets", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "user-1", got.OwnerId)
+		assert.Equal(t, "Main", got.Label)
+		assert.Equal(t, map[string]any{"tier": "gold"}, got.Metadata)
+
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "user-1", body["ownerId"])
+		assert.Equal(t, map[string]any{"tier": "gold"}, body["metadata"])
+	})
+
+	t.Run("Owner already has a wallet", func(t *testing.T) {
+		mockService := &MockService{
+			CreateWalletFn: func(ctx context.Context, wallet model.Wallet) (model.Wallet, error) {
+				return model.Wallet{}, fmt.Errorf("create wallet: %w", apperror.ErrWalletExists)
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallets", h.CreateWallet)
+
+		req := httptest.NewRequest(http.MethodPost, "/wallets", bytes.NewBufferString(`{"ownerId": "user-1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, string(apperror.CodeWalletExists), body["code"])
+	})
+
+	t.Run("Unsupported currency", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallets", h.CreateWallet)
+
+		req := httptest.NewRequest(http.MethodPost, "/wallets", bytes.NewBufferString(`{"currency": "XYZ"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Contains(t, body["error"], "unsupported currency")
 	})
 
 	t.Run("Failed to create wallet", func(t *testing.T) {
 		mockService := &MockService{
-			CreateWalletFn: func(ctx context.Context) (string, error) {
-				return "", errors.New("service error")
+			CreateWalletFn: func(ctx context.Context, wallet model.Wallet) (model.Wallet, error) {
+				return model.Wallet{}, errors.New("service error")
 			},
 		}
 		h := NewHandler(mockService, logger)
 
-		app := fiber.New()
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
 		app.Post("/wallets", h.CreateWallet)
 
 		req := httptest.NewRequest(http.MethodPost, "/wallets", nil)
@@ -86,13 +341,13 @@ func TestTransaction(t *testing.T) {
 
 	t.Run("Success Deposit", func(t *testing.T) {
 		mockService := &MockService{
-			TransactionFn: func(ctx context.Context, transaction model.Transaction) error {
-				return nil
+			TransactionFn: func(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+				return model.Operation{Id: "op-1", BalanceAfter: decimal.NewFromInt(100)}, nil
 			},
 		}
 		h := NewHandler(mockService, logger)
 
-		app := fiber.New()
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
 		app.Post("/transaction", h.Transaction)
 
 		reqBody := `{"valletId": "test-uuid", "operationType": "DEPOSIT", "amount": "100"}`
@@ -108,15 +363,86 @@ func TestTransaction(t *testing.T) {
 		assert.Equal(t, "OK", body["message"])
 	})
 
+	t.Run("If-Match", func(t *testing.T) {
+		var got model.Transaction
+		mockService := &MockService{
+			TransactionFn: func(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+				got = transaction
+				if transaction.ExpectedVersion == 3 {
+					return model.Operation{}, apperror.ErrVersionMismatch
+				}
+				return model.Operation{Id: "op-1", BalanceAfter: decimal.NewFromInt(100)}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/transaction", h.Transaction)
+
+		send := func(ifMatch string) *http.Response {
+			reqBody := `{"valletId": "test-uuid", "operationType": "DEPOSIT", "amount": "100"}`
+			req := httptest.NewRequest(http.MethodPost, "/transaction", bytes.NewBufferString(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(fiber.HeaderIfMatch, ifMatch)
+			resp, _ := app.Test(req)
+			return resp
+		}
+
+		resp := send(`"4"`)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, int64(4), got.ExpectedVersion)
+
+		resp = send(`"3"`)
+		assert.Equal(t, fiber.StatusPreconditionFailed, resp.StatusCode)
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, string(apperror.CodeVersionMismatch), body["code"])
+
+		resp = send("*")
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Zero(t, got.ExpectedVersion)
+
+		for _, ifMatch := range []string{"4", `W/"4"`, `"abc"`, `"0"`} {
+			resp = send(ifMatch)
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, ifMatch)
+		}
+	})
+
+	t.Run("Withdraw with fee", func(t *testing.T) {
+		mockService := &MockService{
+			TransactionFn: func(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+				fee := model.Operation{Id: "op-2", OperationType: model.OperationFee, Amount: decimal.RequireFromString("1.5"), BalanceAfter: decimal.RequireFromString("48.5")}
+				return model.Operation{Id: "op-1", BalanceAfter: decimal.NewFromInt(50), Fee: &fee}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/transaction", h.Transaction)
+
+		reqBody := `{"valletId": "test-uuid", "operationType": "WITHDRAW", "amount": "50"}`
+		req := httptest.NewRequest(http.MethodPost, "/transaction", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "1.5", body["fee"])
+		assert.Equal(t, "48.5", body["balance"])
+	})
+
 	t.Run("Success Withdraw", func(t *testing.T) {
 		mockService := &MockService{
-			TransactionFn: func(ctx context.Context, transaction model.Transaction) error {
-				return nil
+			TransactionFn: func(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+				return model.Operation{Id: "op-1", BalanceAfter: decimal.NewFromInt(100)}, nil
 			},
 		}
 		h := NewHandler(mockService, logger)
 
-		app := fiber.New()
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
 		app.Post("/transaction", h.Transaction)
 
 		reqBody := `{"valletId": "test-uuid", "operationType": "WITHDRAW", "amount": "50"}`
@@ -136,7 +462,7 @@ func TestTransaction(t *testing.T) {
 		mockService := &MockService{}
 		h := NewHandler(mockService, logger)
 
-		app := fiber.New()
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
 		app.Post("/transaction", h.Transaction)
 
 		reqBody := `{"valletId": "test-uuid", "operationType": "INVALID", "amount": "100"}`
@@ -150,13 +476,14 @@ func TestTransaction(t *testing.T) {
 		var body map[string]string
 		json.NewDecoder(resp.Body).Decode(&body)
 		assert.Contains(t, body["error"], "invalid operation type")
+		assert.Equal(t, string(apperror.CodeValidation), body["code"])
 	})
 
 	t.Run("negative amount", func(t *testing.T) {
 		mockService := &MockService{}
 		h := NewHandler(mockService, logger)
 
-		app := fiber.New()
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
 		app.Post("/transaction", h.Transaction)
 
 		reqBody := `{"valletId": "test-uuid", "operationType": "DEPOSIT", "amount": "-10"}`
@@ -174,13 +501,13 @@ func TestTransaction(t *testing.T) {
 
 	t.Run("Service transaction error", func(t *testing.T) {
 		mockService := &MockService{
-			TransactionFn: func(ctx context.Context, transaction model.Transaction) error {
-				return errors.New("service transaction error")
+			TransactionFn: func(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+				return model.Operation{}, errors.New("service transaction error")
 			},
 		}
 		h := NewHandler(mockService, logger)
 
-		app := fiber.New()
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
 		app.Post("/transaction", h.Transaction)
 
 		reqBody := `{"valletId": "test-uuid", "operationType": "DEPOSIT", "amount": "100"}`
@@ -193,52 +520,1801 @@ func TestTransaction(t *testing.T) {
 
 		var body map[string]string
 		json.NewDecoder(resp.Body).Decode(&body)
-		assert.Contains(t, body["error"], "service transaction error")
+		assert.Equal(t, "internal server error", body["error"])
+		assert.Equal(t, string(apperror.CodeInternal), body["code"])
+	})
+
+	t.Run("Idempotency key is passed through", func(t *testing.T) {
+		var got model.Transaction
+		mockService := &MockService{
+			TransactionFn: func(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+				got = transaction
+				return model.Operation{Id: "op-1", BalanceAfter: decimal.NewFromInt(100)}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/transaction", h.Transaction)
+
+		reqBody := `{"valletId": "test-uuid", "operationType": "DEPOSIT", "amount": "100"}`
+		req := httptest.NewRequest(http.MethodPost, "/transaction", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "key-1", got.IdempotencyKey)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "op-1", body["transactionId"])
+		assert.Equal(t, "100", body["balance"])
+	})
+
+	t.Run("Insufficient funds", func(t *testing.T) {
+		mockService := &MockService{
+			TransactionFn: func(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+				return model.Operation{}, apperror.ErrInsufficientFunds
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/transaction", h.Transaction)
+
+		reqBody := `{"valletId": "test-uuid", "operationType": "WITHDRAW", "amount": "100"}`
+		req := httptest.NewRequest(http.MethodPost, "/transaction", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, string(apperror.CodeInsufficientFunds), body["code"])
+	})
+
+	t.Run("Idempotency key conflict", func(t *testing.T) {
+		mockService := &MockService{
+			TransactionFn: func(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+				return model.Operation{}, fmt.Errorf("transaction: %w", apperror.ErrIdempotencyKeyConflict)
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/transaction", h.Transaction)
+
+		reqBody := `{"valletId": "test-uuid", "operationType": "DEPOSIT", "amount": "100"}`
+		req := httptest.NewRequest(http.MethodPost, "/transaction", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
 	})
 }
 
-func TestGetWallet(t *testing.T) {
+func TestRefund(t *testing.T) {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
 	t.Run("Success", func(t *testing.T) {
+		var got model.Transaction
 		mockService := &MockService{
-			GetBalanceByUuidFn: func(ctx context.Context, uuid string) (decimal.Decimal, error) {
-				return decimal.NewFromInt(100), nil
+			TransactionFn: func(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+				got = transaction
+				return model.Operation{Id: "op-2", ReferenceId: transaction.ReferenceId, Amount: decimal.NewFromInt(25), BalanceAfter: decimal.NewFromInt(75), Currency: "USD"}, nil
 			},
 		}
 		h := NewHandler(mockService, logger)
 
-		app := fiber.New()
-		app.Get("/wallet/:uuid", h.GetWallet)
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/refund", h.Refund)
+
+		req := httptest.NewRequest(http.MethodPost, "/refund", bytes.NewBufferString(`{"operationId": "op-1", "amount": "25"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
 
-		req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid", nil)
 		resp, _ := app.Test(req)
 
 		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, model.TransactionRefund, got.OperationType)
+		assert.Equal(t, "op-1", got.ReferenceId)
+		assert.Equal(t, "key-1", got.IdempotencyKey)
+		assert.True(t, decimal.NewFromInt(25).Equal(got.Amount))
 
 		var body map[string]string
 		json.NewDecoder(resp.Body).Decode(&body)
-		assert.Equal(t, "100", body["balance"])
+		assert.Equal(t, "op-2", body["transactionId"])
+		assert.Equal(t, "op-1", body["referenceId"])
+		assert.Equal(t, "75", body["balance"])
 	})
 
-	t.Run("Service error", func(t *testing.T) {
+	t.Run("Missing operation id", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/refund", h.Refund)
+
+		req := httptest.NewRequest(http.MethodPost, "/refund", bytes.NewBufferString(`{"amount": "25"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Exceeds original", func(t *testing.T) {
 		mockService := &MockService{
-			GetBalanceByUuidFn: func(ctx context.Context, uuid string) (decimal.Decimal, error) {
-				return decimal.Zero, errors.New("service error")
+			TransactionFn: func(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+				return model.Operation{}, apperror.ErrRefundExceeded
 			},
 		}
 		h := NewHandler(mockService, logger)
 
-		app := fiber.New()
-		app.Get("/wallet/:uuid", h.GetWallet)
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/refund", h.Refund)
+
+		req := httptest.NewRequest(http.MethodPost, "/refund", bytes.NewBufferString(`{"operationId": "op-1"}`))
+		req.Header.Set("Content-Type", "application/json")
 
-		req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid", nil)
 		resp, _ := app.Test(req)
 
-		assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
 
 		var body map[string]string
 		json.NewDecoder(resp.Body).Decode(&body)
-		assert.Contains(t, body["error"], "service error")
+		assert.Equal(t, string(apperror.CodeRefundExceeded), body["code"])
+	})
+}
+
+func TestTransfer(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		var got model.Transfer
+		mockService := &MockService{
+			TransferFn: func(ctx context.Context, transfer model.Transfer) (model.Transfer, error) {
+				got = transfer
+				transfer.Id = "transfer-1"
+				return transfer, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/transfer", h.Transfer)
+
+		reqBody := `{"fromWalletId": "wallet-a", "toWalletId": "wallet-b", "amount": "30"}`
+		req := httptest.NewRequest(http.MethodPost, "/transfer", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "wallet-a", got.FromUuid)
+		assert.Equal(t, "wallet-b", got.ToUuid)
+		assert.True(t, decimal.NewFromInt(30).Equal(got.Amount))
+
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "transfer-1", body["id"])
+		assert.Equal(t, "30", body["amount"])
 	})
+
+	t.Run("Same wallet", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/transfer", h.Transfer)
+
+		reqBody := `{"fromWalletId": "wallet-a", "toWalletId": "wallet-a", "amount": "30"}`
+		req := httptest.NewRequest(http.MethodPost, "/transfer", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Contains(t, body["error"], "must differ")
+	})
+
+	t.Run("Service error", func(t *testing.T) {
+		mockService := &MockService{
+			TransferFn: func(ctx context.Context, transfer model.Transfer) (model.Transfer, error) {
+				return model.Transfer{}, errors.New("service error")
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/transfer", h.Transfer)
+
+		reqBody := `{"fromWalletId": "wallet-a", "toWalletId": "wallet-b", "amount": "30"}`
+		req := httptest.NewRequest(http.MethodPost, "/transfer", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+	})
+}
+
+func TestListWallets(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		mockService := &MockService{
+			ListWalletsFn: func(ctx context.Context, ownerId string) ([]model.Wallet, error) {
+				return []model.Wallet{{Id: "wallet-a", OwnerId: ownerId, Currency: "USD", Status: model.WalletActive}}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallets", h.ListWallets)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallets?ownerId=user-1", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var body struct {
+			Wallets []model.Wallet `json:"wallets"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Len(t, body.Wallets, 1)
+		assert.Equal(t, "user-1", body.Wallets[0].OwnerId)
+	})
+
+	t.Run("Missing owner", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallets", h.ListWallets)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallets", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestGetWallet(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Wallet not found", func(t *testing.T) {
+		mockService := &MockService{
+			GetWalletByUuidFn: func(ctx context.Context, uuid string) (model.Wallet, error) {
+				return model.Wallet{}, apperror.ErrWalletNotFound
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid", h.GetWallet)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "wallet not found", body["error"])
+		assert.Equal(t, string(apperror.CodeWalletNotFound), body["code"])
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockService := &MockService{
+			GetWalletByUuidFn: func(ctx context.Context, uuid string) (model.Wallet, error) {
+				return model.Wallet{Id: uuid, Balance: decimal.NewFromInt(100), Held: decimal.NewFromInt(30), Currency: "USD", Version: 4}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid", h.GetWallet)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"4"`, resp.Header.Get(fiber.HeaderETag))
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "100", body["balance"])
+		assert.Equal(t, "70", body["available"])
+		assert.Equal(t, "100", body["total"])
+		assert.Equal(t, "USD", body["currency"])
+	})
+
+	t.Run("As of", func(t *testing.T) {
+		at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		mockService := &MockService{
+			BalanceAsOfFn: func(ctx context.Context, uuid string, got time.Time) (model.HistoricalBalance, error) {
+				assert.Equal(t, at, got.UTC())
+				return model.HistoricalBalance{WalletId: uuid, Currency: "USD", Balance: decimal.NewFromInt(70), AsOf: got}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid", h.GetWallet)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid?asOf=2025-01-02T03:04:05Z", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "70", body["balance"])
+		assert.Equal(t, "USD", body["currency"])
+		assert.Equal(t, "2025-01-02T03:04:05Z", body["asOf"])
+	})
+
+	t.Run("Invalid as of", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid", h.GetWallet)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid?asOf=yesterday", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Credit line", func(t *testing.T) {
+		mockService := &MockService{
+			GetWalletByUuidFn: func(ctx context.Context, uuid string) (model.Wallet, error) {
+				return model.Wallet{Id: uuid, Balance: decimal.NewFromInt(-40), Held: decimal.NewFromInt(10), Currency: "USD", CreditLimit: decimal.NewFromInt(100)}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid", h.GetWallet)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "-40", body["balance"])
+		assert.Equal(t, "50", body["available"])
+		assert.Equal(t, "100", body["creditLimit"])
+		assert.Equal(t, "50", body["availableCredit"])
+	})
+
+	t.Run("Service error", func(t *testing.T) {
+		mockService := &MockService{
+			GetWalletByUuidFn: func(ctx context.Context, uuid string) (model.Wallet, error) {
+				return model.Wallet{}, errors.New("service error")
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid", h.GetWallet)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "internal server error", body["error"])
+		assert.Equal(t, string(apperror.CodeInternal), body["code"])
+	})
+}
+
+func TestAuthorizeHold(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		var got model.Hold
+		mockService := &MockService{
+			AuthorizeHoldFn: func(ctx context.Context, hold model.Hold) (model.Hold, error) {
+				got = hold
+				hold.Id = "hold-1"
+				hold.Status = model.HoldActive
+				return hold, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet/:uuid/holds", h.AuthorizeHold)
+
+		req := httptest.NewRequest(http.MethodPost, "/wallet/wallet-a/holds", bytes.NewBufferString(`{"amount": "40"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "wallet-a", got.WalletId)
+		assert.True(t, decimal.NewFromInt(40).Equal(got.Amount))
+
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "hold-1", body["id"])
+		assert.Equal(t, model.HoldActive, body["status"])
+	})
+
+	t.Run("Insufficient funds", func(t *testing.T) {
+		mockService := &MockService{
+			AuthorizeHoldFn: func(ctx context.Context, hold model.Hold) (model.Hold, error) {
+				return model.Hold{}, apperror.ErrInsufficientFunds
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet/:uuid/holds", h.AuthorizeHold)
+
+		req := httptest.NewRequest(http.MethodPost, "/wallet/wallet-a/holds", bytes.NewBufferString(`{"amount": "40"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+	})
+}
+
+func TestCaptureHold(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Full capture without body", func(t *testing.T) {
+		var got decimal.Decimal
+		mockService := &MockService{
+			CaptureHoldFn: func(ctx context.Context, id string, amount decimal.Decimal) (model.Hold, error) {
+				got = amount
+				return model.Hold{Id: id, Status: model.HoldCaptured}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/holds/:id/capture", h.CaptureHold)
+
+		req := httptest.NewRequest(http.MethodPost, "/holds/hold-1/capture", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.True(t, got.IsZero())
+	})
+
+	t.Run("Partial capture", func(t *testing.T) {
+		var got decimal.Decimal
+		mockService := &MockService{
+			CaptureHoldFn: func(ctx context.Context, id string, amount decimal.Decimal) (model.Hold, error) {
+				got = amount
+				return model.Hold{Id: id, Status: model.HoldCaptured, CapturedAmount: amount}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/holds/:id/capture", h.CaptureHold)
+
+		req := httptest.NewRequest(http.MethodPost, "/holds/hold-1/capture", bytes.NewBufferString(`{"amount": "12.50"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.True(t, decimal.RequireFromString("12.50").Equal(got))
+	})
+
+	t.Run("Negative amount", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/holds/:id/capture", h.CaptureHold)
+
+		req := httptest.NewRequest(http.MethodPost, "/holds/hold-1/capture", bytes.NewBufferString(`{"amount": "-1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestVoidHold(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Not active", func(t *testing.T) {
+		mockService := &MockService{
+			VoidHoldFn: func(ctx context.Context, id string) (model.Hold, error) {
+				return model.Hold{}, apperror.ErrHoldNotActive
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/holds/:id/void", h.VoidHold)
+
+		req := httptest.NewRequest(http.MethodPost, "/holds/hold-1/void", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, string(apperror.CodeHoldNotActive), body["code"])
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		mockService := &MockService{
+			VoidHoldFn: func(ctx context.Context, id string) (model.Hold, error) {
+				return model.Hold{}, apperror.ErrHoldNotFound
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/holds/:id/void", h.VoidHold)
+
+		req := httptest.NewRequest(http.MethodPost, "/holds/hold-1/void", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestChangeWalletStatus(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Freeze", func(t *testing.T) {
+		var got model.StatusChange
+		mockService := &MockService{
+			ChangeStatusFn: func(ctx context.Context, change model.StatusChange) (model.StatusChange, error) {
+				got = change
+				change.Id = "change-1"
+				change.FromStatus = model.WalletActive
+				return change, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/admin/wallets/:uuid/freeze", h.FreezeWallet)
+
+		reqBody := `{"reason": "fraud review", "actor": "compliance", "allowDeposits": true}`
+		req := httptest.NewRequest(http.MethodPost, "/admin/wallets/wallet-a/freeze", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, model.StatusChange{WalletId: "wallet-a", Status: model.WalletFrozen, Reason: "fraud review", Actor: "compliance", AllowDeposits: true}, got)
+
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "change-1", body["id"])
+		assert.Equal(t, model.WalletActive, body["fromStatus"])
+	})
+
+	t.Run("Missing actor", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/admin/wallets/:uuid/close", h.CloseWallet)
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/wallets/wallet-a/close", bytes.NewBufferString(`{"reason": "customer request"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Close with balance", func(t *testing.T) {
+		mockService := &MockService{
+			ChangeStatusFn: func(ctx context.Context, change model.StatusChange) (model.StatusChange, error) {
+				return model.StatusChange{}, apperror.ErrBalanceNotZero
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/admin/wallets/:uuid/close", h.CloseWallet)
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/wallets/wallet-a/close", bytes.NewBufferString(`{"reason": "customer request", "actor": "support"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, string(apperror.CodeBalanceNotZero), body["code"])
+	})
+}
+
+func TestLimitPolicy(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Upsert", func(t *testing.T) {
+		var got model.LimitPolicy
+		mockService := &MockService{
+			UpsertPolicyFn: func(ctx context.Context, policy model.LimitPolicy) (model.LimitPolicy, error) {
+				got = policy
+				return policy, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Put("/admin/limit-policies/:name", h.UpsertLimitPolicy)
+
+		reqBody := `{"currency": "usd", "maxBalance": "1000", "dailyWithdrawal": "300"}`
+		req := httptest.NewRequest(http.MethodPut, "/admin/limit-policies/basic", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "basic", got.Name)
+		assert.Equal(t, "USD", got.Currency)
+		assert.True(t, got.MaxBalance.Decimal.Equal(decimal.NewFromInt(1000)))
+		assert.False(t, got.MaxSingleAmount.Valid)
+	})
+
+	t.Run("Non-positive limit", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Put("/admin/limit-policies/:name", h.UpsertLimitPolicy)
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/limit-policies/basic", bytes.NewBufferString(`{"currency": "USD", "maxSingleAmount": "0"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Assign unknown policy", func(t *testing.T) {
+		mockService := &MockService{
+			SetPolicyFn: func(ctx context.Context, walletId, name string) (model.Wallet, error) {
+				return model.Wallet{}, apperror.ErrLimitPolicyNotFound
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Put("/admin/wallets/:uuid/limit-policy", h.SetWalletLimitPolicy)
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/wallets/wallet-a/limit-policy", bytes.NewBufferString(`{"policy": "missing"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Limit exceeded", func(t *testing.T) {
+		mockService := &MockService{
+			TransactionFn: func(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+				return model.Operation{}, apperror.New(apperror.CodeDailyWithdrawalExceeded, "daily withdrawal limit of 300.00 USD exceeded")
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet", h.Transaction)
+
+		req := httptest.NewRequest(http.MethodPost, "/wallet", bytes.NewBufferString(`{"valletId": "wallet-a", "operationType": "WITHDRAW", "amount": "100"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+
+		var body map[string]string
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, string(apperror.CodeDailyWithdrawalExceeded), body["code"])
+	})
+}
+
+func TestSetWalletCreditLimit(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		var got decimal.Decimal
+		mockService := &MockService{
+			SetCreditLimitFn: func(ctx context.Context, walletId string, limit decimal.Decimal) (model.Wallet, error) {
+				got = limit
+				return model.Wallet{Id: walletId, Currency: "USD", CreditLimit: limit}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Put("/admin/wallets/:uuid/credit-limit", h.SetWalletCreditLimit)
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/wallets/wallet-a/credit-limit", bytes.NewBufferString(`{"creditLimit": "500"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.True(t, got.Equal(decimal.NewFromInt(500)))
+	})
+
+	t.Run("Negative limit", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Put("/admin/wallets/:uuid/credit-limit", h.SetWalletCreditLimit)
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/wallets/wallet-a/credit-limit", bytes.NewBufferString(`{"creditLimit": "-1"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Credit in use", func(t *testing.T) {
+		mockService := &MockService{
+			SetCreditLimitFn: func(ctx context.Context, walletId string, limit decimal.Decimal) (model.Wallet, error) {
+				return model.Wallet{}, apperror.New(apperror.CodeCreditLimitInUse, "credit limit cannot be lower than the 60.00 USD in use")
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Put("/admin/wallets/:uuid/credit-limit", h.SetWalletCreditLimit)
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/wallets/wallet-a/credit-limit", bytes.NewBufferString(`{"creditLimit": "50"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Create", func(t *testing.T) {
+		var got model.WebhookSubscription
+		mockService := &MockService{
+			CreateWebhookFn: func(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+				got = sub
+				sub.Id = "sub-1"
+				sub.Secret = "generated"
+				return sub, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/webhooks", h.CreateWebhook)
+
+		reqBody := `{"url": "https://partner.example/hooks", "eventTypes": ["operation.withdraw", "wallet.low_balance"], "lowBalanceThreshold": "10", "lowBalanceCurrency": "USD"}`
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, []string{model.WebhookWithdraw, model.WebhookLowBalance}, got.EventTypes)
+		assert.Equal(t, "USD", got.LowBalanceCurrency)
+		assert.Empty(t, got.Secret)
+
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "sub-1", body["id"])
+		assert.Equal(t, "generated", body["secret"])
+	})
+
+	t.Run("Low balance without threshold", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/webhooks", h.CreateWebhook)
+
+		reqBody := `{"url": "https://partner.example/hooks", "eventTypes": ["wallet.low_balance"]}`
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Threshold without currency", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/webhooks", h.CreateWebhook)
+
+		reqBody := `{"url": "https://partner.example/hooks", "eventTypes": ["wallet.low_balance"], "lowBalanceThreshold": "10"}`
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Invalid url", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/webhooks", h.CreateWebhook)
+
+		for _, url := range []string{
+			"ftp://partner.example",
+			"http://localhost:8080/hooks",
+			"http://api.localhost/hooks",
+			"http://127.0.0.1/hooks",
+			"http://10.0.0.5/hooks",
+			"http://169.254.169.254/latest/meta-data",
+			"http://[::1]/hooks",
+			"http://[::ffff:192.168.1.1]/hooks",
+			"http://100.64.0.1/hooks",
+		} {
+			reqBody := `{"url": "` + url + `", "eventTypes": ["operation.deposit"]}`
+			req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, url)
+		}
+	})
+
+	t.Run("Dead deliveries", func(t *testing.T) {
+		var gotStatus string
+		mockService := &MockService{
+			ListDeliveriesFn: func(ctx context.Context, subscriptionId, status string) ([]model.WebhookDelivery, error) {
+				gotStatus = status
+				return []model.WebhookDelivery{{Id: "delivery-1", SubscriptionId: subscriptionId, Status: model.DeliveryDead}}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/webhooks/:id/deliveries", h.ListWebhookDeliveries)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/webhooks/sub-1/deliveries?status=dead", nil))
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, model.DeliveryDead, gotStatus)
+	})
+
+	t.Run("Redeliver unknown delivery", func(t *testing.T) {
+		mockService := &MockService{
+			RedeliverFn: func(ctx context.Context, id string) (model.WebhookDelivery, error) {
+				return model.WebhookDelivery{}, apperror.ErrDeliveryNotFound
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/webhooks/deliveries/:id/redeliver", h.RedeliverWebhook)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodPost, "/webhooks/deliveries/missing/redeliver", nil))
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		mockService := &MockService{
+			DeleteWebhookFn: func(ctx context.Context, id string) error {
+				return nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Delete("/webhooks/:id", h.DeleteWebhook)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodDelete, "/webhooks/sub-1", nil))
+
+		assert.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+	})
+}
+
+func TestBatch(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Best effort", func(t *testing.T) {
+		var got []model.Transaction
+		mockService := &MockService{
+			BatchFn: func(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) ([]model.BatchItemResult, error) {
+				got = transactions
+				assert.False(t, opts.Atomic)
+				return []model.BatchItemResult{
+					{Operation: model.Operation{Id: "op-1", BalanceAfter: decimal.NewFromInt(100), Currency: "USD"}},
+					{Err: apperror.ErrInsufficientFunds},
+				}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet/batch", h.Batch)
+
+		reqBody := `{"mode": "best_effort", "items": [
+			{"valletId": "wallet-a", "operationType": "DEPOSIT", "amount": "100"},
+			{"valletId": "wallet-b", "operationType": "DEPOSIT", "amount": "abc"},
+			{"valletId": "wallet-c", "operationType": "WITHDRAW", "amount": "50"}
+		]}`
+		req := httptest.NewRequest(http.MethodPost, "/wallet/batch", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "payroll-7")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Len(t, got, 2)
+		assert.Equal(t, "payroll-7/0", got[0].IdempotencyKey)
+		assert.Equal(t, "payroll-7/2", got[1].IdempotencyKey)
+
+		var body struct {
+			Succeeded int                 `json:"succeeded"`
+			Failed    int                 `json:"failed"`
+			Items     []batchItemResponse `json:"items"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, 1, body.Succeeded)
+		assert.Equal(t, 2, body.Failed)
+		assert.Equal(t, "op-1", body.Items[0].TransactionId)
+		assert.Equal(t, apperror.CodeValidation, body.Items[1].Code)
+		assert.Equal(t, apperror.CodeInsufficientFunds, body.Items[2].Code)
+	})
+
+	t.Run("Atomic failure", func(t *testing.T) {
+		mockService := &MockService{
+			BatchFn: func(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) ([]model.BatchItemResult, error) {
+				aborted := apperror.New(apperror.CodeBatchAborted, "not applied because item 1 failed")
+				return []model.BatchItemResult{{Err: aborted}, {Err: apperror.ErrInsufficientFunds}}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet/batch", h.Batch)
+
+		reqBody := `{"mode": "ATOMIC", "items": [
+			{"valletId": "wallet-a", "operationType": "DEPOSIT", "amount": "100"},
+			{"valletId": "wallet-b", "operationType": "WITHDRAW", "amount": "50"}
+		]}`
+		req := httptest.NewRequest(http.MethodPost, "/wallet/batch", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, string(apperror.CodeInsufficientFunds), body["code"])
+	})
+
+	t.Run("Atomic with an invalid item", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet/batch", h.Batch)
+
+		reqBody := `{"mode": "ATOMIC", "items": [{"valletId": "wallet-a", "operationType": "INVALID", "amount": "100"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/wallet/batch", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Idempotency key at the maximum length", func(t *testing.T) {
+		var got []model.Transaction
+		mockService := &MockService{
+			BatchFn: func(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) ([]model.BatchItemResult, error) {
+				got = transactions
+				results := make([]model.BatchItemResult, len(transactions))
+				for i := range results {
+					results[i] = model.BatchItemResult{Operation: model.Operation{Id: "op", BalanceAfter: decimal.NewFromInt(100)}}
+				}
+				return results, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet/batch", h.Batch)
+
+		items := strings.TrimSuffix(strings.Repeat(`{"valletId": "wallet-a", "operationType": "DEPOSIT", "amount": "1"},`, 11), ",")
+		reqBody := `{"mode": "ATOMIC", "items": [` + items + `]}`
+		send := func(key string) *http.Response {
+			req := httptest.NewRequest(http.MethodPost, "/wallet/batch", bytes.NewBufferString(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(IdempotencyKeyHeader, key)
+			resp, _ := app.Test(req)
+			return resp
+		}
+
+		// The last of 11 items is keyed "<key>/10", three characters longer.
+		longest := strings.Repeat("k", model.MaxIdempotencyKeyLength-3)
+		resp := send(longest)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Len(t, got, 11)
+		assert.Len(t, got[10].IdempotencyKey, model.MaxIdempotencyKeyLength)
+
+		got = nil
+		resp = send(longest + "k")
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		assert.Nil(t, got)
+	})
+
+	t.Run("Unknown mode", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet/batch", h.Batch)
+
+		req := httptest.NewRequest(http.MethodPost, "/wallet/batch", bytes.NewBufferString(`{"mode": "SOMETIMES", "items": []}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+// failingWriter is a stream whose client has disconnected.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
+
+func TestExportOperations(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		var got model.OperationFilter
+		mockService := &MockService{
+			ExportFn: func(ctx context.Context, filter model.OperationFilter, fn func([]model.Operation) error) error {
+				got = filter
+				return fn([]model.Operation{
+					{Id: "op-2", WalletId: "wallet-b", OperationType: "WITHDRAW", Amount: decimal.NewFromInt(5), Currency: "EUR", BalanceAfter: decimal.NewFromInt(15), CreatedAt: createdAt},
+					{Id: "op-1", WalletId: "wallet-a", OperationType: "DEPOSIT", Amount: decimal.NewFromInt(100), Currency: "USD", BalanceAfter: decimal.NewFromInt(100), CreatedAt: createdAt},
+				})
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/operations/export", h.ExportOperations)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/operations/export?from=2025-01-01T00:00:00Z&to=2025-02-01T00:00:00Z", nil))
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Empty(t, got.WalletId)
+		assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), got.From)
+
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "id,wallet_id,operation_type,amount,currency,balance_after,transfer_id,reference_id,created_at\n"+
+			"op-2,wallet-b,WITHDRAW,5,EUR,15,,,2025-01-02T03:04:05Z\n"+
+			"op-1,wallet-a,DEPOSIT,100,USD,100,,,2025-01-02T03:04:05Z\n", string(body))
+	})
+
+	t.Run("Client gone", func(t *testing.T) {
+		called := false
+		mockService := &MockService{
+			ExportFn: func(ctx context.Context, filter model.OperationFilter, fn func([]model.Operation) error) error {
+				called = true
+				return nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		w := bufio.NewWriter(failingWriter{})
+		err := h.writeOperations(context.Background(), w, model.OperationFilter{})
+
+		assert.Error(t, err)
+		assert.False(t, called)
+	})
+
+	t.Run("Wallet not found", func(t *testing.T) {
+		mockService := &MockService{
+			GetWalletByUuidFn: func(ctx context.Context, uuid string) (model.Wallet, error) {
+				return model.Wallet{}, apperror.ErrWalletNotFound
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/operations/export", h.ExportOperations)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/operations/export?walletId=missing", nil))
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Invalid range", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/operations/export", h.ExportOperations)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/operations/export?from=2025-02-01T00:00:00Z&to=2025-01-01T00:00:00Z", nil))
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestGetStatement(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	statement := model.NewStatement(model.Wallet{Id: "wallet-a", Currency: "USD"}, from, to, decimal.NewFromInt(100), []model.StatementLine{
+		{Operation: model.Operation{Id: "op-1", OperationType: "DEPOSIT", Amount: decimal.NewFromInt(50), CreatedAt: createdAt}, Change: decimal.NewFromInt(50)},
+		{Operation: model.Operation{Id: "op-2", OperationType: "WITHDRAW", Amount: decimal.NewFromInt(30), CreatedAt: createdAt}, Change: decimal.NewFromInt(-30)},
+	})
+
+	newApp := func(got *[2]time.Time) *fiber.App {
+		mockService := &MockService{
+			GetStatementFn: func(ctx context.Context, walletId string, from, to time.Time) (model.Statement, error) {
+				if walletId != "wallet-a" {
+					return model.Statement{}, apperror.ErrWalletNotFound
+				}
+				*got = [2]time.Time{from, to}
+				return statement, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid/statement", h.GetStatement)
+		return app
+	}
+
+	t.Run("JSON for a month", func(t *testing.T) {
+		var got [2]time.Time
+		resp, _ := newApp(&got).Test(httptest.NewRequest(http.MethodGet, "/wallet/wallet-a/statement?month=2025-01", nil))
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, [2]time.Time{from, to}, got)
+
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "100", body["openingBalance"])
+		assert.Equal(t, "120", body["closingBalance"])
+		assert.Equal(t, map[string]any{"DEPOSIT": "50", "WITHDRAW": "30"}, body["totals"])
+		operations := body["operations"].([]any)
+		assert.Equal(t, "150", operations[0].(map[string]any)["runningBalance"])
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		var got [2]time.Time
+		resp, _ := newApp(&got).Test(httptest.NewRequest(http.MethodGet, "/wallet/wallet-a/statement?from=2025-01-01T00:00:00Z&to=2025-02-01T00:00:00Z&format=csv", nil))
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, "date,operation_id,operation_type,amount,change,balance\n"+
+			"2025-01-01T00:00:00Z,,OPENING_BALANCE,,,100\n"+
+			"2025-01-02T03:04:05Z,op-1,DEPOSIT,50,50,150\n"+
+			"2025-01-02T03:04:05Z,op-2,WITHDRAW,30,-30,120\n"+
+			"2025-02-01T00:00:00Z,,CLOSING_BALANCE,,,120\n", string(body))
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		var got [2]time.Time
+		resp, _ := newApp(&got).Test(httptest.NewRequest(http.MethodGet, "/wallet/wallet-a/statement?month=2025-01&format=text", nil))
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), "Opening balance: 100\n")
+		assert.Contains(t, string(body), "Closing balance: 120\n")
+	})
+
+	t.Run("Invalid period", func(t *testing.T) {
+		var got [2]time.Time
+		app := newApp(&got)
+
+		for _, query := range []string{"", "?month=January", "?from=2025-02-01T00:00:00Z&to=2025-01-01T00:00:00Z", "?from=2020-01-01T00:00:00Z&to=2025-01-01T00:00:00Z", "?month=2025-01&format=pdf"} {
+			resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/wallet/wallet-a/statement"+query, nil))
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, query)
+		}
+	})
+
+	t.Run("Wallet not found", func(t *testing.T) {
+		var got [2]time.Time
+		resp, _ := newApp(&got).Test(httptest.NewRequest(http.MethodGet, "/wallet/missing/statement?month=2025-01", nil))
+
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestImportOperations(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	type importBody struct {
+		DryRun  bool              `json:"dryRun"`
+		Applied bool              `json:"applied"`
+		Rows    int               `json:"rows"`
+		Errors  []importLineError `json:"errors"`
+		Code    apperror.Code     `json:"code"`
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		var got []model.Transaction
+		mockService := &MockService{
+			BatchFn: func(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) ([]model.BatchItemResult, error) {
+				got = transactions
+				assert.Equal(t, model.BatchOptions{Atomic: true}, opts)
+				return make([]model.BatchItemResult, len(transactions)), nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/operations/import", h.ImportOperations)
+
+		csv := "wallet_id,operation_type,amount,currency\nwallet-a,deposit,100\nwallet-b,WITHDRAW,2.50,eur\n"
+		req := httptest.NewRequest(http.MethodPost, "/operations/import", bytes.NewBufferString(csv))
+		req.Header.Set(IdempotencyKeyHeader, "import-1")
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Len(t, got, 2)
+		assert.Equal(t, model.TransactionDeposit, got[0].OperationType)
+		assert.Equal(t, "import-1/2", got[0].IdempotencyKey)
+		assert.Equal(t, "EUR", got[1].Currency)
+
+		var body importBody
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.True(t, body.Applied)
+		assert.Equal(t, 2, body.Rows)
+		assert.Empty(t, body.Errors)
+	})
+
+	t.Run("Idempotency key too long for the last line", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/operations/import", h.ImportOperations)
+
+		// The row on line 10 is keyed "<key>/10", three characters longer.
+		csv := strings.Repeat("wallet-a,DEPOSIT,1\n", 10)
+		req := httptest.NewRequest(http.MethodPost, "/operations/import", bytes.NewBufferString(csv))
+		req.Header.Set(IdempotencyKeyHeader, strings.Repeat("k", model.MaxIdempotencyKeyLength-2))
+
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Invalid lines reject the import", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/operations/import", h.ImportOperations)
+
+		csv := "wallet-a,DEPOSIT,100\nwallet-b,DEPOSIT,-5\nwallet-c,DEPOSIT\n"
+		resp, _ := app.Test(httptest.NewRequest(http.MethodPost, "/operations/import", bytes.NewBufferString(csv)))
+
+		assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+
+		var body importBody
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.False(t, body.Applied)
+		assert.Equal(t, apperror.CodeImportRejected, body.Code)
+		assert.Equal(t, 3, body.Rows)
+		assert.Equal(t, []importLineError{
+			{Line: 2, Error: "amount must be positive", Code: apperror.CodeValidation},
+			{Line: 3, Error: "expected 3 or 4 columns, got 2", Code: apperror.CodeValidation},
+		}, body.Errors)
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		mockService := &MockService{
+			BatchFn: func(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) ([]model.BatchItemResult, error) {
+				assert.Equal(t, model.BatchOptions{DryRun: true}, opts)
+				assert.Len(t, transactions, 2)
+				return []model.BatchItemResult{{}, {Err: apperror.ErrInsufficientFunds}}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/operations/import", h.ImportOperations)
+
+		csv := "wallet-a,DEPOSIT,100\nwallet-b,REFUND,5\nwallet-c,WITHDRAW,50\n"
+		resp, _ := app.Test(httptest.NewRequest(http.MethodPost, "/operations/import?dryRun=true", bytes.NewBufferString(csv)))
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var body importBody
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.True(t, body.DryRun)
+		assert.False(t, body.Applied)
+		assert.Len(t, body.Errors, 2)
+		assert.Equal(t, 2, body.Errors[0].Line)
+		assert.Equal(t, apperror.CodeValidation, body.Errors[0].Code)
+		assert.Equal(t, 3, body.Errors[1].Line)
+		assert.Equal(t, apperror.CodeInsufficientFunds, body.Errors[1].Code)
+	})
+
+	t.Run("Empty file", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/operations/import", h.ImportOperations)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodPost, "/operations/import", bytes.NewBufferString("wallet_id,operation_type,amount\n")))
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestReconciliation(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Run without fixing", func(t *testing.T) {
+		var gotFix []bool
+		mockService := &MockService{
+			ReconcileFn: func(ctx context.Context, fix bool) (model.ReconciliationRun, error) {
+				gotFix = append(gotFix, fix)
+				return model.ReconciliationRun{Id: 7, WalletsChecked: 3, MismatchCount: 1, Fix: fix}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/reconciliations", h.RunReconciliation)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodPost, "/reconciliations", nil))
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+
+		resp, _ = app.Test(httptest.NewRequest(http.MethodPost, "/reconciliations?fix=true", nil))
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, []bool{false, true}, gotFix)
+	})
+
+	t.Run("Get run", func(t *testing.T) {
+		mockService := &MockService{
+			GetRunFn: func(ctx context.Context, id int64) (model.ReconciliationRun, error) {
+				if id != 7 {
+					return model.ReconciliationRun{}, apperror.ErrRunNotFound
+				}
+				return model.ReconciliationRun{Id: 7, MismatchCount: 1, Mismatches: []model.BalanceMismatch{
+					{WalletId: "wallet-a", StoredBalance: decimal.NewFromInt(110), LedgerBalance: decimal.NewFromInt(100), Difference: decimal.NewFromInt(10)},
+				}}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/reconciliations/:id", h.GetReconciliationRun)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/reconciliations/7", nil))
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		var body model.ReconciliationRun
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Len(t, body.Mismatches, 1)
+		assert.Equal(t, "10", body.Mismatches[0].Difference.String())
+
+		for _, id := range []string{"8", "latest"} {
+			resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/reconciliations/"+id, nil))
+			assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+		}
+	})
+}
+
+func TestTrialBalance(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	mockService := &MockService{
+		TrialBalanceFn: func(ctx context.Context) (model.TrialBalance, error) {
+			return model.TrialBalance{Balanced: true, Currencies: []model.CurrencyBalance{{
+				Currency: "USD",
+				Accounts: map[string]decimal.Decimal{
+					model.AccountWallets: decimal.NewFromInt(70),
+					model.AccountCashIn:  decimal.NewFromInt(-100),
+					model.AccountCashOut: decimal.NewFromInt(30),
+				},
+			}}}, nil
+		},
+	}
+	h := NewHandler(mockService, logger)
+
+	app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+	app.Get("/trial-balance", h.TrialBalance)
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/trial-balance", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body model.TrialBalance
+	json.NewDecoder(resp.Body).Decode(&body)
+	assert.True(t, body.Balanced)
+	assert.Len(t, body.Currencies, 1)
+	assert.Equal(t, "-100", body.Currencies[0].Accounts[model.AccountCashIn].String())
+}
+
+func TestFeeRules(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Upsert", func(t *testing.T) {
+		var got model.FeeRule
+		mockService := &MockService{
+			UpsertFeeRuleFn: func(ctx context.Context, rule model.FeeRule) (model.FeeRule, error) {
+				got = rule
+				return rule, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Put("/fee-rules/:operationType/:currency", h.UpsertFeeRule)
+
+		req := httptest.NewRequest(http.MethodPut, "/fee-rules/withdraw/usd", bytes.NewBufferString(`{"flat": "0.5", "percent": "1", "max": "10"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, model.TransactionWithdraw, got.OperationType)
+		assert.Equal(t, "USD", got.Currency)
+		assert.Equal(t, "0.5", got.Flat.String())
+		assert.Equal(t, "10", got.Max.Decimal.String())
+	})
+
+	t.Run("Invalid rule", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Put("/fee-rules/:operationType/:currency", h.UpsertFeeRule)
+
+		for path, body := range map[string]string{
+			"/fee-rules/DEPOSIT/USD":  `{"flat": "1"}`,
+			"/fee-rules/WITHDRAW/USD": `{"percent": "101"}`,
+			"/fee-rules/TRANSFER/USD": `{"min": "5", "max": "1"}`,
+		} {
+			req := httptest.NewRequest(http.MethodPut, path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, path)
+		}
+	})
+
+	t.Run("Delete missing rule", func(t *testing.T) {
+		mockService := &MockService{
+			DeleteFeeRuleFn: func(ctx context.Context, operationType, currency string) error {
+				return apperror.ErrFeeRuleNotFound
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Delete("/fee-rules/:operationType/:currency", h.DeleteFeeRule)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodDelete, "/fee-rules/TRANSFER/EUR", nil))
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestQuoteFee(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	mockService := &MockService{
+		QuoteFeeFn: func(ctx context.Context, quote model.FeeQuote) (model.FeeQuote, error) {
+			quote.Currency = "USD"
+			quote.Fee = decimal.NewFromInt(2)
+			quote.Total = quote.Amount.Add(quote.Fee)
+			return quote, nil
+		},
+	}
+	h := NewHandler(mockService, logger)
+
+	app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+	app.Get("/wallet/:uuid/fee-quote", h.QuoteFee)
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/wallet/wallet-a/fee-quote?operationType=transfer&amount=100", nil))
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body model.FeeQuote
+	json.NewDecoder(resp.Body).Decode(&body)
+	assert.Equal(t, "wallet-a", body.WalletId)
+	assert.Equal(t, model.FeeTransfer, body.OperationType)
+	assert.Equal(t, "102", body.Total.String())
+
+	for _, query := range []string{"operationType=DEPOSIT&amount=100", "operationType=WITHDRAW&amount=abc", "operationType=WITHDRAW&amount=-1"} {
+		resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/wallet/wallet-a/fee-quote?"+query, nil))
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Create recurring", func(t *testing.T) {
+		var got model.Schedule
+		mockService := &MockService{
+			CreateScheduleFn: func(ctx context.Context, schedule model.Schedule) (model.Schedule, error) {
+				got = schedule
+				schedule.Id = "schedule-1"
+				schedule.Status = model.ScheduleActive
+				return schedule, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet/:uuid/schedules", h.CreateSchedule)
+
+		req := httptest.NewRequest(http.MethodPost, "/wallet/wallet-a/schedules", bytes.NewBufferString(`{"operationType": "WITHDRAW", "amount": "9.99", "cron": "0 9 1 * *"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "wallet-a", got.WalletId)
+		assert.Equal(t, model.TransactionWithdraw, got.OperationType)
+		assert.Equal(t, "9.99", got.Amount.String())
+		assert.Equal(t, "0 9 1 * *", got.Cron)
+		assert.Nil(t, got.NextRunAt)
+
+		var body model.Schedule
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "schedule-1", body.Id)
+	})
+
+	t.Run("Create one-off", func(t *testing.T) {
+		var got model.Schedule
+		mockService := &MockService{
+			CreateScheduleFn: func(ctx context.Context, schedule model.Schedule) (model.Schedule, error) {
+				got = schedule
+				return schedule, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet/:uuid/schedules", h.CreateSchedule)
+
+		runAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+		body := fmt.Sprintf(`{"operationType": "DEPOSIT", "amount": "100", "runAt": %q}`, runAt.Format(time.RFC3339))
+		req := httptest.NewRequest(http.MethodPost, "/wallet/wallet-a/schedules", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		if assert.NotNil(t, got.NextRunAt) {
+			assert.True(t, runAt.Equal(*got.NextRunAt))
+		}
+		assert.Empty(t, got.Cron)
+	})
+
+	t.Run("Invalid schedule", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet/:uuid/schedules", h.CreateSchedule)
+
+		past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+		future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		for _, body := range []string{
+			`{"operationType": "REFUND", "amount": "1", "cron": "@daily"}`,
+			`{"operationType": "deposit", "amount": "1", "cron": "@daily"}`,
+			`{"operationType": "DEPOSIT", "amount": "0", "cron": "@daily"}`,
+			`{"operationType": "DEPOSIT", "amount": "abc", "cron": "@daily"}`,
+			`{"operationType": "DEPOSIT", "amount": "1"}`,
+			`{"operationType": "DEPOSIT", "amount": "1", "cron": "@daily", "runAt": "` + future + `"}`,
+			`{"operationType": "DEPOSIT", "amount": "1", "runAt": "` + past + `"}`,
+			`{"operationType": "DEPOSIT", "amount": "1", "cron": "0 25 * * *"}`,
+			`{"operationType": "DEPOSIT", "amount": "1", "cron": "0 0 30 2 *"}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/wallet/wallet-a/schedules", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, body)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		var gotStatus string
+		mockService := &MockService{
+			ListSchedulesFn: func(ctx context.Context, walletId, status string) ([]model.Schedule, error) {
+				gotStatus = status
+				return []model.Schedule{{Id: "schedule-1", WalletId: walletId}}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid/schedules", h.ListSchedules)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/wallet/wallet-a/schedules?status=failed", nil))
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, model.ScheduleFailed, gotStatus)
+
+		var body struct {
+			Schedules []model.Schedule `json:"schedules"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Len(t, body.Schedules, 1)
+
+		resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/wallet/wallet-a/schedules?status=paused", nil))
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		mockService := &MockService{
+			CancelScheduleFn: func(ctx context.Context, id string) (model.Schedule, error) {
+				if id == "done" {
+					return model.Schedule{}, apperror.ErrScheduleNotActive
+				}
+				return model.Schedule{Id: id, Status: model.ScheduleCancelled}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Post("/wallet/schedules/:id/cancel", h.CancelSchedule)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodPost, "/wallet/schedules/schedule-1/cancel", nil))
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+		resp, _ = app.Test(httptest.NewRequest(http.MethodPost, "/wallet/schedules/done/cancel", nil))
+		assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("Get missing", func(t *testing.T) {
+		mockService := &MockService{
+			GetScheduleFn: func(ctx context.Context, id string) (model.Schedule, error) {
+				return model.Schedule{}, apperror.ErrScheduleNotFound
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/schedules/:id", h.GetSchedule)
+
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/wallet/schedules/missing", nil))
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestListTransactions(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	t.Run("Success", func(t *testing.T) {
+		createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		var got model.OperationFilter
+		mockService := &MockService{
+			ListOperationsFn: func(ctx context.Context, filter model.OperationFilter) (model.OperationPage, error) {
+				got = filter
+				return model.OperationPage{
+					Operations: []model.Operation{{
+						Id:            "op-1",
+						WalletId:      "test-uuid",
+						OperationType: model.TransactionDeposit,
+						Amount:        decimal.RequireFromString("10.50"),
+						BalanceAfter:  decimal.RequireFromString("110.50"),
+						CreatedAt:     createdAt,
+					}},
+					NextCursor: "next",
+				}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid/transactions", h.ListTransactions)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid/transactions?limit=5&operationType=DEPOSIT&from=2025-01-01T00:00:00Z", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "test-uuid", got.WalletId)
+		assert.Equal(t, 5, got.Limit)
+		assert.Equal(t, model.TransactionDeposit, got.OperationType)
+		assert.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), got.From)
+		assert.True(t, got.To.IsZero())
+
+		var body struct {
+			Transactions []map[string]any `json:"transactions"`
+			NextCursor   string           `json:"nextCursor"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		assert.Equal(t, "next", body.NextCursor)
+		assert.Len(t, body.Transactions, 1)
+		assert.Equal(t, "10.5", body.Transactions[0]["amount"])
+		assert.Equal(t, "110.5", body.Transactions[0]["balanceAfter"])
+	})
+
+	t.Run("Cursor is decoded", func(t *testing.T) {
+		cursor := model.OperationCursor{CreatedAt: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), Id: "op-1"}
+		var got model.OperationFilter
+		mockService := &MockService{
+			ListOperationsFn: func(ctx context.Context, filter model.OperationFilter) (model.OperationPage, error) {
+				got = filter
+				return model.OperationPage{Operations: []model.Operation{}}, nil
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid/transactions", h.ListTransactions)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid/transactions?cursor="+cursor.Encode(), nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, model.DefaultOperationsLimit, got.Limit)
+		assert.Equal(t, &cursor, got.Cursor)
+	})
+
+	t.Run("Invalid query", func(t *testing.T) {
+		h := NewHandler(&MockService{}, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid/transactions", h.ListTransactions)
+
+		for _, query := range []string{"limit=abc", "limit=0", "limit=1000", "operationType=INVALID", "from=yesterday", "cursor=%21%21", "from=2025-02-01T00:00:00Z&to=2025-01-01T00:00:00Z"} {
+			req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid/transactions?"+query, nil)
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, query)
+		}
+	})
+
+	t.Run("Service error", func(t *testing.T) {
+		mockService := &MockService{
+			ListOperationsFn: func(ctx context.Context, filter model.OperationFilter) (model.OperationPage, error) {
+				return model.OperationPage{}, errors.New("service error")
+			},
+		}
+		h := NewHandler(mockService, logger)
+
+		app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+		app.Get("/wallet/:uuid/transactions", h.ListTransactions)
+
+		req := httptest.NewRequest(http.MethodGet, "/wallet/test-uuid/transactions", nil)
+		resp, _ := app.Test(req)
+
+		assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+	})
+}
+
+func TestErrorHandler(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	h := NewHandler(&MockService{}, logger)
+
+	app := fiber.New(fiber.Config{ErrorHandler: h.ErrorHandler})
+	app.Get("/wallet/:uuid", h.GetWallet)
+
+	req := httptest.NewRequest(http.MethodGet, "/unknown", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	assert.Equal(t, "NOT_FOUND", body["code"])
 }
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"log/slog"
+	"strings"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
+)
+
+// CreateSchedule schedules a deposit or withdrawal on the wallet, either once
+// at runAt or every time cron fires, e.g. {"cron": "0 9 1 * *"} for 09:00 UTC
+// on the 1st of every month.
+func (h *Handler) CreateSchedule(c *fiber.Ctx) error {
+	ctx := c.Context()
+	var req model.ScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		h.logger.Error("failed to parse request", slog.Any("error", err))
+		return apperror.ErrInvalidRequest
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		h.logger.Warn("invalid amount format", slog.Any("error", err))
+		return apperror.New(apperror.CodeValidation, "invalid amount format")
+	}
+
+	schedule := model.Schedule{
+		WalletId:      c.Params("uuid"),
+		OperationType: req.OperationType,
+		Amount:        amount,
+		Currency:      strings.ToUpper(req.Currency),
+		Cron:          strings.TrimSpace(req.Cron),
+		NextRunAt:     req.RunAt,
+	}
+
+	if err := model.ValidateSchedule(schedule); err != nil {
+		h.logger.Warn("validation failed", slog.Any("error", err))
+		return err
+	}
+
+	result, err := h.service.CreateSchedule(ctx, schedule)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
+func (h *Handler) ListSchedules(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	status := strings.ToUpper(c.Query("status"))
+	if status != "" && !model.IsScheduleStatus(status) {
+		return apperror.Errorf(apperror.CodeValidation, "invalid schedule status: %s", status)
+	}
+
+	schedules, err := h.service.ListSchedules(ctx, c.Params("uuid"), status)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"schedules": schedules})
+}
+
+func (h *Handler) GetSchedule(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	schedule, err := h.service.GetSchedule(ctx, c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(schedule)
+}
+
+func (h *Handler) CancelSchedule(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	schedule, err := h.service.CancelSchedule(ctx, c.Params("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(schedule)
+}
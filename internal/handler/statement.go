@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Statement renderings selected with ?format=.
+const (
+	statementJSON = "json"
+	statementCSV  = "csv"
+	statementText = "text"
+)
+
+// GetStatement returns the statement of a wallet for ?month=YYYY-MM or for
+// ?from=...&to=..., as JSON, CSV or plain text.
+func (h *Handler) GetStatement(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	format := strings.ToLower(c.Query("format", statementJSON))
+	if format != statementJSON && format != statementCSV && format != statementText {
+		return apperror.Errorf(apperror.CodeValidation, "format must be %s, %s or %s", statementJSON, statementCSV, statementText)
+	}
+
+	var from, to time.Time
+	var err error
+	if month := c.Query("month"); month != "" {
+		if from, to, err = model.StatementMonth(month); err != nil {
+			return err
+		}
+	} else {
+		if from, err = parseTimeQuery(c, "from"); err != nil {
+			return err
+		}
+		if to, err = parseTimeQuery(c, "to"); err != nil {
+			return err
+		}
+	}
+	if err := model.ValidateStatementPeriod(from, to); err != nil {
+		return err
+	}
+
+	statement, err := h.service.GetStatement(ctx, c.Params("uuid"), from, to)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case statementCSV:
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="statement.csv"`)
+		return c.Status(fiber.StatusOK).Send(statementCSVBody(statement))
+	case statementText:
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.Status(fiber.StatusOK).Send(statementTextBody(statement))
+	}
+	return c.Status(fiber.StatusOK).JSON(statement)
+}
+
+// statementCSVBody renders one row per operation between an opening and a
+// closing balance row, so the balance column can be checked in a spreadsheet.
+func statementCSVBody(s model.Statement) []byte {
+	var buf bytes.Buffer
+	out := csv.NewWriter(&buf)
+
+	_ = out.Write([]string{"date", "operation_id", "operation_type", "amount", "change", "balance"})
+	_ = out.Write([]string{formatStatementTime(s.From), "", "OPENING_BALANCE", "", "", s.OpeningBalance.String()})
+	for _, line := range s.Lines {
+		_ = out.Write([]string{
+			formatStatementTime(line.CreatedAt),
+			line.Id,
+			line.OperationType,
+			line.Amount.String(),
+			line.Change.String(),
+			line.RunningBalance.String(),
+		})
+	}
+	_ = out.Write([]string{formatStatementTime(s.To), "", "CLOSING_BALANCE", "", "", s.ClosingBalance.String()})
+
+	out.Flush()
+	return buf.Bytes()
+}
+
+func statementTextBody(s model.Statement) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Statement for wallet %s (%s)\n", s.WalletId, s.Currency)
+	fmt.Fprintf(&buf, "Period: %s - %s\n\n", formatStatementTime(s.From), formatStatementTime(s.To))
+	fmt.Fprintf(&buf, "Opening balance: %s\n\n", s.OpeningBalance)
+
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "DATE\tOPERATION\tTYPE\tCHANGE\tBALANCE\t")
+	for _, line := range s.Lines {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", formatStatementTime(line.CreatedAt), line.Id, line.OperationType, line.Change, line.RunningBalance)
+	}
+	_ = w.Flush()
+
+	buf.WriteString("\nTotals:\n")
+	types := make([]string, 0, len(s.Totals))
+	for operationType := range s.Totals {
+		types = append(types, operationType)
+	}
+	slices.Sort(types)
+	w = tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, operationType := range types {
+		fmt.Fprintf(w, "  %s\t%s\n", operationType, s.Totals[operationType])
+	}
+	_ = w.Flush()
+
+	fmt.Fprintf(&buf, "\nClosing balance: %s\n", s.ClosingBalance)
+	return buf.Bytes()
+}
+
+func formatStatementTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"strconv"
+	"wallet-service/internal/apperror"
+)
+
+// Batch modes. An ATOMIC batch is applied entirely or not at all; a
+// BEST_EFFORT batch applies every item it can and reports the rest.
+const (
+	BatchAtomic     = "ATOMIC"
+	BatchBestEffort = "BEST_EFFORT"
+)
+
+// DefaultMaxBatchItems is the largest batch accepted unless configured otherwise.
+const DefaultMaxBatchItems = 1000
+
+type BatchRequest struct {
+	Mode  string               `json:"mode"`
+	Items []TransactionRequest `json:"items"`
+}
+
+// BatchOptions controls how a batch is applied. A dry run applies every item
+// as usual and then rolls the whole batch back, so its results show what a
+// real run would do without changing anything.
+type BatchOptions struct {
+	Atomic bool
+	DryRun bool
+}
+
+// BatchItemResult is the outcome of one item of a batch: the operation it
+// produced, or the error that stopped it.
+type BatchItemResult struct {
+	Operation Operation
+	Err       error
+}
+
+func ValidateBatch(req BatchRequest) error {
+	if req.Mode != BatchAtomic && req.Mode != BatchBestEffort {
+		return apperror.Errorf(apperror.CodeValidation, "mode must be %s or %s", BatchAtomic, BatchBestEffort)
+	}
+	if len(req.Items) == 0 {
+		return apperror.Errorf(apperror.CodeValidation, "at least one item is required")
+	}
+	return nil
+}
+
+// ValidateItemKey checks that an Idempotency-Key header shared by the items of
+// a batch still fits MaxIdempotencyKeyLength once it is extended to
+// "<key>/<n>", n being the largest index or line number an item gets.
+func ValidateItemKey(key string, n int) error {
+	max := MaxIdempotencyKeyLength - len("/"+strconv.Itoa(n))
+	if len(key) > max {
+		return apperror.Errorf(apperror.CodeValidation, "idempotency key must be at most %d characters", max)
+	}
+	return nil
+}
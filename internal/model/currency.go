@@ -0,0 +1,51 @@
+package model
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+const DefaultCurrency = "USD"
+
+// currencyMinorUnits lists the ISO 4217 currencies a wallet can be opened in,
+// with the number of digits of their minor unit.
+var currencyMinorUnits = map[string]int32{
+	"AED": 2, "AUD": 2, "BHD": 3, "BRL": 2, "BYN": 2, "CAD": 2, "CHF": 2, "CLP": 0,
+	"CNY": 2, "CZK": 2, "DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "ILS": 2,
+	"INR": 2, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "KZT": 2, "MXN": 2,
+	"NOK": 2, "NZD": 2, "OMR": 3, "PLN": 2, "RUB": 2, "SEK": 2, "SGD": 2, "TND": 3,
+	"TRY": 2, "UAH": 2, "USD": 2, "VND": 0, "ZAR": 2,
+}
+
+func IsCurrency(code string) bool {
+	_, ok := currencyMinorUnits[code]
+	return ok
+}
+
+// Currencies returns the codes of the supported currencies in order.
+func Currencies() []string {
+	codes := make([]string, 0, len(currencyMinorUnits))
+	for code := range currencyMinorUnits {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// MinorUnits returns the precision of the currency, defaulting to two digits
+// for codes the service does not know.
+func MinorUnits(currency string) int32 {
+	if units, ok := currencyMinorUnits[currency]; ok {
+		return units
+	}
+	return 2
+}
+
+func RoundAmount(amount decimal.Decimal, currency string) decimal.Decimal {
+	return amount.Round(MinorUnits(currency))
+}
+
+func FormatAmount(amount decimal.Decimal, currency string) string {
+	return amount.StringFixed(MinorUnits(currency))
+}
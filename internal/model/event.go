@@ -0,0 +1,22 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is a change recorded in the outbox together with the write that caused
+// it and delivered to downstream services at least once. Consumers should
+// deduplicate by Id.
+type Event struct {
+	Id        int64           `json:"id"`
+	Type      string          `json:"type"`
+	WalletId  string          `json:"walletId"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Attempts  int             `json:"-"`
+}
+
+// EventBalanceChanged is written for every ledger operation; its payload is
+// the Operation.
+const EventBalanceChanged = "wallet.balance_changed"
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"wallet-service/internal/apperror"
+
+	"github.com/shopspring/decimal"
+)
+
+// FeeTransfer names transfers in fee rules, which are charged on the source
+// wallet in its currency.
+const FeeTransfer = "TRANSFER"
+
+// FeeRule prices the fee on operations of OperationType in Currency: Flat plus
+// Percent of the amount, raised to Min and capped at Max when they are set.
+type FeeRule struct {
+	OperationType string              `json:"operationType"`
+	Currency      string              `json:"currency"`
+	Flat          decimal.Decimal     `json:"flat"`
+	Percent       decimal.Decimal     `json:"percent"`
+	Min           decimal.NullDecimal `json:"min"`
+	Max           decimal.NullDecimal `json:"max"`
+}
+
+// Fee returns the fee on amount, rounded to the minor unit of the currency.
+func (r FeeRule) Fee(amount decimal.Decimal) decimal.Decimal {
+	fee := r.Flat.Add(amount.Mul(r.Percent).Div(decimal.NewFromInt(100)))
+	if r.Min.Valid && fee.LessThan(r.Min.Decimal) {
+		fee = r.Min.Decimal
+	}
+	if r.Max.Valid && fee.GreaterThan(r.Max.Decimal) {
+		fee = r.Max.Decimal
+	}
+	return RoundAmount(fee, r.Currency)
+}
+
+// FeeQuote is what an operation would cost before it is made: Total is the
+// amount and the fee together, as debited from the wallet.
+type FeeQuote struct {
+	WalletId      string          `json:"walletId"`
+	OperationType string          `json:"operationType"`
+	Amount        decimal.Decimal `json:"amount"`
+	Currency      string          `json:"currency"`
+	Fee           decimal.Decimal `json:"fee"`
+	Total         decimal.Decimal `json:"total"`
+}
+
+// IsFeeOperationType reports whether fees can be charged on op.
+func IsFeeOperationType(op string) bool {
+	return op == TransactionWithdraw || op == FeeTransfer
+}
+
+func ValidateFeeRule(r FeeRule) error {
+	if !IsFeeOperationType(r.OperationType) {
+		return apperror.Errorf(apperror.CodeValidation, "fees can only be charged on %s and %s", TransactionWithdraw, FeeTransfer)
+	}
+	if !IsCurrency(r.Currency) {
+		return apperror.Errorf(apperror.CodeValidation, "unsupported currency: %s", r.Currency)
+	}
+	if r.Flat.IsNegative() || r.Percent.IsNegative() {
+		return apperror.Errorf(apperror.CodeValidation, "flat and percent must not be negative")
+	}
+	if r.Percent.GreaterThan(decimal.NewFromInt(100)) {
+		return apperror.Errorf(apperror.CodeValidation, "percent must be at most 100")
+	}
+	if r.Min.Valid && r.Min.Decimal.IsNegative() || r.Max.Valid && r.Max.Decimal.IsNegative() {
+		return apperror.Errorf(apperror.CodeValidation, "min and max must not be negative")
+	}
+	if r.Min.Valid && r.Max.Valid && r.Min.Decimal.GreaterThan(r.Max.Decimal) {
+		return apperror.Errorf(apperror.CodeValidation, "min must not exceed max")
+	}
+	return nil
+}
+
+func ValidateFeeQuote(q FeeQuote) error {
+	if !IsFeeOperationType(q.OperationType) {
+		return apperror.Errorf(apperror.CodeValidation, "fees can only be charged on %s and %s", TransactionWithdraw, FeeTransfer)
+	}
+	if !q.Amount.IsPositive() {
+		return apperror.Errorf(apperror.CodeValidation, "amount must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+	"wallet-service/internal/apperror"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultHoldTTL is how long a hold reserves funds when no other TTL is configured.
+const DefaultHoldTTL = 15 * time.Minute
+
+const (
+	HoldActive   = "ACTIVE"
+	HoldCaptured = "CAPTURED"
+	HoldVoided   = "VOIDED"
+	HoldExpired  = "EXPIRED"
+)
+
+// Hold reserves Amount of a wallet's balance until it is captured, voided or
+// expires. While active it lowers the available balance but not the total.
+// A capture debits CapturedAmount and releases whatever is left of the hold.
+type Hold struct {
+	Id             string          `json:"id"`
+	WalletId       string          `json:"walletId"`
+	Amount         decimal.Decimal `json:"amount"`
+	Currency       string          `json:"currency"`
+	Status         string          `json:"status"`
+	CapturedAmount decimal.Decimal `json:"capturedAmount"`
+	OperationId    string          `json:"operationId,omitempty"`
+	ExpiresAt      time.Time       `json:"expiresAt"`
+	CreatedAt      time.Time       `json:"createdAt"`
+}
+
+type HoldRequest struct {
+	Amount string `json:"amount"`
+}
+
+// CaptureRequest captures the whole hold when Amount is empty.
+type CaptureRequest struct {
+	Amount string `json:"amount"`
+}
+
+func ValidateHold(hold Hold) error {
+	if hold.WalletId == "" {
+		return apperror.Errorf(apperror.CodeValidation, "wallet id is required")
+	}
+	if hold.Amount.LessThanOrEqual(decimal.Zero) {
+		return apperror.Errorf(apperror.CodeValidation, "amount must be positive")
+	}
+	return nil
+}
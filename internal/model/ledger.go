@@ -0,0 +1,69 @@
+package model
+
+import "github.com/shopspring/decimal"
+
+// System accounts are the other side of every wallet entry: money enters
+// through CASH_IN, leaves through CASH_OUT, is charged to FEES and changes
+// currency through FX.
+const (
+	AccountCashIn  = "CASH_IN"
+	AccountCashOut = "CASH_OUT"
+	AccountFees    = "FEES"
+	AccountFX      = "FX"
+)
+
+// AccountWallets stands for all wallets taken together in a trial balance.
+const AccountWallets = "WALLETS"
+
+// LedgerEntry books a signed Amount on a wallet, or on SystemAccount when
+// WalletId is empty. The entries of a wallet sum to its balance, and the
+// entries of a posting sum to zero in each currency.
+type LedgerEntry struct {
+	OperationId   string
+	WalletId      string
+	SystemAccount string
+	Currency      string
+	Amount        decimal.Decimal
+}
+
+// WalletEntry books op on its wallet, adding the amount when credit is set
+// and subtracting it otherwise.
+func WalletEntry(op Operation, credit bool) LedgerEntry {
+	amount := op.Amount
+	if !credit {
+		amount = amount.Neg()
+	}
+	return LedgerEntry{OperationId: op.Id, WalletId: op.WalletId, Currency: op.Currency, Amount: amount}
+}
+
+// SystemEntry books the other side of entry on a system account.
+func SystemEntry(account string, entry LedgerEntry) LedgerEntry {
+	return LedgerEntry{OperationId: entry.OperationId, SystemAccount: account, Currency: entry.Currency, Amount: entry.Amount.Neg()}
+}
+
+// PostingBalanced reports whether entries sum to zero in every currency.
+func PostingBalanced(entries []LedgerEntry) bool {
+	totals := make(map[string]decimal.Decimal)
+	for _, e := range entries {
+		totals[e.Currency] = totals[e.Currency].Add(e.Amount)
+	}
+	for _, total := range totals {
+		if !total.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrencyBalance sums the ledger of one currency per account, with all
+// wallets under AccountWallets. Total is zero when the books balance.
+type CurrencyBalance struct {
+	Currency string                     `json:"currency"`
+	Accounts map[string]decimal.Decimal `json:"accounts"`
+	Total    decimal.Decimal            `json:"total"`
+}
+
+type TrialBalance struct {
+	Currencies []CurrencyBalance `json:"currencies"`
+	Balanced   bool              `json:"balanced"`
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+	"wallet-service/internal/apperror"
+
+	"github.com/shopspring/decimal"
+)
+
+// LimitPolicy caps what wallets assigned to it may hold and move. Amounts are
+// in Currency and a null limit is not enforced. Deposit and withdrawal totals
+// are rolling: daily covers the last 24 hours, monthly the last 30 days.
+// A policy may serve a single wallet or a whole tier of them.
+type LimitPolicy struct {
+	Name              string              `json:"name"`
+	Currency          string              `json:"currency"`
+	MaxBalance        decimal.NullDecimal `json:"maxBalance"`
+	MaxSingleAmount   decimal.NullDecimal `json:"maxSingleAmount"`
+	DailyDeposit      decimal.NullDecimal `json:"dailyDeposit"`
+	DailyWithdrawal   decimal.NullDecimal `json:"dailyWithdrawal"`
+	MonthlyDeposit    decimal.NullDecimal `json:"monthlyDeposit"`
+	MonthlyWithdrawal decimal.NullDecimal `json:"monthlyWithdrawal"`
+}
+
+const (
+	DailyLimitWindow   = 24 * time.Hour
+	MonthlyLimitWindow = 30 * 24 * time.Hour
+)
+
+const MaxLimitPolicyNameLength = 64
+
+// Operation types counted towards the rolling deposit and withdrawal limits.
+// Refunds are left out, since they undo an operation that was already counted.
+var (
+	DepositOperationTypes    = []string{TransactionDeposit, OperationTransferIn}
+	WithdrawalOperationTypes = []string{TransactionWithdraw, OperationTransferOut, OperationCapture}
+)
+
+type AssignLimitPolicyRequest struct {
+	Policy string `json:"policy"`
+}
+
+func ValidateLimitPolicy(p LimitPolicy) error {
+	if p.Name == "" || len(p.Name) > MaxLimitPolicyNameLength {
+		return apperror.Errorf(apperror.CodeValidation, "policy name must be 1 to %d characters", MaxLimitPolicyNameLength)
+	}
+	if !IsCurrency(p.Currency) {
+		return apperror.Errorf(apperror.CodeValidation, "unsupported currency: %s", p.Currency)
+	}
+	for _, limit := range []decimal.NullDecimal{p.MaxBalance, p.MaxSingleAmount, p.DailyDeposit, p.DailyWithdrawal, p.MonthlyDeposit, p.MonthlyWithdrawal} {
+		if limit.Valid && !limit.Decimal.IsPositive() {
+			return apperror.Errorf(apperror.CodeValidation, "limits must be positive")
+		}
+	}
+	return nil
+}
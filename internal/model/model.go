@@ -1,37 +1,102 @@
 package model
 
 import (
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
+	"wallet-service/internal/apperror"
 
 	"github.com/shopspring/decimal"
 )
 
+// Transaction is a single-wallet balance change. For a REFUND, ReferenceId
+// names the operation being refunded and a zero Amount refunds whatever is
+// left of it; Uuid may be empty and is taken from that operation. Fee is set by
+// the service from the fee rules and is debited on top of Amount. A non-zero
+// ExpectedVersion rejects the transaction unless the wallet is at that version.
 type Transaction struct {
-	Uuid          string
-	OperationType string
-	Amount        decimal.Decimal
+	Uuid            string
+	OperationType   string
+	Amount          decimal.Decimal
+	Currency        string
+	ReferenceId     string
+	IdempotencyKey  string
+	Fee             decimal.Decimal
+	ExpectedVersion int64
+}
+
+// Hash fingerprints the payload of the transaction so that a replayed
+// idempotency key can be told apart from a key reused for another request.
+func (t Transaction) Hash() string {
+	payload := t.Uuid + "|" + t.OperationType + "|" + t.Amount.String()
+	if t.Currency != "" {
+		payload += "|" + t.Currency
+	}
+	if t.ReferenceId != "" {
+		payload += "|" + t.ReferenceId
+	}
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
 }
 
 type TransactionRequest struct {
 	ValletId      string `json:"valletId"`
 	OperationType string `json:"operationType"`
 	Amount        string `json:"amount"`
+	Currency      string `json:"currency"`
+}
+
+type RefundRequest struct {
+	OperationId string `json:"operationId"`
+	Amount      string `json:"amount"`
 }
 
 const (
 	TransactionDeposit  = "DEPOSIT"
 	TransactionWithdraw = "WITHDRAW"
+	TransactionRefund   = "REFUND"
 )
 
+// IsRefundable reports whether operations of type op can be refunded.
+func IsRefundable(op string) bool {
+	return op == TransactionDeposit || op == TransactionWithdraw
+}
+
+const MaxIdempotencyKeyLength = 255
+
 func ValidateTransaction(req Transaction) error {
+	if req.OperationType == TransactionRefund {
+		return validateRefund(req)
+	}
 	if req.Uuid == "" {
-		return fmt.Errorf("uuid is required")
+		return apperror.Errorf(apperror.CodeValidation, "uuid is required")
 	}
 	if req.OperationType != TransactionDeposit && req.OperationType != TransactionWithdraw {
-		return fmt.Errorf("invalid operation type: %s", req.OperationType)
+		return apperror.Errorf(apperror.CodeValidation, "invalid operation type: %s", req.OperationType)
+	}
+	if req.ReferenceId != "" {
+		return apperror.Errorf(apperror.CodeValidation, "only refunds may reference another operation")
 	}
 	if req.Amount.LessThanOrEqual(decimal.Zero) {
-		return fmt.Errorf("amount must be positive")
+		return apperror.Errorf(apperror.CodeValidation, "amount must be positive")
+	}
+	if req.Currency != "" && !IsCurrency(req.Currency) {
+		return apperror.Errorf(apperror.CodeValidation, "unsupported currency: %s", req.Currency)
+	}
+	if len(req.IdempotencyKey) > MaxIdempotencyKeyLength {
+		return apperror.Errorf(apperror.CodeValidation, "idempotency key must be at most %d characters", MaxIdempotencyKeyLength)
+	}
+	return nil
+}
+
+func validateRefund(req Transaction) error {
+	if req.ReferenceId == "" {
+		return apperror.Errorf(apperror.CodeValidation, "operation id is required")
+	}
+	if req.Amount.IsNegative() {
+		return apperror.Errorf(apperror.CodeValidation, "amount must be positive")
+	}
+	if len(req.IdempotencyKey) > MaxIdempotencyKeyLength {
+		return apperror.Errorf(apperror.CodeValidation, "idempotency key must be at most %d characters", MaxIdempotencyKeyLength)
 	}
 	return nil
 }
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+	"wallet-service/internal/apperror"
+
+	"github.com/shopspring/decimal"
+)
+
+// Operation is an immutable ledger row written for every balance change.
+type Operation struct {
+	Id            string          `json:"id"`
+	WalletId      string          `json:"walletId"`
+	OperationType string          `json:"operationType"`
+	Amount        decimal.Decimal `json:"amount"`
+	Currency      string          `json:"currency"`
+	BalanceAfter  decimal.Decimal `json:"balanceAfter"`
+	TransferId    string          `json:"transferId,omitempty"`
+	ReferenceId   string          `json:"referenceId,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+
+	// Fee is the FEE line charged with the operation, if any. It is not a
+	// column: the fee is a row of its own that references the operation.
+	Fee *Operation `json:"fee,omitempty"`
+}
+
+// FinalBalance is the wallet balance once the operation and its fee are applied.
+func (o Operation) FinalBalance() decimal.Decimal {
+	if o.Fee != nil {
+		return o.Fee.BalanceAfter
+	}
+	return o.BalanceAfter
+}
+
+// Operation types that only appear in the ledger: the two legs of a transfer,
+// the debit written when a hold is captured and the fee charged on an operation.
+const (
+	OperationTransferOut = "TRANSFER_OUT"
+	OperationTransferIn  = "TRANSFER_IN"
+	OperationCapture     = "CAPTURE"
+	OperationFee         = "FEE"
+)
+
+func IsOperationType(op string) bool {
+	switch op {
+	case TransactionDeposit, TransactionWithdraw, TransactionRefund, OperationTransferOut, OperationTransferIn, OperationCapture, OperationFee:
+		return true
+	}
+	return false
+}
+
+const (
+	DefaultOperationsLimit = 20
+	MaxOperationsLimit     = 100
+)
+
+// OperationCursor points at the last operation of a page. Pages are ordered
+// newest-first, so the next page starts strictly before the cursor.
+type OperationCursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+func (c OperationCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.Id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeOperationCursor(s string) (OperationCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return OperationCursor{}, apperror.Errorf(apperror.CodeValidation, "invalid cursor")
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return OperationCursor{}, apperror.Errorf(apperror.CodeValidation, "invalid cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return OperationCursor{}, apperror.Errorf(apperror.CodeValidation, "invalid cursor")
+	}
+	return OperationCursor{CreatedAt: t, Id: id}, nil
+}
+
+type OperationFilter struct {
+	WalletId      string
+	OperationType string
+	From          time.Time
+	To            time.Time
+	Cursor        *OperationCursor
+	Limit         int
+}
+
+type OperationPage struct {
+	Operations []Operation `json:"transactions"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+func ValidateOperationFilter(f OperationFilter) error {
+	if f.WalletId == "" {
+		return apperror.Errorf(apperror.CodeValidation, "uuid is required")
+	}
+	if err := ValidateExportFilter(f); err != nil {
+		return err
+	}
+	if f.Limit < 1 || f.Limit > MaxOperationsLimit {
+		return apperror.Errorf(apperror.CodeValidation, "limit must be between 1 and %d", MaxOperationsLimit)
+	}
+	return nil
+}
+
+// ValidateExportFilter checks the criteria of an export. Unlike a listing, an
+// export may span all wallets and is not paged by the caller.
+func ValidateExportFilter(f OperationFilter) error {
+	if f.OperationType != "" && !IsOperationType(f.OperationType) {
+		return apperror.Errorf(apperror.CodeValidation, "invalid operation type: %s", f.OperationType)
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return apperror.Errorf(apperror.CodeValidation, "from must be before to")
+	}
+	return nil
+}
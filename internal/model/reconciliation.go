@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultReconciliationRuns is how many recent runs are listed.
+const DefaultReconciliationRuns = 20
+
+// BalanceMismatch is a wallet whose stored balance differs from the sum of
+// its operations. Difference is stored minus ledger.
+type BalanceMismatch struct {
+	WalletId      string          `json:"walletId"`
+	Currency      string          `json:"currency"`
+	StoredBalance decimal.Decimal `json:"storedBalance"`
+	LedgerBalance decimal.Decimal `json:"ledgerBalance"`
+	Difference    decimal.Decimal `json:"difference"`
+	Fixed         bool            `json:"fixed"`
+}
+
+// ReconciliationRun is one comparison of every wallet's stored balance with
+// its ledger. Fix records whether mismatched balances were to be overwritten
+// with the ledger value; Mismatches is only filled in for a single run.
+type ReconciliationRun struct {
+	Id             int64             `json:"id"`
+	StartedAt      time.Time         `json:"startedAt"`
+	FinishedAt     time.Time         `json:"finishedAt"`
+	WalletsChecked int               `json:"walletsChecked"`
+	MismatchCount  int               `json:"mismatchCount"`
+	Fix            bool              `json:"fix"`
+	Mismatches     []BalanceMismatch `json:"mismatches,omitempty"`
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/cron"
+
+	"github.com/shopspring/decimal"
+)
+
+// Schedule states. A schedule runs while ACTIVE; a one-off one is COMPLETED
+// after its run, and one that cannot go on is FAILED.
+const (
+	ScheduleActive    = "ACTIVE"
+	ScheduleCompleted = "COMPLETED"
+	ScheduleFailed    = "FAILED"
+	ScheduleCancelled = "CANCELLED"
+)
+
+// Schedule makes a deposit or withdrawal on a wallet at NextRunAt: once, or
+// every time Cron fires when it is set. Cron is evaluated in UTC. Runs and
+// Failures count the occurrences that went through and those given up on;
+// Attempts counts the tries at the current one.
+type Schedule struct {
+	Id              string          `json:"id"`
+	WalletId        string          `json:"walletId"`
+	OperationType   string          `json:"operationType"`
+	Amount          decimal.Decimal `json:"amount"`
+	Currency        string          `json:"currency"`
+	Cron            string          `json:"cron,omitempty"`
+	Status          string          `json:"status"`
+	NextRunAt       *time.Time      `json:"nextRunAt,omitempty"`
+	Runs            int             `json:"runs"`
+	Failures        int             `json:"failures"`
+	Attempts        int             `json:"attempts"`
+	LastError       string          `json:"lastError,omitempty"`
+	LastRunAt       *time.Time      `json:"lastRunAt,omitempty"`
+	LastOperationId string          `json:"lastOperationId,omitempty"`
+	CreatedAt       time.Time       `json:"createdAt"`
+}
+
+// ScheduleRequest takes exactly one of RunAt and Cron.
+type ScheduleRequest struct {
+	OperationType string     `json:"operationType"`
+	Amount        string     `json:"amount"`
+	Currency      string     `json:"currency"`
+	RunAt         *time.Time `json:"runAt"`
+	Cron          string     `json:"cron"`
+}
+
+// Next returns when the schedule runs after the given time, or nil when it
+// does not run again.
+func (s Schedule) Next(after time.Time) *time.Time {
+	if s.Cron == "" {
+		return nil
+	}
+	expr, err := cron.Parse(s.Cron)
+	if err != nil {
+		return nil
+	}
+	next := expr.Next(after.UTC())
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// LatestDue returns the last occurrence from NextRunAt on that is due by now,
+// which is NextRunAt itself unless the schedule fell behind.
+func (s Schedule) LatestDue(now time.Time) *time.Time {
+	if s.NextRunAt == nil || s.Cron == "" {
+		return s.NextRunAt
+	}
+	expr, err := cron.Parse(s.Cron)
+	if err != nil {
+		return s.NextRunAt
+	}
+	latest := *s.NextRunAt
+	for {
+		next := expr.Next(latest.UTC())
+		if next.IsZero() || next.After(now) {
+			return &latest
+		}
+		latest = next
+	}
+}
+
+// Transaction is the operation the schedule makes at NextRunAt. Its
+// idempotency key names the occurrence, so that running it again after a crash
+// replays the first run instead of charging twice.
+func (s Schedule) Transaction() Transaction {
+	t := Transaction{
+		Uuid:          s.WalletId,
+		OperationType: s.OperationType,
+		Amount:        s.Amount,
+		Currency:      s.Currency,
+	}
+	if s.NextRunAt != nil {
+		t.IdempotencyKey = "schedule/" + s.Id + "/" + s.NextRunAt.UTC().Format(time.RFC3339)
+	}
+	return t
+}
+
+func ValidateSchedule(s Schedule) error {
+	if s.WalletId == "" {
+		return apperror.Errorf(apperror.CodeValidation, "wallet id is required")
+	}
+	if s.OperationType != TransactionDeposit && s.OperationType != TransactionWithdraw {
+		return apperror.Errorf(apperror.CodeValidation, "invalid operation type: %s", s.OperationType)
+	}
+	if s.Amount.LessThanOrEqual(decimal.Zero) {
+		return apperror.Errorf(apperror.CodeValidation, "amount must be positive")
+	}
+	if s.Currency != "" && !IsCurrency(s.Currency) {
+		return apperror.Errorf(apperror.CodeValidation, "unsupported currency: %s", s.Currency)
+	}
+	if (s.NextRunAt == nil) == (s.Cron == "") {
+		return apperror.Errorf(apperror.CodeValidation, "exactly one of runAt and cron is required")
+	}
+	if s.NextRunAt != nil && !s.NextRunAt.After(time.Now()) {
+		return apperror.Errorf(apperror.CodeValidation, "runAt must be in the future")
+	}
+	if s.Cron != "" {
+		expr, err := cron.Parse(s.Cron)
+		if err != nil {
+			return apperror.Errorf(apperror.CodeValidation, "invalid cron: %v", err)
+		}
+		if expr.Next(time.Now().UTC()).IsZero() {
+			return apperror.Errorf(apperror.CodeValidation, "cron never fires: %s", s.Cron)
+		}
+	}
+	return nil
+}
+
+func IsScheduleStatus(status string) bool {
+	return status == ScheduleActive || status == ScheduleCompleted || status == ScheduleFailed || status == ScheduleCancelled
+}
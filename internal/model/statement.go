@@ -0,0 +1,89 @@
+package model
+
+import (
+	"time"
+	"wallet-service/internal/apperror"
+
+	"github.com/shopspring/decimal"
+)
+
+// MaxStatementPeriod is the longest period a single statement may cover.
+const MaxStatementPeriod = 366 * 24 * time.Hour
+
+// StatementLine is an operation of a statement together with its signed
+// effect on the balance and the balance right after it.
+type StatementLine struct {
+	Operation
+	Change         decimal.Decimal `json:"change"`
+	RunningBalance decimal.Decimal `json:"runningBalance"`
+}
+
+// Statement lists the operations of a wallet over [From, To). Its balances
+// are derived from the ledger alone.
+type Statement struct {
+	WalletId       string                     `json:"walletId"`
+	Currency       string                     `json:"currency"`
+	From           time.Time                  `json:"from"`
+	To             time.Time                  `json:"to"`
+	OpeningBalance decimal.Decimal            `json:"openingBalance"`
+	Lines          []StatementLine            `json:"operations"`
+	Totals         map[string]decimal.Decimal `json:"totals"`
+	ClosingBalance decimal.Decimal            `json:"closingBalance"`
+}
+
+// NewStatement builds a statement from the opening balance and lines in
+// ledger order, each carrying its Change. It fills in the running balances,
+// the total amount per operation type and the closing balance.
+func NewStatement(wallet Wallet, from, to time.Time, opening decimal.Decimal, lines []StatementLine) Statement {
+	statement := Statement{
+		WalletId:       wallet.Id,
+		Currency:       wallet.Currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		Lines:          lines,
+		Totals:         map[string]decimal.Decimal{},
+	}
+
+	balance := opening
+	for i := range lines {
+		balance = balance.Add(lines[i].Change)
+		lines[i].RunningBalance = balance
+		statement.Totals[lines[i].OperationType] = statement.Totals[lines[i].OperationType].Add(lines[i].Amount)
+	}
+	statement.ClosingBalance = balance
+
+	return statement
+}
+
+// HistoricalBalance is the balance of a wallet as reconstructed from the
+// operations recorded up to and including AsOf.
+type HistoricalBalance struct {
+	WalletId string          `json:"walletId"`
+	Currency string          `json:"currency"`
+	Balance  decimal.Decimal `json:"balance"`
+	AsOf     time.Time       `json:"asOf"`
+}
+
+// StatementMonth returns the period covering the calendar month of a
+// "YYYY-MM" string, in UTC.
+func StatementMonth(month string) (time.Time, time.Time, error) {
+	from, err := time.Parse("2006-01", month)
+	if err != nil {
+		return time.Time{}, time.Time{}, apperror.Errorf(apperror.CodeValidation, "invalid month: expected YYYY-MM")
+	}
+	return from, from.AddDate(0, 1, 0), nil
+}
+
+func ValidateStatementPeriod(from, to time.Time) error {
+	if from.IsZero() || to.IsZero() {
+		return apperror.Errorf(apperror.CodeValidation, "a month or both from and to are required")
+	}
+	if !from.Before(to) {
+		return apperror.Errorf(apperror.CodeValidation, "from must be before to")
+	}
+	if to.Sub(from) > MaxStatementPeriod {
+		return apperror.Errorf(apperror.CodeValidation, "a statement can cover at most %d days", int(MaxStatementPeriod.Hours()/24))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"time"
+	"wallet-service/internal/apperror"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Transfer moves Amount out of the source wallet and ConvertedAmount into the
+// destination wallet. For wallets in the same currency the two are equal and
+// Rate is one; otherwise Rate and RateTimestamp record the quote that was used.
+// Fee is debited from the source wallet on top of Amount.
+type Transfer struct {
+	Id              string          `json:"id"`
+	FromUuid        string          `json:"fromWalletId"`
+	ToUuid          string          `json:"toWalletId"`
+	Amount          decimal.Decimal `json:"amount"`
+	FromCurrency    string          `json:"fromCurrency"`
+	ConvertedAmount decimal.Decimal `json:"convertedAmount"`
+	ToCurrency      string          `json:"toCurrency"`
+	Rate            decimal.Decimal `json:"rate"`
+	RateTimestamp   *time.Time      `json:"rateTimestamp,omitempty"`
+	Fee             decimal.Decimal `json:"fee"`
+	CreatedAt       time.Time       `json:"createdAt"`
+}
+
+type TransferRequest struct {
+	FromWalletId string `json:"fromWalletId"`
+	ToWalletId   string `json:"toWalletId"`
+	Amount       string `json:"amount"`
+}
+
+// CanonicalWalletId spells a well-formed wallet id the way Postgres prints it,
+// so that ids compare and sort as the uuids they stand for. Malformed ids are
+// returned as they are, for the lookup to reject.
+func CanonicalWalletId(id string) string {
+	if parsed, err := uuid.Parse(id); err == nil {
+		return parsed.String()
+	}
+	return id
+}
+
+func ValidateTransfer(req Transfer) error {
+	if req.FromUuid == "" || req.ToUuid == "" {
+		return apperror.Errorf(apperror.CodeValidation, "source and destination wallets are required")
+	}
+	if CanonicalWalletId(req.FromUuid) == CanonicalWalletId(req.ToUuid) {
+		return apperror.Errorf(apperror.CodeValidation, "source and destination wallets must differ")
+	}
+	if req.Amount.LessThanOrEqual(decimal.Zero) {
+		return apperror.Errorf(apperror.CodeValidation, "amount must be positive")
+	}
+	return nil
+}
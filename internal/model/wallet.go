@@ -0,0 +1,163 @@
+package model
+
+import (
+	"time"
+	"wallet-service/internal/apperror"
+
+	"github.com/shopspring/decimal"
+)
+
+// Wallet is an account in a single currency. Balance is the total amount
+// owned and goes negative, down to -CreditLimit, when the wallet draws on its
+// credit line; Held is the part of it reserved by active holds. OwnerId, Label
+// and Metadata belong to the client and are stored as given. Version goes up
+// with every change to the wallet and is handed to clients as its ETag.
+type Wallet struct {
+	Id            string          `json:"id"`
+	Balance       decimal.Decimal `json:"balance"`
+	Held          decimal.Decimal `json:"held"`
+	Currency      string          `json:"currency"`
+	Status        string          `json:"status"`
+	AllowDeposits bool            `json:"allowDeposits"`
+	OwnerId       string          `json:"ownerId,omitempty"`
+	Label         string          `json:"label,omitempty"`
+	Metadata      map[string]any  `json:"metadata,omitempty"`
+	LimitPolicy   string          `json:"limitPolicy,omitempty"`
+	CreditLimit   decimal.Decimal `json:"creditLimit"`
+	Version       int64           `json:"-"`
+}
+
+// Available is the amount free to spend, including any unused credit.
+func (w Wallet) Available() decimal.Decimal {
+	return w.Balance.Sub(w.Held).Add(w.CreditLimit)
+}
+
+// AvailableCredit is the part of the credit line not yet drawn or held.
+func (w Wallet) AvailableCredit() decimal.Decimal {
+	own := w.Balance.Sub(w.Held)
+	if own.IsNegative() {
+		return w.CreditLimit.Add(own)
+	}
+	return w.CreditLimit
+}
+
+// Wallet lifecycle states. A frozen wallet accepts no debits and accepts
+// credits only when AllowDeposits is set; a closed wallet accepts nothing.
+const (
+	WalletActive = "ACTIVE"
+	WalletFrozen = "FROZEN"
+	WalletClosed = "CLOSED"
+)
+
+// CheckDebit reports whether funds may leave the wallet in its current state.
+func (w Wallet) CheckDebit() error {
+	switch w.Status {
+	case WalletFrozen:
+		return apperror.ErrWalletFrozen
+	case WalletClosed:
+		return apperror.ErrWalletClosed
+	}
+	return nil
+}
+
+// CheckCredit reports whether funds may enter the wallet in its current state.
+func (w Wallet) CheckCredit() error {
+	switch {
+	case w.Status == WalletFrozen && !w.AllowDeposits:
+		return apperror.ErrWalletFrozen
+	case w.Status == WalletClosed:
+		return apperror.ErrWalletClosed
+	}
+	return nil
+}
+
+type CreateWalletRequest struct {
+	Currency string         `json:"currency"`
+	OwnerId  string         `json:"ownerId"`
+	Label    string         `json:"label"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+const (
+	MaxOwnerIdLength = 255
+	MaxLabelLength   = 255
+	MaxMetadataKeys  = 50
+)
+
+func ValidateWallet(w Wallet) error {
+	if !IsCurrency(w.Currency) {
+		return apperror.Errorf(apperror.CodeValidation, "unsupported currency: %s", w.Currency)
+	}
+	if len(w.OwnerId) > MaxOwnerIdLength {
+		return apperror.Errorf(apperror.CodeValidation, "owner id must be at most %d characters", MaxOwnerIdLength)
+	}
+	if len(w.Label) > MaxLabelLength {
+		return apperror.Errorf(apperror.CodeValidation, "label must be at most %d characters", MaxLabelLength)
+	}
+	if len(w.Metadata) > MaxMetadataKeys {
+		return apperror.Errorf(apperror.CodeValidation, "metadata must have at most %d keys", MaxMetadataKeys)
+	}
+	return nil
+}
+
+// StatusChange moves a wallet to Status. Reason and Actor are kept in the
+// audit trail of the wallet.
+type StatusChange struct {
+	Id            string    `json:"id"`
+	WalletId      string    `json:"walletId"`
+	FromStatus    string    `json:"fromStatus"`
+	Status        string    `json:"status"`
+	Reason        string    `json:"reason"`
+	Actor         string    `json:"actor"`
+	AllowDeposits bool      `json:"allowDeposits"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type StatusChangeRequest struct {
+	Reason        string `json:"reason"`
+	Actor         string `json:"actor"`
+	AllowDeposits bool   `json:"allowDeposits"`
+}
+
+// CanTransition reports whether a wallet may move from one state to another.
+// A frozen wallet may be frozen again to change whether it accepts deposits.
+// Closing is final.
+func CanTransition(from, to string) bool {
+	switch from {
+	case WalletActive:
+		return to == WalletFrozen || to == WalletClosed
+	case WalletFrozen:
+		return to == WalletActive || to == WalletFrozen || to == WalletClosed
+	}
+	return false
+}
+
+func ValidateStatusChange(c StatusChange) error {
+	if c.WalletId == "" {
+		return apperror.Errorf(apperror.CodeValidation, "wallet id is required")
+	}
+	if c.Status != WalletActive && c.Status != WalletFrozen && c.Status != WalletClosed {
+		return apperror.Errorf(apperror.CodeValidation, "invalid wallet status: %s", c.Status)
+	}
+	if c.Reason == "" {
+		return apperror.Errorf(apperror.CodeValidation, "reason is required")
+	}
+	if c.Actor == "" {
+		return apperror.Errorf(apperror.CodeValidation, "actor is required")
+	}
+	if c.AllowDeposits && c.Status != WalletFrozen {
+		return apperror.Errorf(apperror.CodeValidation, "allowDeposits only applies to frozen wallets")
+	}
+	return nil
+}
+
+type CreditLimitRequest struct {
+	CreditLimit decimal.Decimal `json:"creditLimit"`
+}
+
+func ValidateCreditLimit(limit decimal.Decimal) error {
+	if limit.IsNegative() {
+		return apperror.Errorf(apperror.CodeValidation, "credit limit must not be negative")
+	}
+	return nil
+}
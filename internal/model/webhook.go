@@ -0,0 +1,141 @@
+package model
+
+import (
+	"encoding/json"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"wallet-service/internal/apperror"
+
+	"github.com/shopspring/decimal"
+)
+
+// Webhook event types a subscription can ask for.
+const (
+	WebhookDeposit    = "operation.deposit"
+	WebhookWithdraw   = "operation.withdraw"
+	WebhookLowBalance = "wallet.low_balance"
+)
+
+var webhookEventTypes = []string{WebhookDeposit, WebhookWithdraw, WebhookLowBalance}
+
+// WebhookEventFor returns the webhook event type announcing an operation of
+// the given type, if there is one.
+func WebhookEventFor(operationType string) (string, bool) {
+	switch operationType {
+	case TransactionDeposit:
+		return WebhookDeposit, true
+	case TransactionWithdraw:
+		return WebhookWithdraw, true
+	}
+	return "", false
+}
+
+// WebhookSubscription asks for events of EventTypes to be POSTed to Url,
+// signed with Secret. LowBalanceThreshold is the balance a wallet in
+// LowBalanceCurrency has to drop below to trigger a low-balance event.
+type WebhookSubscription struct {
+	Id                  string              `json:"id"`
+	Url                 string              `json:"url"`
+	EventTypes          []string            `json:"eventTypes"`
+	Secret              string              `json:"secret,omitempty"`
+	LowBalanceThreshold decimal.NullDecimal `json:"lowBalanceThreshold"`
+	LowBalanceCurrency  string              `json:"lowBalanceCurrency,omitempty"`
+	CreatedAt           time.Time           `json:"createdAt"`
+}
+
+// Webhook delivery states. A delivery is retried while PENDING and parked as
+// DEAD once it runs out of attempts; only a redelivery revives it.
+const (
+	DeliveryPending   = "PENDING"
+	DeliveryDelivered = "DELIVERED"
+	DeliveryDead      = "DEAD"
+)
+
+// WebhookDelivery is a single event queued for a single subscription. Url and
+// Secret are copied from the subscription when the delivery is claimed.
+type WebhookDelivery struct {
+	Id             string          `json:"id"`
+	SubscriptionId string          `json:"subscriptionId"`
+	EventType      string          `json:"eventType"`
+	OperationId    string          `json:"operationId"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"lastError,omitempty"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+
+	Url    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+const MaxWebhookUrlLength = 2048
+
+// nonPublicPrefixes are the ranges IsPublicAddress refuses on top of the
+// loopback, link-local, multicast and private ones: "this network" and the
+// carrier-grade NAT space.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// IsPublicAddress reports whether webhooks may be sent to addr. Loopback,
+// link-local, private and other internal addresses are refused, so that a
+// subscription cannot reach the service's own network.
+func IsPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateWebhookSubscription checks s. A URL naming a host rather than an
+// address is checked again after resolution, when a delivery connects to it.
+func ValidateWebhookSubscription(s WebhookSubscription) error {
+	u, err := url.Parse(s.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(s.Url) > MaxWebhookUrlLength {
+		return apperror.Errorf(apperror.CodeValidation, "url must be an absolute http or https URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return apperror.Errorf(apperror.CodeValidation, "url must not point to a local or private address")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !IsPublicAddress(addr) {
+		return apperror.Errorf(apperror.CodeValidation, "url must not point to a local or private address")
+	}
+	if len(s.EventTypes) == 0 {
+		return apperror.Errorf(apperror.CodeValidation, "at least one event type is required")
+	}
+	for _, eventType := range s.EventTypes {
+		if !slices.Contains(webhookEventTypes, eventType) {
+			return apperror.Errorf(apperror.CodeValidation, "unknown event type: %s", eventType)
+		}
+	}
+	if slices.Contains(s.EventTypes, WebhookLowBalance) && !s.LowBalanceThreshold.Valid {
+		return apperror.Errorf(apperror.CodeValidation, "lowBalanceThreshold is required for %s", WebhookLowBalance)
+	}
+	if s.LowBalanceThreshold.Valid && s.LowBalanceThreshold.Decimal.IsNegative() {
+		return apperror.Errorf(apperror.CodeValidation, "lowBalanceThreshold must not be negative")
+	}
+	if s.LowBalanceThreshold.Valid != (s.LowBalanceCurrency != "") {
+		return apperror.Errorf(apperror.CodeValidation, "lowBalanceThreshold and lowBalanceCurrency go together")
+	}
+	if s.LowBalanceCurrency != "" && !IsCurrency(s.LowBalanceCurrency) {
+		return apperror.Errorf(apperror.CodeValidation, "unsupported currency: %s", s.LowBalanceCurrency)
+	}
+	return nil
+}
+
+func IsDeliveryStatus(status string) bool {
+	return status == DeliveryPending || status == DeliveryDelivered || status == DeliveryDead
+}
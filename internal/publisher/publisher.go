@@ -0,0 +1,88 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"wallet-service/internal/model"
+)
+
+// Publisher delivers outbox events to downstream consumers. A nil error means
+// the event has been accepted and will not be offered again.
+type Publisher interface {
+	Publish(ctx context.Context, event model.Event) error
+}
+
+// MemoryPublisher keeps published events in memory. It is meant for tests and
+// local runs.
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []model.Event
+	err    error
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// FailWith makes every following Publish return err, or succeed again when
+// err is nil.
+func (p *MemoryPublisher) FailWith(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event model.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events returns the events published so far, oldest first.
+func (p *MemoryPublisher) Events() []model.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]model.Event(nil), p.events...)
+}
+
+// FilePublisher appends every event to a file as a line of JSON.
+type FilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open events file: %w", err)
+	}
+	return &FilePublisher{file: file}, nil
+}
+
+func (p *FilePublisher) Publish(ctx context.Context, event model.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := p.file.Write(line); err != nil {
+		return fmt.Errorf("write event: %w", err)
+	}
+	return nil
+}
+
+func (p *FilePublisher) Close() error {
+	return p.file.Close()
+}
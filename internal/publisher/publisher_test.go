@@ -0,0 +1,35 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"wallet-service/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	p, err := NewFilePublisher(path)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, p.Publish(ctx, model.Event{Id: 1, Type: model.EventBalanceChanged, WalletId: "wallet-a", Payload: json.RawMessage(`{"id":"op-1"}`)}))
+	require.NoError(t, p.Publish(ctx, model.Event{Id: 2, Type: model.EventBalanceChanged, WalletId: "wallet-a", Payload: json.RawMessage(`{"id":"op-2"}`)}))
+	require.NoError(t, p.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+
+	var event model.Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(t, int64(2), event.Id)
+	assert.JSONEq(t, `{"id":"op-2"}`, string(event.Payload))
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Batch applies transactions in order within a single database transaction.
+// Every wallet they touch is locked up front in id order, the same order
+// Transfer uses, so concurrent batches and transfers cannot deadlock.
+//
+// When atomic, the first failing item rolls the whole batch back and every
+// other item is reported as aborted. Otherwise each item runs in its own
+// savepoint and only the failed ones are undone. A dry run is rolled back
+// after the last item. The returned error is set only when the batch as a
+// whole could not run.
+func (r *repository) Batch(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) (results []model.BatchItemResult, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	aborted := false
+	defer func() {
+		if err != nil || aborted || opts.DryRun {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err := lockWallets(ctx, tx, batchWalletIds(transactions)); err != nil {
+		return nil, err
+	}
+
+	results = make([]model.BatchItemResult, len(transactions))
+	for i, transaction := range transactions {
+		if opts.Atomic {
+			op, itemErr := applyTransaction(ctx, tx, transaction)
+			if itemErr != nil {
+				aborted = true
+				return abortBatch(results, i, itemErr), nil
+			}
+			results[i].Operation = op
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_item"); err != nil {
+			return nil, fmt.Errorf("create savepoint: %w", err)
+		}
+		op, itemErr := applyTransaction(ctx, tx, transaction)
+		if itemErr != nil {
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_item"); err != nil {
+				return nil, fmt.Errorf("rollback to savepoint: %w", err)
+			}
+			results[i].Err = itemErr
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_item"); err != nil {
+			return nil, fmt.Errorf("release savepoint: %w", err)
+		}
+		results[i].Operation = op
+	}
+
+	return results, nil
+}
+
+// abortBatch reports err for the failed item and marks every other item as
+// not applied.
+func abortBatch(results []model.BatchItemResult, failed int, err error) []model.BatchItemResult {
+	aborted := apperror.Errorf(apperror.CodeBatchAborted, "not applied because item %d failed", failed)
+	for i := range results {
+		results[i] = model.BatchItemResult{Err: aborted}
+	}
+	results[failed].Err = err
+	return results
+}
+
+// batchWalletIds returns the distinct, well-formed wallet ids of transactions
+// in ascending order. Malformed ids are left for the item to report.
+func batchWalletIds(transactions []model.Transaction) []string {
+	ids := make([]string, 0, len(transactions))
+	for _, transaction := range transactions {
+		if _, err := uuid.Parse(transaction.Uuid); err == nil {
+			ids = append(ids, transaction.Uuid)
+		}
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// lockWallets takes row locks on the wallets in id order. Wallets that do not
+// exist are skipped.
+func lockWallets(ctx context.Context, tx *sql.Tx, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM wallets WHERE id = ANY($1::uuid[]) ORDER BY id FOR UPDATE`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("lock wallets: %w", err)
+	}
+	defer rows.Close()
+
+	// Only the locks matter; the ids are read to let the query finish.
+	for rows.Next() {
+	}
+	return rows.Err()
+}
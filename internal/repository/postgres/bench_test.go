@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"wallet-service/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// BenchmarkHotWalletDeposits compares the guarded single UPDATE of Transaction
+// with the locking path it replaced, with every goroutine depositing into the
+// same wallet. It needs a migrated database:
+//
+//	WALLET_BENCH_DSN=postgres://... go test -run '^$' -bench HotWallet -cpu 1,8,32 ./internal/repository/postgres
+func BenchmarkHotWalletDeposits(b *testing.B) {
+	dsn := os.Getenv("WALLET_BENCH_DSN")
+	if dsn == "" {
+		b.Skip("WALLET_BENCH_DSN is not set")
+	}
+	db, err := NewDB(dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(64)
+	db.SetMaxIdleConns(64)
+
+	repo := NewRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil))).(*repository)
+	ctx := context.Background()
+
+	deposit := func(b *testing.B) model.Transaction {
+		wallet := model.Wallet{Id: uuid.New().String(), Currency: model.DefaultCurrency}
+		if err := repo.CreateWallet(ctx, wallet, false); err != nil {
+			b.Fatal(err)
+		}
+		return model.Transaction{Uuid: wallet.Id, OperationType: model.TransactionDeposit, Amount: decimal.NewFromInt(1)}
+	}
+
+	b.Run("guarded update", func(b *testing.B) {
+		transaction := deposit(b)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := repo.Transaction(ctx, transaction); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+
+	b.Run("select for update", func(b *testing.B) {
+		transaction := deposit(b)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				tx, err := db.BeginTx(ctx, nil)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				if _, err := applyTransaction(ctx, tx, transaction); err != nil {
+					_ = tx.Rollback()
+					b.Error(err)
+					return
+				}
+				if err := tx.Commit(); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
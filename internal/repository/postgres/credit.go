@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// SetWalletCreditLimit replaces the wallet's credit line. The limit cannot be
+// cut below the credit the wallet already uses, counting its active holds.
+func (r *repository) SetWalletCreditLimit(ctx context.Context, walletId string, limit decimal.Decimal) (wallet model.Wallet, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Wallet{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	wallet, err = lockWallet(ctx, tx, walletId)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	limit = model.RoundAmount(limit, wallet.Currency)
+	if used := wallet.CreditLimit.Sub(wallet.AvailableCredit()); limit.LessThan(used) {
+		return model.Wallet{}, apperror.Errorf(apperror.CodeCreditLimitInUse,
+			"credit limit cannot be lower than the %s %s in use", model.FormatAmount(used, wallet.Currency), wallet.Currency)
+	}
+
+	wallet.CreditLimit = limit
+	_, err = tx.ExecContext(ctx, "UPDATE wallets SET credit_limit = $1 WHERE id = $2", model.FormatAmount(limit, wallet.Currency), wallet.Id)
+	if err != nil {
+		return model.Wallet{}, fmt.Errorf("update credit limit: %w", err)
+	}
+
+	return wallet, nil
+}
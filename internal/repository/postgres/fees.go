@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/shopspring/decimal"
+)
+
+const feeRuleColumns = `operation_type, currency, flat, percent, min_fee, max_fee`
+
+// UpsertFeeRule creates the rule for its operation type and currency or
+// replaces the one already there.
+func (r *repository) UpsertFeeRule(ctx context.Context, rule model.FeeRule) (model.FeeRule, error) {
+	const query = `INSERT INTO fee_rules (` + feeRuleColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (operation_type, currency) DO UPDATE SET
+			flat = EXCLUDED.flat,
+			percent = EXCLUDED.percent,
+			min_fee = EXCLUDED.min_fee,
+			max_fee = EXCLUDED.max_fee,
+			updated_at = now()
+		RETURNING ` + feeRuleColumns
+
+	result, err := scanFeeRule(r.db.QueryRowContext(ctx, query,
+		rule.OperationType, rule.Currency, rule.Flat.String(), rule.Percent.String(), rule.Min, rule.Max,
+	))
+	if err != nil {
+		return model.FeeRule{}, fmt.Errorf("upsert fee rule: %w", err)
+	}
+	return result, nil
+}
+
+func (r *repository) ListFeeRules(ctx context.Context) ([]model.FeeRule, error) {
+	const query = `SELECT ` + feeRuleColumns + ` FROM fee_rules ORDER BY operation_type, currency`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list fee rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []model.FeeRule{}
+	for rows.Next() {
+		rule, err := scanFeeRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan fee rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (r *repository) GetFeeRule(ctx context.Context, operationType, currency string) (model.FeeRule, error) {
+	const query = `SELECT ` + feeRuleColumns + ` FROM fee_rules WHERE operation_type = $1 AND currency = $2`
+
+	rule, err := scanFeeRule(r.db.QueryRowContext(ctx, query, operationType, currency))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.FeeRule{}, apperror.ErrFeeRuleNotFound
+	}
+	if err != nil {
+		return model.FeeRule{}, fmt.Errorf("get fee rule: %w", err)
+	}
+	return rule, nil
+}
+
+func (r *repository) DeleteFeeRule(ctx context.Context, operationType, currency string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM fee_rules WHERE operation_type = $1 AND currency = $2`, operationType, currency)
+	if err != nil {
+		return fmt.Errorf("delete fee rule: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete fee rule: %w", err)
+	}
+	if n == 0 {
+		return apperror.ErrFeeRuleNotFound
+	}
+	return nil
+}
+
+func scanFeeRule(row rowScanner) (model.FeeRule, error) {
+	var rule model.FeeRule
+	err := row.Scan(&rule.OperationType, &rule.Currency, &rule.Flat, &rule.Percent, &rule.Min, &rule.Max)
+	if err != nil {
+		return model.FeeRule{}, err
+	}
+	return rule, nil
+}
+
+// chargeFee writes the FEE line of op, which wallet must already have paid:
+// its balance is the one left after the fee. The returned entries book the fee
+// against FEES and belong in the posting of op.
+func chargeFee(ctx context.Context, tx *sql.Tx, wallet model.Wallet, op *model.Operation, fee decimal.Decimal) ([]model.LedgerEntry, error) {
+	line := model.Operation{
+		WalletId:      wallet.Id,
+		OperationType: model.OperationFee,
+		Amount:        fee,
+		Currency:      wallet.Currency,
+		BalanceAfter:  wallet.Balance,
+		ReferenceId:   op.Id,
+	}
+	if err := insertOperation(ctx, tx, &line, "", ""); err != nil {
+		return nil, fmt.Errorf("insert fee: %w", err)
+	}
+	op.Fee = &line
+
+	entry := model.WalletEntry(line, false)
+	return []model.LedgerEntry{entry, model.SystemEntry(model.AccountFees, entry)}, nil
+}
+
+// getFeeOperation looks up the FEE line charged with the operation id, or
+// returns nil when it was free.
+func getFeeOperation(ctx context.Context, tx *sql.Tx, id string) (*model.Operation, error) {
+	const query = `SELECT ` + operationColumns + ` FROM operations WHERE reference_id = $1 AND operation_type = $2`
+
+	fee, err := scanOperation(tx.QueryRowContext(ctx, query, id, model.OperationFee))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get fee: %w", err)
+	}
+	return &fee, nil
+}
@@ -0,0 +1,320 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const holdColumns = `id, wallet_id, amount, currency, status, captured_amount, operation_id, expires_at, created_at`
+
+// AuthorizeHold reserves hold.Amount of the wallet's available balance until
+// hold.ExpiresAt. The total balance is left untouched.
+func (r *repository) AuthorizeHold(ctx context.Context, hold model.Hold) (result model.Hold, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Hold{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	wallet, err := lockWallet(ctx, tx, hold.WalletId)
+	if err != nil {
+		return model.Hold{}, err
+	}
+	if err := wallet.CheckDebit(); err != nil {
+		return model.Hold{}, err
+	}
+
+	amount, err := roundAmount(hold.Amount, wallet.Currency)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	if wallet.Available().LessThan(amount) {
+		return model.Hold{}, apperror.ErrInsufficientFunds
+	}
+	if err := checkLimits(ctx, tx, wallet, false, amount); err != nil {
+		return model.Hold{}, err
+	}
+
+	wallet.Held = wallet.Held.Add(amount)
+	if err := updateHeld(ctx, tx, wallet); err != nil {
+		return model.Hold{}, err
+	}
+
+	result = model.Hold{
+		WalletId:       wallet.Id,
+		Amount:         amount,
+		Currency:       wallet.Currency,
+		Status:         model.HoldActive,
+		CapturedAmount: decimal.Zero,
+		ExpiresAt:      hold.ExpiresAt,
+	}
+	err = tx.QueryRowContext(ctx, `INSERT INTO holds (wallet_id, amount, currency, expires_at)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		result.WalletId, amount.String(), result.Currency, result.ExpiresAt,
+	).Scan(&result.Id, &result.CreatedAt)
+	if err != nil {
+		return model.Hold{}, fmt.Errorf("insert hold: %w", err)
+	}
+
+	return result, nil
+}
+
+// CaptureHold debits amount from the wallet and releases the rest of the hold.
+// A zero amount captures the hold in full.
+func (r *repository) CaptureHold(ctx context.Context, id string, amount decimal.Decimal) (result model.Hold, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Hold{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	hold, err := lockHold(ctx, tx, id)
+	if err != nil {
+		return model.Hold{}, err
+	}
+	if hold.Status != model.HoldActive || !hold.ExpiresAt.After(time.Now()) {
+		return model.Hold{}, apperror.ErrHoldNotActive
+	}
+
+	if amount.IsZero() {
+		amount = hold.Amount
+	}
+	if amount, err = roundAmount(amount, hold.Currency); err != nil {
+		return model.Hold{}, err
+	}
+	if amount.GreaterThan(hold.Amount) {
+		return model.Hold{}, apperror.Errorf(apperror.CodeValidation, "capture amount exceeds the hold amount of %s", hold.Amount)
+	}
+
+	wallet, err := lockWallet(ctx, tx, hold.WalletId)
+	if err != nil {
+		return model.Hold{}, err
+	}
+	if err := wallet.CheckDebit(); err != nil {
+		return model.Hold{}, err
+	}
+	// Release the hold before debiting, so held never exceeds balance in
+	// between. The captured amount then counts towards the withdrawal limits
+	// in place of what the hold reserved.
+	wallet.Held = wallet.Held.Sub(hold.Amount)
+	if err := checkLimits(ctx, tx, wallet, false, amount); err != nil {
+		return model.Hold{}, err
+	}
+	if err := updateHeld(ctx, tx, wallet); err != nil {
+		return model.Hold{}, err
+	}
+	wallet.Balance = wallet.Balance.Sub(amount)
+	if err := updateBalance(ctx, tx, wallet); err != nil {
+		return model.Hold{}, err
+	}
+
+	op := model.Operation{
+		WalletId:      wallet.Id,
+		OperationType: model.OperationCapture,
+		Amount:        amount,
+		Currency:      wallet.Currency,
+		BalanceAfter:  wallet.Balance,
+	}
+	if err := insertOperation(ctx, tx, &op, "", ""); err != nil {
+		return model.Hold{}, fmt.Errorf("insert operation: %w", err)
+	}
+	entry := model.WalletEntry(op, false)
+	if err := postEntries(ctx, tx, op.Id, []model.LedgerEntry{entry, model.SystemEntry(model.AccountCashOut, entry)}); err != nil {
+		return model.Hold{}, err
+	}
+	if err := enqueueWebhooks(ctx, tx, op, op.BalanceAfter.Add(amount)); err != nil {
+		return model.Hold{}, err
+	}
+
+	hold.Status = model.HoldCaptured
+	hold.CapturedAmount = amount
+	hold.OperationId = op.Id
+	if err := updateHold(ctx, tx, hold); err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// VoidHold releases an active hold without moving any funds.
+func (r *repository) VoidHold(ctx context.Context, id string) (result model.Hold, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Hold{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	hold, err := lockHold(ctx, tx, id)
+	if err != nil {
+		return model.Hold{}, err
+	}
+	if hold.Status != model.HoldActive {
+		return model.Hold{}, apperror.ErrHoldNotActive
+	}
+
+	if err := releaseHold(ctx, tx, &hold, model.HoldVoided); err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+// ExpireHolds releases up to limit active holds that expired at or before now
+// and returns how many it released. Holds locked by a concurrent capture or
+// void are skipped and left for the next run.
+func (r *repository) ExpireHolds(ctx context.Context, now time.Time, limit int) (n int, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Ordering by wallet keeps the wallet locks taken below in a stable order.
+	const query = `SELECT ` + holdColumns + ` FROM holds
+		WHERE status = 'ACTIVE' AND expires_at <= $1
+		ORDER BY wallet_id, id LIMIT $2 FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("select expired holds: %w", err)
+	}
+	holds, err := scanHolds(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range holds {
+		if err := releaseHold(ctx, tx, &holds[i], model.HoldExpired); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(holds), nil
+}
+
+// releaseHold returns the held amount to the wallet's available balance and
+// moves the hold to status.
+func releaseHold(ctx context.Context, tx *sql.Tx, hold *model.Hold, status string) error {
+	wallet, err := lockWallet(ctx, tx, hold.WalletId)
+	if err != nil {
+		return err
+	}
+
+	wallet.Held = wallet.Held.Sub(hold.Amount)
+	if err := updateHeld(ctx, tx, wallet); err != nil {
+		return err
+	}
+
+	hold.Status = status
+	return updateHold(ctx, tx, *hold)
+}
+
+// lockHold takes a row lock on the hold for the rest of tx. Holds are always
+// locked before their wallet.
+func lockHold(ctx context.Context, tx *sql.Tx, id string) (model.Hold, error) {
+	const query = `SELECT ` + holdColumns + ` FROM holds WHERE id = $1 FOR UPDATE`
+
+	hold, err := scanHold(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return model.Hold{}, fmt.Errorf("get hold: %w", holdError(err))
+	}
+	return hold, nil
+}
+
+func updateHeld(ctx context.Context, tx *sql.Tx, wallet model.Wallet) error {
+	_, err := tx.ExecContext(ctx, "UPDATE wallets SET held = $1 WHERE id = $2", model.FormatAmount(wallet.Held, wallet.Currency), wallet.Id)
+	if err != nil {
+		return fmt.Errorf("update held: %w", err)
+	}
+	return nil
+}
+
+func updateHold(ctx context.Context, tx *sql.Tx, hold model.Hold) error {
+	const query = `UPDATE holds SET status = $1, captured_amount = $2, operation_id = $3, updated_at = now() WHERE id = $4`
+
+	_, err := tx.ExecContext(ctx, query, hold.Status, hold.CapturedAmount.String(), nullString(hold.OperationId), hold.Id)
+	if err != nil {
+		return fmt.Errorf("update hold: %w", err)
+	}
+	return nil
+}
+
+func scanHold(row rowScanner) (model.Hold, error) {
+	var hold model.Hold
+	var operationId sql.NullString
+
+	err := row.Scan(&hold.Id, &hold.WalletId, &hold.Amount, &hold.Currency, &hold.Status, &hold.CapturedAmount, &operationId, &hold.ExpiresAt, &hold.CreatedAt)
+	if err != nil {
+		return model.Hold{}, err
+	}
+	hold.OperationId = operationId.String
+
+	return hold, nil
+}
+
+func scanHolds(rows *sql.Rows) ([]model.Hold, error) {
+	defer rows.Close()
+
+	holds := []model.Hold{}
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan hold: %w", err)
+		}
+		holds = append(holds, hold)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return holds, nil
+}
+
+// holdError is the hold counterpart of walletError.
+func holdError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperror.ErrHoldNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == invalidTextRepresentation {
+		return apperror.ErrHoldNotFound
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"wallet-service/internal/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// postEntries books a posting. An unbalanced posting is a bug in the caller,
+// so it is refused here; the database checks the balance again on commit.
+func postEntries(ctx context.Context, tx *sql.Tx, postingId string, entries []model.LedgerEntry) error {
+	if !model.PostingBalanced(entries) {
+		return fmt.Errorf("posting %s does not balance", postingId)
+	}
+
+	values := make([]string, len(entries))
+	args := make([]any, 0, 6*len(entries))
+	for i, e := range entries {
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6)
+		args = append(args, postingId, e.OperationId, nullString(e.WalletId), nullString(e.SystemAccount), e.Currency, e.Amount.String())
+	}
+
+	query := `INSERT INTO ledger_entries (posting_id, operation_id, wallet_id, system_account, currency, amount) VALUES ` + strings.Join(values, ", ")
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("insert ledger entries: %w", err)
+	}
+	return nil
+}
+
+// TrialBalance sums the ledger per currency and account. Every posting nets
+// to zero, so every currency should too.
+func (r *repository) TrialBalance(ctx context.Context) (model.TrialBalance, error) {
+	const query = `SELECT currency, COALESCE(system_account, '` + model.AccountWallets + `'), SUM(amount)
+		FROM ledger_entries
+		GROUP BY 1, 2
+		ORDER BY 1, 2`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return model.TrialBalance{}, err
+	}
+	defer rows.Close()
+
+	balance := model.TrialBalance{Currencies: []model.CurrencyBalance{}, Balanced: true}
+	for rows.Next() {
+		var currency, account string
+		var amount decimal.Decimal
+		if err := rows.Scan(&currency, &account, &amount); err != nil {
+			return model.TrialBalance{}, fmt.Errorf("scan trial balance: %w", err)
+		}
+
+		n := len(balance.Currencies)
+		if n == 0 || balance.Currencies[n-1].Currency != currency {
+			balance.Currencies = append(balance.Currencies, model.CurrencyBalance{Currency: currency, Accounts: map[string]decimal.Decimal{}})
+			n++
+		}
+		c := &balance.Currencies[n-1]
+		c.Accounts[account] = amount
+		c.Total = c.Total.Add(amount)
+	}
+	if err := rows.Err(); err != nil {
+		return model.TrialBalance{}, err
+	}
+
+	for _, c := range balance.Currencies {
+		if !c.Total.IsZero() {
+			balance.Balanced = false
+		}
+	}
+	return balance, nil
+}
@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const limitPolicyColumns = `name, currency, max_balance, max_single_amount, daily_deposit, daily_withdrawal, monthly_deposit, monthly_withdrawal`
+
+// UpsertLimitPolicy creates the policy or replaces its limits. The currency of
+// an existing policy cannot change, since wallets may already be assigned to it.
+func (r *repository) UpsertLimitPolicy(ctx context.Context, policy model.LimitPolicy) (model.LimitPolicy, error) {
+	const query = `INSERT INTO limit_policies (` + limitPolicyColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (name) DO UPDATE SET
+			max_balance = EXCLUDED.max_balance,
+			max_single_amount = EXCLUDED.max_single_amount,
+			daily_deposit = EXCLUDED.daily_deposit,
+			daily_withdrawal = EXCLUDED.daily_withdrawal,
+			monthly_deposit = EXCLUDED.monthly_deposit,
+			monthly_withdrawal = EXCLUDED.monthly_withdrawal,
+			updated_at = now()
+		WHERE limit_policies.currency = EXCLUDED.currency
+		RETURNING ` + limitPolicyColumns
+
+	result, err := scanLimitPolicy(r.db.QueryRowContext(ctx, query,
+		policy.Name, policy.Currency,
+		policy.MaxBalance, policy.MaxSingleAmount,
+		policy.DailyDeposit, policy.DailyWithdrawal,
+		policy.MonthlyDeposit, policy.MonthlyWithdrawal,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.LimitPolicy{}, apperror.Errorf(apperror.CodeCurrencyMismatch, "limit policy %s is not in %s", policy.Name, policy.Currency)
+	}
+	if err != nil {
+		return model.LimitPolicy{}, fmt.Errorf("upsert limit policy: %w", err)
+	}
+
+	return result, nil
+}
+
+// SetWalletLimitPolicy assigns the named policy to the wallet, or removes its
+// policy when name is empty.
+func (r *repository) SetWalletLimitPolicy(ctx context.Context, walletId, name string) (wallet model.Wallet, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Wallet{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	wallet, err = lockWallet(ctx, tx, walletId)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	if name != "" {
+		policy, err := getLimitPolicy(ctx, tx, name)
+		if err != nil {
+			return model.Wallet{}, err
+		}
+		if policy.Currency != wallet.Currency {
+			return model.Wallet{}, apperror.ErrCurrencyMismatch
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE wallets SET limit_policy = $1 WHERE id = $2`, nullString(name), wallet.Id); err != nil {
+		return model.Wallet{}, fmt.Errorf("update limit policy: %w", err)
+	}
+
+	wallet.LimitPolicy = name
+	return wallet, nil
+}
+
+// checkLimits enforces the limit policy of wallet on an operation moving amount
+// into (credit) or out of it, or on a hold setting amount aside. wallet must
+// be locked in tx, which keeps the rolling totals exact, and carry the balance
+// the operation leaves behind.
+//
+// Funds set aside by open holds count towards the withdrawal limits as if they
+// were already withdrawn, so a hold is refused when authorizing it would
+// reserve more than the limits leave. wallet.Held must therefore not include a
+// hold that is being captured: its capture counts the captured amount instead.
+func checkLimits(ctx context.Context, tx *sql.Tx, wallet model.Wallet, credit bool, amount decimal.Decimal) error {
+	if wallet.LimitPolicy == "" {
+		return nil
+	}
+
+	policy, err := getLimitPolicy(ctx, tx, wallet.LimitPolicy)
+	if err != nil {
+		return err
+	}
+
+	if policy.MaxSingleAmount.Valid && amount.GreaterThan(policy.MaxSingleAmount.Decimal) {
+		return limitError(apperror.CodeSingleAmountExceeded, "single operation", policy.MaxSingleAmount.Decimal, wallet.Currency)
+	}
+	if credit && policy.MaxBalance.Valid && wallet.Balance.GreaterThan(policy.MaxBalance.Decimal) {
+		return limitError(apperror.CodeMaxBalanceExceeded, "balance", policy.MaxBalance.Decimal, wallet.Currency)
+	}
+
+	type rollingLimit struct {
+		limit  decimal.NullDecimal
+		window time.Duration
+		code   apperror.Code
+		name   string
+	}
+	types := model.WithdrawalOperationTypes
+	reserved := wallet.Held
+	limits := []rollingLimit{
+		{policy.DailyWithdrawal, model.DailyLimitWindow, apperror.CodeDailyWithdrawalExceeded, "daily withdrawal"},
+		{policy.MonthlyWithdrawal, model.MonthlyLimitWindow, apperror.CodeMonthlyWithdrawalExceeded, "monthly withdrawal"},
+	}
+	if credit {
+		types = model.DepositOperationTypes
+		reserved = decimal.Zero
+		limits = []rollingLimit{
+			{policy.DailyDeposit, model.DailyLimitWindow, apperror.CodeDailyDepositExceeded, "daily deposit"},
+			{policy.MonthlyDeposit, model.MonthlyLimitWindow, apperror.CodeMonthlyDepositExceeded, "monthly deposit"},
+		}
+	}
+
+	for _, l := range limits {
+		if !l.limit.Valid {
+			continue
+		}
+		total, err := operationTotal(ctx, tx, wallet.Id, types, l.window)
+		if err != nil {
+			return err
+		}
+		if total.Add(reserved).Add(amount).GreaterThan(l.limit.Decimal) {
+			return limitError(l.code, l.name, l.limit.Decimal, wallet.Currency)
+		}
+	}
+
+	return nil
+}
+
+// operationTotal sums the amounts of the wallet's operations of the given
+// types over the trailing window.
+func operationTotal(ctx context.Context, tx *sql.Tx, walletId string, types []string, window time.Duration) (decimal.Decimal, error) {
+	const query = `SELECT COALESCE(SUM(amount), 0) FROM operations
+		WHERE wallet_id = $1 AND operation_type = ANY($2) AND created_at > now() - $3::interval`
+
+	var total decimal.Decimal
+	err := tx.QueryRowContext(ctx, query, walletId, pq.Array(types), interval(window)).Scan(&total)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("sum operations: %w", err)
+	}
+	return total, nil
+}
+
+func limitError(code apperror.Code, name string, limit decimal.Decimal, currency string) error {
+	return apperror.Errorf(code, "%s limit of %s %s exceeded", name, model.FormatAmount(limit, currency), currency)
+}
+
+func getLimitPolicy(ctx context.Context, tx *sql.Tx, name string) (model.LimitPolicy, error) {
+	const query = `SELECT ` + limitPolicyColumns + ` FROM limit_policies WHERE name = $1`
+
+	policy, err := scanLimitPolicy(tx.QueryRowContext(ctx, query, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.LimitPolicy{}, apperror.ErrLimitPolicyNotFound
+	}
+	if err != nil {
+		return model.LimitPolicy{}, fmt.Errorf("get limit policy: %w", err)
+	}
+	return policy, nil
+}
+
+func scanLimitPolicy(row rowScanner) (model.LimitPolicy, error) {
+	var p model.LimitPolicy
+	err := row.Scan(&p.Name, &p.Currency, &p.MaxBalance, &p.MaxSingleAmount, &p.DailyDeposit, &p.DailyWithdrawal, &p.MonthlyDeposit, &p.MonthlyWithdrawal)
+	if err != nil {
+		return model.LimitPolicy{}, err
+	}
+	return p, nil
+}
@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const operationColumns = `id, wallet_id, operation_type, amount, currency, balance_after, transfer_id, reference_id, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// insertOperation appends op to the ledger, fills in its generated id and
+// timestamp and queues an EventBalanceChanged for it in the outbox.
+// BalanceAfter is written with the precision of the operation currency.
+func insertOperation(ctx context.Context, tx *sql.Tx, op *model.Operation, idempotencyKey, requestHash string) error {
+	const query = `INSERT INTO operations (wallet_id, operation_type, amount, currency, balance_after, transfer_id, reference_id, idempotency_key, request_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at`
+
+	err := tx.QueryRowContext(ctx, query,
+		op.WalletId, op.OperationType, op.Amount.String(), op.Currency, model.FormatAmount(op.BalanceAfter, op.Currency),
+		nullString(op.TransferId), nullString(op.ReferenceId), nullString(idempotencyKey), nullString(requestHash),
+	).Scan(&op.Id, &op.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return insertEvent(ctx, tx, model.EventBalanceChanged, op.WalletId, op)
+}
+
+func getOperationByIdempotencyKey(ctx context.Context, tx *sql.Tx, key string) (model.Operation, string, error) {
+	const query = `SELECT ` + operationColumns + `, request_hash FROM operations WHERE idempotency_key = $1`
+
+	var requestHash string
+	op, err := scanOperation(tx.QueryRowContext(ctx, query, key), &requestHash)
+	if err != nil {
+		return model.Operation{}, "", err
+	}
+
+	return op, requestHash, nil
+}
+
+// getOperation looks up a single ledger row. Rows are never updated, so no
+// lock is taken.
+func getOperation(ctx context.Context, tx *sql.Tx, id string) (model.Operation, error) {
+	const query = `SELECT ` + operationColumns + ` FROM operations WHERE id = $1`
+
+	op, err := scanOperation(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return model.Operation{}, operationError(err)
+	}
+	return op, nil
+}
+
+// refundedAmount sums the refunds already written against the operation id.
+func refundedAmount(ctx context.Context, tx *sql.Tx, id string) (decimal.Decimal, error) {
+	const query = `SELECT COALESCE(SUM(amount), 0) FROM operations WHERE reference_id = $1 AND operation_type = $2`
+
+	var total decimal.Decimal
+	if err := tx.QueryRowContext(ctx, query, id, model.TransactionRefund).Scan(&total); err != nil {
+		return decimal.Zero, fmt.Errorf("sum refunds: %w", err)
+	}
+	return total, nil
+}
+
+func (r *repository) GetOperationsByWalletUuid(ctx context.Context, uuid string) ([]model.Operation, error) {
+	const query = `SELECT ` + operationColumns + `
+		FROM operations WHERE wallet_id = $1 ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOperations(rows)
+}
+
+func (r *repository) ListOperations(ctx context.Context, filter model.OperationFilter) ([]model.Operation, error) {
+	var conditions []string
+	var args []any
+
+	// An empty wallet id lists the operations of every wallet.
+	if filter.WalletId != "" {
+		args = append(args, filter.WalletId)
+		conditions = append(conditions, fmt.Sprintf("wallet_id = $%d", len(args)))
+	}
+	if filter.OperationType != "" {
+		args = append(args, filter.OperationType)
+		conditions = append(conditions, fmt.Sprintf("operation_type = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if filter.Cursor != nil {
+		args = append(args, filter.Cursor.CreatedAt, filter.Cursor.Id)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	query := `SELECT ` + operationColumns + `
+		FROM operations`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOperations(rows)
+}
+
+func scanOperation(row rowScanner, extra ...any) (model.Operation, error) {
+	var op model.Operation
+	var transferId, referenceId sql.NullString
+
+	dest := append([]any{&op.Id, &op.WalletId, &op.OperationType, &op.Amount, &op.Currency, &op.BalanceAfter, &transferId, &referenceId, &op.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return model.Operation{}, err
+	}
+	op.TransferId = transferId.String
+	op.ReferenceId = referenceId.String
+
+	return op, nil
+}
+
+func scanOperations(rows *sql.Rows) ([]model.Operation, error) {
+	defer rows.Close()
+
+	operations := []model.Operation{}
+	for rows.Next() {
+		op, err := scanOperation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan operation: %w", err)
+		}
+		operations = append(operations, op)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return operations, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// operationError is the operation counterpart of walletError.
+func operationError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperror.ErrOperationNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == invalidTextRepresentation {
+		return apperror.ErrOperationNotFound
+	}
+	return err
+}
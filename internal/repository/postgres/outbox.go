@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+	"wallet-service/internal/model"
+)
+
+// ClaimEvents picks up to limit unpublished events that are due and hides them
+// from other relays for lease. An event that is neither published nor
+// rescheduled before the lease runs out is claimed again, which is what makes
+// delivery at-least-once.
+func (r *repository) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]model.Event, error) {
+	const query = `UPDATE outbox_events SET attempts = attempts + 1, next_attempt_at = now() + $2::interval
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE published_at IS NULL AND next_attempt_at <= now()
+			ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, wallet_id, payload, created_at, attempts`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, interval(lease))
+	if err != nil {
+		return nil, fmt.Errorf("claim events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []model.Event{}
+	for rows.Next() {
+		var event model.Event
+		var payload []byte
+		if err := rows.Scan(&event.Id, &event.Type, &event.WalletId, &payload, &event.CreatedAt, &event.Attempts); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the subquery.
+	sort.Slice(events, func(i, j int) bool { return events[i].Id < events[j].Id })
+	return events, nil
+}
+
+// MarkEventPublished records that the event has been delivered.
+func (r *repository) MarkEventPublished(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE outbox_events SET published_at = now(), last_error = NULL WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("mark event published: %w", err)
+	}
+	return nil
+}
+
+// RetryEvent reschedules a failed delivery for at.
+func (r *repository) RetryEvent(ctx context.Context, id int64, at time.Time, cause string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE outbox_events SET next_attempt_at = $1, last_error = $2 WHERE id = $3`, at, cause, id)
+	if err != nil {
+		return fmt.Errorf("reschedule event: %w", err)
+	}
+	return nil
+}
+
+// insertEvent adds an event to the outbox in tx, so it is published if and
+// only if tx commits.
+func insertEvent(ctx context.Context, tx *sql.Tx, eventType, walletId string, payload any) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO outbox_events (event_type, wallet_id, payload) VALUES ($1, $2, $3)`,
+		eventType, walletId, string(encoded))
+	if err != nil {
+		return fmt.Errorf("insert event: %w", err)
+	}
+	return nil
+}
+
+// interval renders d as a Postgres interval literal.
+func interval(d time.Duration) string {
+	return fmt.Sprintf("%d seconds", int64(d.Seconds()))
+}
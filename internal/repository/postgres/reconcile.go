@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+)
+
+// Reconcile compares the stored balance of every wallet with the sum of its
+// operations, both read from one snapshot so that operations committed
+// meanwhile cannot show up as drift, and records the run with its
+// mismatches. Balances are overwritten with the ledger value only when fix
+// is set; a wallet whose balance cannot be fixed, for example because the
+// ledger value breaks its credit limit, is recorded as not fixed.
+func (r *repository) Reconcile(ctx context.Context, fix bool) (run model.ReconciliationRun, err error) {
+	run = model.ReconciliationRun{StartedAt: time.Now(), Fix: fix, Mismatches: []model.BalanceMismatch{}}
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return model.ReconciliationRun{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err = tx.QueryRowContext(ctx, `SELECT count(*) FROM wallets`).Scan(&run.WalletsChecked); err != nil {
+		return model.ReconciliationRun{}, fmt.Errorf("count wallets: %w", err)
+	}
+
+	const query = `SELECT w.id, w.currency, w.balance, COALESCE(l.balance, 0)
+		FROM wallets w
+		LEFT JOIN (
+			SELECT o.wallet_id, SUM(` + operationChange + `) AS balance
+			FROM operations o LEFT JOIN operations r ON r.id = o.reference_id
+			GROUP BY o.wallet_id
+		) l ON l.wallet_id = w.id
+		WHERE w.balance <> COALESCE(l.balance, 0)
+		ORDER BY w.id`
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return model.ReconciliationRun{}, fmt.Errorf("compare balances: %w", err)
+	}
+	for rows.Next() {
+		var m model.BalanceMismatch
+		if err = rows.Scan(&m.WalletId, &m.Currency, &m.StoredBalance, &m.LedgerBalance); err != nil {
+			rows.Close()
+			return model.ReconciliationRun{}, fmt.Errorf("scan mismatch: %w", err)
+		}
+		m.Difference = m.StoredBalance.Sub(m.LedgerBalance)
+		run.Mismatches = append(run.Mismatches, m)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return model.ReconciliationRun{}, err
+	}
+	run.MismatchCount = len(run.Mismatches)
+
+	if fix {
+		for i := range run.Mismatches {
+			if run.Mismatches[i].Fixed, err = fixBalance(ctx, tx, run.Mismatches[i]); err != nil {
+				return model.ReconciliationRun{}, err
+			}
+		}
+	}
+
+	const insertRun = `INSERT INTO reconciliation_runs (started_at, wallets_checked, mismatches, fix)
+		VALUES ($1, $2, $3, $4) RETURNING id, finished_at`
+	if err = tx.QueryRowContext(ctx, insertRun, run.StartedAt, run.WalletsChecked, run.MismatchCount, fix).Scan(&run.Id, &run.FinishedAt); err != nil {
+		return model.ReconciliationRun{}, fmt.Errorf("insert reconciliation run: %w", err)
+	}
+
+	const insertMismatch = `INSERT INTO reconciliation_mismatches (run_id, wallet_id, currency, stored_balance, ledger_balance, fixed)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	for _, m := range run.Mismatches {
+		if _, err = tx.ExecContext(ctx, insertMismatch, run.Id, m.WalletId, m.Currency, m.StoredBalance.String(), m.LedgerBalance.String(), m.Fixed); err != nil {
+			return model.ReconciliationRun{}, fmt.Errorf("insert reconciliation mismatch: %w", err)
+		}
+	}
+
+	return run, nil
+}
+
+// fixBalance overwrites the stored balance with the ledger value inside a
+// savepoint, so that a wallet the database refuses to fix does not abort the
+// run. The returned error is set only when the savepoint itself fails.
+func fixBalance(ctx context.Context, tx *sql.Tx, m model.BalanceMismatch) (bool, error) {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT reconcile_fix"); err != nil {
+		return false, fmt.Errorf("create savepoint: %w", err)
+	}
+
+	const query = `UPDATE wallets SET balance = $1 WHERE id = $2 AND balance = $3`
+	res, err := tx.ExecContext(ctx, query, m.LedgerBalance.String(), m.WalletId, m.StoredBalance.String())
+	if err == nil {
+		var n int64
+		if n, err = res.RowsAffected(); err == nil && n == 0 {
+			err = errors.New("balance changed since it was read")
+		}
+	}
+	if err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT reconcile_fix"); rbErr != nil {
+			return false, fmt.Errorf("rollback to savepoint: %w", rbErr)
+		}
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT reconcile_fix"); err != nil {
+		return false, fmt.Errorf("release savepoint: %w", err)
+	}
+	return true, nil
+}
+
+func (r *repository) ListReconciliationRuns(ctx context.Context, limit int) ([]model.ReconciliationRun, error) {
+	const query = `SELECT id, started_at, finished_at, wallets_checked, mismatches, fix
+		FROM reconciliation_runs ORDER BY id DESC LIMIT $1`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []model.ReconciliationRun{}
+	for rows.Next() {
+		run, err := scanReconciliationRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan reconciliation run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetReconciliationRun returns a run together with its mismatches.
+func (r *repository) GetReconciliationRun(ctx context.Context, id int64) (model.ReconciliationRun, error) {
+	const runQuery = `SELECT id, started_at, finished_at, wallets_checked, mismatches, fix
+		FROM reconciliation_runs WHERE id = $1`
+
+	run, err := scanReconciliationRun(r.db.QueryRowContext(ctx, runQuery, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ReconciliationRun{}, apperror.ErrRunNotFound
+	}
+	if err != nil {
+		return model.ReconciliationRun{}, err
+	}
+
+	const mismatchQuery = `SELECT wallet_id, currency, stored_balance, ledger_balance, fixed
+		FROM reconciliation_mismatches WHERE run_id = $1 ORDER BY wallet_id`
+
+	rows, err := r.db.QueryContext(ctx, mismatchQuery, id)
+	if err != nil {
+		return model.ReconciliationRun{}, err
+	}
+	defer rows.Close()
+
+	run.Mismatches = []model.BalanceMismatch{}
+	for rows.Next() {
+		var m model.BalanceMismatch
+		if err := rows.Scan(&m.WalletId, &m.Currency, &m.StoredBalance, &m.LedgerBalance, &m.Fixed); err != nil {
+			return model.ReconciliationRun{}, fmt.Errorf("scan mismatch: %w", err)
+		}
+		m.Difference = m.StoredBalance.Sub(m.LedgerBalance)
+		run.Mismatches = append(run.Mismatches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return model.ReconciliationRun{}, err
+	}
+
+	return run, nil
+}
+
+func scanReconciliationRun(row rowScanner) (model.ReconciliationRun, error) {
+	var run model.ReconciliationRun
+	err := row.Scan(&run.Id, &run.StartedAt, &run.FinishedAt, &run.WalletsChecked, &run.MismatchCount, &run.Fix)
+	return run, err
+}
@@ -3,19 +3,76 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"time"
+	"wallet-service/internal/apperror"
 	"wallet-service/internal/model"
 
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 )
 
 type Repository interface {
-	CreateWallet(ctx context.Context, uuid string) error
-	GetBalanceByUuid(ctx context.Context, uuid string) (decimal.Decimal, error)
-	Transaction(ctx context.Context, uuid string, amount decimal.Decimal, op string) error
+	CreateWallet(ctx context.Context, wallet model.Wallet, uniquePerOwner bool) error
+	GetWalletByUuid(ctx context.Context, uuid string) (model.Wallet, error)
+	ListWalletsByOwner(ctx context.Context, ownerId string) ([]model.Wallet, error)
+	UpsertLimitPolicy(ctx context.Context, policy model.LimitPolicy) (model.LimitPolicy, error)
+	SetWalletLimitPolicy(ctx context.Context, walletId, name string) (model.Wallet, error)
+	SetWalletCreditLimit(ctx context.Context, walletId string, limit decimal.Decimal) (model.Wallet, error)
+	Transaction(ctx context.Context, transaction model.Transaction) (model.Operation, error)
+	Batch(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) ([]model.BatchItemResult, error)
+	Transfer(ctx context.Context, transfer model.Transfer) (model.Transfer, error)
+	GetOperationsByWalletUuid(ctx context.Context, uuid string) ([]model.Operation, error)
+	ListOperations(ctx context.Context, filter model.OperationFilter) ([]model.Operation, error)
+	GetStatement(ctx context.Context, walletId string, from, to time.Time) (model.Statement, error)
+	GetBalanceAsOf(ctx context.Context, walletId string, at time.Time) (model.HistoricalBalance, error)
+	Reconcile(ctx context.Context, fix bool) (model.ReconciliationRun, error)
+	ListReconciliationRuns(ctx context.Context, limit int) ([]model.ReconciliationRun, error)
+	GetReconciliationRun(ctx context.Context, id int64) (model.ReconciliationRun, error)
+	UpsertFeeRule(ctx context.Context, rule model.FeeRule) (model.FeeRule, error)
+	ListFeeRules(ctx context.Context) ([]model.FeeRule, error)
+	GetFeeRule(ctx context.Context, operationType, currency string) (model.FeeRule, error)
+	DeleteFeeRule(ctx context.Context, operationType, currency string) error
+	CreateSchedule(ctx context.Context, schedule model.Schedule) (model.Schedule, error)
+	GetSchedule(ctx context.Context, id string) (model.Schedule, error)
+	ListSchedules(ctx context.Context, walletId, status string) ([]model.Schedule, error)
+	CancelSchedule(ctx context.Context, id string) (model.Schedule, error)
+	ClaimDueSchedules(ctx context.Context, limit int, lease time.Duration) ([]model.Schedule, error)
+	SkipScheduleRuns(ctx context.Context, id string, to time.Time) error
+	CompleteScheduleRun(ctx context.Context, id, operationId string, next *time.Time) error
+	RetryScheduleRun(ctx context.Context, id string, at time.Time, cause string) error
+	FailScheduleRun(ctx context.Context, id string, cause string, next *time.Time) error
+	TrialBalance(ctx context.Context) (model.TrialBalance, error)
+	AuthorizeHold(ctx context.Context, hold model.Hold) (model.Hold, error)
+	CaptureHold(ctx context.Context, id string, amount decimal.Decimal) (model.Hold, error)
+	VoidHold(ctx context.Context, id string) (model.Hold, error)
+	ExpireHolds(ctx context.Context, now time.Time, limit int) (int, error)
+	ChangeWalletStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error)
+	ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]model.Event, error)
+	MarkEventPublished(ctx context.Context, id int64) error
+	RetryEvent(ctx context.Context, id int64, at time.Time, cause string) error
+	CreateWebhookSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id string) error
+	ListWebhookDeliveries(ctx context.Context, subscriptionId, status string) ([]model.WebhookDelivery, error)
+	RedeliverWebhook(ctx context.Context, id string) (model.WebhookDelivery, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	MarkWebhookDelivered(ctx context.Context, id string) error
+	RetryWebhookDelivery(ctx context.Context, id string, at time.Time, cause string) error
+	DeadLetterWebhookDelivery(ctx context.Context, id string, cause string) error
 }
+
+// invalidTextRepresentation is the SQLSTATE Postgres reports when a value,
+// such as a malformed UUID, cannot be parsed into the column type.
+const invalidTextRepresentation = "22P02"
+
+// uniqueViolation is the SQLSTATE of an insert that breaks a unique index.
+const uniqueViolation = "23505"
+
 type repository struct {
 	db     *sql.DB
 	logger *slog.Logger
@@ -28,71 +85,462 @@ func NewRepository(db *sql.DB, logger *slog.Logger) Repository {
 	}
 }
 
-var (
-	ErrWalletNotFound      = errors.New("wallet not found")
-	ErrInsufficientBalance = errors.New("insufficient balance")
-)
-
-func (r *repository) CreateWallet(ctx context.Context, uuid string) error {
-	const query = `INSERT INTO wallets (id) VALUES ($1)`
+// CreateWallet inserts the wallet. With uniquePerOwner set, it refuses to give
+// an owner a second open wallet in the same currency; the check is serialized
+// per owner with a transaction-scoped advisory lock.
+func (r *repository) CreateWallet(ctx context.Context, wallet model.Wallet, uniquePerOwner bool) (err error) {
+	const query = `INSERT INTO wallets (id, currency, owner_id, label, metadata) VALUES ($1, $2, $3, $4, $5)`
 
-	_, err := r.db.ExecContext(ctx, query, uuid)
+	metadata, err := encodeMetadata(wallet.Metadata)
 	if err != nil {
 		return err
 	}
+	args := []any{wallet.Id, wallet.Currency, nullString(wallet.OwnerId), wallet.Label, metadata}
 
-	return nil
+	if !uniquePerOwner || wallet.OwnerId == "" {
+		_, err = r.db.ExecContext(ctx, query, args...)
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, wallet.OwnerId); err != nil {
+		return fmt.Errorf("lock owner: %w", err)
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM wallets WHERE owner_id = $1 AND currency = $2 AND status <> $3)`,
+		wallet.OwnerId, wallet.Currency, model.WalletClosed).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check owner wallets: %w", err)
+	}
+	if exists {
+		return apperror.ErrWalletExists
+	}
 
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
 }
 
-func (r *repository) GetBalanceByUuid(ctx context.Context, uuid string) (decimal.Decimal, error) {
-	const query = `SELECT balance FROM wallets WHERE id = $1`
+func (r *repository) GetWalletByUuid(ctx context.Context, uuid string) (model.Wallet, error) {
+	const query = `SELECT ` + walletColumns + ` FROM wallets WHERE id = $1`
 
-	var balance decimal.Decimal
-	err := r.db.QueryRowContext(ctx, query, uuid).Scan(&balance)
+	wallet, err := scanWallet(r.db.QueryRowContext(ctx, query, uuid))
 	if err != nil {
-		return decimal.Zero, err
+		return model.Wallet{}, walletError(err)
+	}
+
+	return wallet, nil
+
+}
+
+func (r *repository) ListWalletsByOwner(ctx context.Context, ownerId string) ([]model.Wallet, error) {
+	const query = `SELECT ` + walletColumns + ` FROM wallets WHERE owner_id = $1 ORDER BY currency, id`
+
+	rows, err := r.db.QueryContext(ctx, query, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wallets := []model.Wallet{}
+	for rows.Next() {
+		wallet, err := scanWallet(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan wallet: %w", err)
+		}
+		wallets = append(wallets, wallet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return balance, nil
+	return wallets, nil
+}
 
+// Transaction applies a deposit or withdrawal with a single guarded UPDATE of
+// the balance where it can, and otherwise, like refunds, under a lock on the
+// wallet that lets every check run in turn and report why it failed.
+func (r *repository) Transaction(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+	op, err := r.transaction(ctx, transaction)
+	if errors.Is(err, errKeyClaimed) {
+		// The key is committed by now, so this attempt replays it.
+		return r.transaction(ctx, transaction)
+	}
+	return op, err
 }
 
-func (r *repository) Transaction(ctx context.Context, uuid string, amount decimal.Decimal, op string) (err error) {
+func (r *repository) transaction(ctx context.Context, transaction model.Transaction) (op model.Operation, err error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+		return model.Operation{}, fmt.Errorf("begin transaction: %w", err)
 	}
 
 	defer func() {
 		if err != nil {
 			_ = tx.Rollback()
 		} else {
-			_ = tx.Commit()
+			err = tx.Commit()
 		}
 	}()
 
-	var balance decimal.Decimal
-	err = tx.QueryRowContext(ctx, "SELECT balance FROM wallets WHERE id = $1 FOR UPDATE", uuid).Scan(&balance)
+	if transaction.OperationType != model.TransactionRefund {
+		var applied bool
+		if op, applied, err = applyInPlace(ctx, tx, transaction); err != nil || applied {
+			return op, err
+		}
+	}
+	return applyTransaction(ctx, tx, transaction)
+}
+
+// errKeyClaimed means a concurrent request inserted the idempotency key after
+// applyInPlace looked it up. The unique violation aborts tx, so the request is
+// retried in a new one.
+var errKeyClaimed = errors.New("idempotency key claimed concurrently")
+
+// minorUnitsSQL is the number of digits of the minor unit of the currency
+// column, as model.MinorUnits gives it.
+var minorUnitsSQL = func() string {
+	var b strings.Builder
+	b.WriteString("CASE currency")
+	for _, code := range model.Currencies() {
+		if units := model.MinorUnits(code); units != 2 {
+			fmt.Fprintf(&b, " WHEN '%s' THEN %d", code, units)
+		}
+	}
+	b.WriteString(" ELSE 2 END")
+	return b.String()
+}()
+
+// inPlaceGuard matches the wallet $3 when the checks of applyTransaction that
+// do not depend on the direction of the operation pass: the expected currency
+// $4 and version $5, and an amount $1 already in the minor unit of the wallet
+// currency. Wallets with a limit policy are left to applyTransaction.
+var inPlaceGuard = `id = $3 AND limit_policy IS NULL
+	AND ($4::text = '' OR currency = $4) AND ($5::bigint = 0 OR version = $5)
+	AND $1::numeric > 0 AND round($1::numeric, ` + minorUnitsSQL + `) = $1::numeric`
+
+var (
+	creditInPlaceQuery = `UPDATE wallets SET balance = balance + $1::numeric - $2::numeric
+		WHERE ` + inPlaceGuard + ` AND (status = 'ACTIVE' OR status = 'FROZEN' AND allow_deposits)
+		RETURNING ` + walletColumns
+	debitInPlaceQuery = `UPDATE wallets SET balance = balance - $1::numeric - $2::numeric
+		WHERE ` + inPlaceGuard + ` AND status = 'ACTIVE' AND balance - held + credit_limit >= $1::numeric + $2::numeric
+		RETURNING ` + walletColumns
+)
+
+// applyInPlace applies a deposit or withdrawal with a single UPDATE whose
+// WHERE clause only matches when applyTransaction would let it through, so
+// the balance changes without reading the wallet first. It reports false,
+// having changed nothing, when the wallet is missing or the UPDATE does not
+// match; applyTransaction then runs in the same tx and tells why. Replays of
+// an idempotency key are left to it as well.
+func applyInPlace(ctx context.Context, tx *sql.Tx, transaction model.Transaction) (model.Operation, bool, error) {
+	if transaction.IdempotencyKey != "" {
+		_, _, err := getOperationByIdempotencyKey(ctx, tx, transaction.IdempotencyKey)
+		if err == nil {
+			return model.Operation{}, false, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return model.Operation{}, false, fmt.Errorf("get operation by idempotency key: %w", err)
+		}
+	}
+
+	credit := transaction.OperationType == model.TransactionDeposit
+	query := debitInPlaceQuery
+	if credit {
+		query = creditInPlaceQuery
+	}
+	fee := transaction.Fee
+	wallet, err := scanWallet(tx.QueryRowContext(ctx, query,
+		transaction.Amount.String(), fee.String(), transaction.Uuid, transaction.Currency, transaction.ExpectedVersion,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Operation{}, false, nil
+	}
+	if err != nil {
+		return model.Operation{}, false, fmt.Errorf("update balance: %w", walletError(err))
+	}
+
+	op := model.Operation{
+		WalletId:      wallet.Id,
+		OperationType: transaction.OperationType,
+		Amount:        model.RoundAmount(transaction.Amount, wallet.Currency),
+		Currency:      wallet.Currency,
+		BalanceAfter:  wallet.Balance.Add(fee),
+	}
+
+	account := model.AccountCashOut
+	if credit {
+		account = model.AccountCashIn
+	}
+	err = recordOperation(ctx, tx, wallet, &op, credit, account, fee, transaction.IdempotencyKey, transaction.Hash())
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return model.Operation{}, false, errKeyClaimed
+	}
 	if err != nil {
-		return fmt.Errorf("get balance: %w", err)
+		return model.Operation{}, false, err
 	}
 
-	if op == model.TransactionWithdraw && balance.LessThan(amount) {
-		err = fmt.Errorf("balance is not enough")
-		return err
+	return op, true, nil
+}
+
+// applyTransaction applies a single deposit, withdrawal or refund in tx,
+// locking the wallet for the rest of it.
+func applyTransaction(ctx context.Context, tx *sql.Tx, transaction model.Transaction) (op model.Operation, err error) {
+	var original model.Operation
+	if transaction.OperationType == model.TransactionRefund {
+		if original, err = getOperation(ctx, tx, transaction.ReferenceId); err != nil {
+			return model.Operation{}, err
+		}
+		if transaction.Uuid == "" {
+			transaction.Uuid = original.WalletId
+		}
+		if transaction.Uuid != original.WalletId {
+			return model.Operation{}, apperror.Errorf(apperror.CodeValidation, "operation %s does not belong to wallet %s", original.Id, transaction.Uuid)
+		}
+	}
+
+	wallet, err := lockWallet(ctx, tx, transaction.Uuid)
+	if err != nil {
+		return model.Operation{}, err
+	}
+
+	requestHash := transaction.Hash()
+	if transaction.IdempotencyKey != "" {
+		stored, storedHash, lookupErr := getOperationByIdempotencyKey(ctx, tx, transaction.IdempotencyKey)
+		switch {
+		case lookupErr == nil && storedHash != requestHash:
+			return model.Operation{}, apperror.ErrIdempotencyKeyConflict
+		case lookupErr == nil:
+			stored.Fee, err = getFeeOperation(ctx, tx, stored.Id)
+			return stored, err
+		case !errors.Is(lookupErr, sql.ErrNoRows):
+			return model.Operation{}, fmt.Errorf("get operation by idempotency key: %w", lookupErr)
+		}
 	}
 
-	if op == model.TransactionDeposit {
-		balance = balance.Add(amount)
+	// Checked after the replay, so that retrying a request that went through
+	// returns its result rather than failing on the version it bumped.
+	if transaction.ExpectedVersion != 0 && transaction.ExpectedVersion != wallet.Version {
+		return model.Operation{}, apperror.ErrVersionMismatch
+	}
+	if transaction.Currency != "" && transaction.Currency != wallet.Currency {
+		return model.Operation{}, apperror.ErrCurrencyMismatch
+	}
+
+	credit := transaction.OperationType == model.TransactionDeposit
+	var amount decimal.Decimal
+	if transaction.OperationType == model.TransactionRefund {
+		// A refund moves money the opposite way to the operation it undoes.
+		credit = original.OperationType == model.TransactionWithdraw
+		amount, err = refundAmount(ctx, tx, original, transaction.Amount)
 	} else {
-		balance = balance.Sub(amount)
+		amount, err = roundAmount(transaction.Amount, wallet.Currency)
+	}
+	if err != nil {
+		return model.Operation{}, err
 	}
 
-	_, err = tx.ExecContext(ctx, "UPDATE wallets SET balance = $1 WHERE id = $2", balance.StringFixed(2), uuid)
+	if credit {
+		err = wallet.CheckCredit()
+	} else {
+		err = wallet.CheckDebit()
+	}
 	if err != nil {
-		return fmt.Errorf("update balance: %w", err)
+		return model.Operation{}, err
+	}
+
+	fee := transaction.Fee
+	if !credit && wallet.Available().LessThan(amount.Add(fee)) {
+		return model.Operation{}, apperror.ErrInsufficientFunds
+	}
+
+	if credit {
+		wallet.Balance = wallet.Balance.Add(amount)
+	} else {
+		wallet.Balance = wallet.Balance.Sub(amount)
+	}
+	balanceAfter := wallet.Balance
+	wallet.Balance = wallet.Balance.Sub(fee)
+
+	// Refunds undo an operation that was already held to the limits.
+	if transaction.OperationType != model.TransactionRefund {
+		if err = checkLimits(ctx, tx, wallet, credit, amount); err != nil {
+			return model.Operation{}, err
+		}
+	}
+
+	if err = updateBalance(ctx, tx, wallet); err != nil {
+		return model.Operation{}, err
+	}
+
+	op = model.Operation{
+		WalletId:      wallet.Id,
+		OperationType: transaction.OperationType,
+		Amount:        amount,
+		Currency:      wallet.Currency,
+		BalanceAfter:  balanceAfter,
+		ReferenceId:   transaction.ReferenceId,
+	}
+
+	// A refund goes back through the system account of the operation it undoes.
+	account := model.AccountCashOut
+	if transaction.OperationType == model.TransactionDeposit || original.OperationType == model.TransactionDeposit {
+		account = model.AccountCashIn
+	}
+	if err = recordOperation(ctx, tx, wallet, &op, credit, account, fee, transaction.IdempotencyKey, requestHash); err != nil {
+		return model.Operation{}, err
+	}
+
+	return op, nil
+}
+
+// recordOperation writes op, which took wallet to its current balance along
+// with fee, books it against the system account and queues its webhooks.
+func recordOperation(ctx context.Context, tx *sql.Tx, wallet model.Wallet, op *model.Operation, credit bool, account string, fee decimal.Decimal, idempotencyKey, requestHash string) error {
+	if err := insertOperation(ctx, tx, op, idempotencyKey, requestHash); err != nil {
+		return fmt.Errorf("insert operation: %w", err)
+	}
+
+	entry := model.WalletEntry(*op, credit)
+	entries := []model.LedgerEntry{entry, model.SystemEntry(account, entry)}
+	if fee.IsPositive() {
+		feeEntries, err := chargeFee(ctx, tx, wallet, op, fee)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, feeEntries...)
+	}
+	if err := postEntries(ctx, tx, op.Id, entries); err != nil {
+		return err
+	}
+
+	balanceBefore := op.BalanceAfter.Add(op.Amount)
+	if credit {
+		balanceBefore = op.BalanceAfter.Sub(op.Amount)
+	}
+	return enqueueWebhooks(ctx, tx, *op, balanceBefore)
+}
+
+const walletColumns = `id, balance, held, currency, status, allow_deposits, owner_id, label, metadata, limit_policy, credit_limit, version`
+
+func scanWallet(row rowScanner) (model.Wallet, error) {
+	var wallet model.Wallet
+	var ownerId, limitPolicy sql.NullString
+	var metadata []byte
+
+	err := row.Scan(&wallet.Id, &wallet.Balance, &wallet.Held, &wallet.Currency, &wallet.Status, &wallet.AllowDeposits,
+		&ownerId, &wallet.Label, &metadata, &limitPolicy, &wallet.CreditLimit, &wallet.Version)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+	wallet.OwnerId = ownerId.String
+	wallet.LimitPolicy = limitPolicy.String
+
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &wallet.Metadata); err != nil {
+			return model.Wallet{}, fmt.Errorf("decode metadata: %w", err)
+		}
+	}
+	return wallet, nil
+}
+
+// encodeMetadata renders metadata as JSON text. It is passed as a string
+// because lib/pq sends []byte parameters in binary, which jsonb rejects.
+func encodeMetadata(metadata map[string]any) (string, error) {
+	if metadata == nil {
+		return "{}", nil
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("encode metadata: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// lockWallet takes a row lock on the wallet for the rest of tx and returns its current state.
+func lockWallet(ctx context.Context, tx *sql.Tx, uuid string) (model.Wallet, error) {
+	const query = `SELECT ` + walletColumns + ` FROM wallets WHERE id = $1 FOR UPDATE`
+
+	wallet, err := scanWallet(tx.QueryRowContext(ctx, query, uuid))
+	if err != nil {
+		return model.Wallet{}, fmt.Errorf("get balance: %w", walletError(err))
 	}
+	return wallet, nil
+}
 
+func updateBalance(ctx context.Context, tx *sql.Tx, wallet model.Wallet) error {
+	_, err := tx.ExecContext(ctx, "UPDATE wallets SET balance = $1 WHERE id = $2", model.FormatAmount(wallet.Balance, wallet.Currency), wallet.Id)
+	if err != nil {
+		return fmt.Errorf("update balance: %w", err)
+	}
 	return nil
 }
+
+// roundAmount rounds amount to the minor unit of currency and rejects amounts
+// that vanish in the process.
+func roundAmount(amount decimal.Decimal, currency string) (decimal.Decimal, error) {
+	rounded := model.RoundAmount(amount, currency)
+	if !rounded.IsPositive() {
+		return decimal.Zero, apperror.Errorf(apperror.CodeValidation, "amount is smaller than the minor unit of %s", currency)
+	}
+	return rounded, nil
+}
+
+// refundAmount resolves how much of original to refund: all that is left of it
+// when amount is zero, otherwise amount rounded to the operation currency.
+// It must run while the wallet of original is locked, so that concurrent
+// refunds of the same operation are serialized.
+func refundAmount(ctx context.Context, tx *sql.Tx, original model.Operation, amount decimal.Decimal) (decimal.Decimal, error) {
+	if !model.IsRefundable(original.OperationType) {
+		return decimal.Zero, apperror.Errorf(apperror.CodeValidation, "%s operations cannot be refunded", original.OperationType)
+	}
+
+	refunded, err := refundedAmount(ctx, tx, original.Id)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	remaining := original.Amount.Sub(refunded)
+
+	if amount.IsZero() {
+		if !remaining.IsPositive() {
+			return decimal.Zero, apperror.ErrRefundExceeded
+		}
+		return remaining, nil
+	}
+
+	amount, err = roundAmount(amount, original.Currency)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if amount.GreaterThan(remaining) {
+		return decimal.Zero, apperror.ErrRefundExceeded
+	}
+	return amount, nil
+}
+
+// walletError translates a failed lookup of a missing or malformed wallet id
+// into apperror.ErrWalletNotFound and leaves any other error untouched.
+func walletError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperror.ErrWalletNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == invalidTextRepresentation {
+		return apperror.ErrWalletNotFound
+	}
+	return err
+}
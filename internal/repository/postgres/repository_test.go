@@ -3,17 +3,89 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"os"
 	"testing"
+	"time"
+	"wallet-service/internal/apperror"
 	"wallet-service/internal/model"
 
 	"log/slog"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 )
 
+var walletRowColumns = []string{"id", "balance", "held", "currency", "status", "allow_deposits", "owner_id", "label", "metadata", "limit_policy", "credit_limit", "version"}
+
+func expectLockWallet(mock sqlmock.Sqlmock, uuid, balance, currency string) {
+	expectLockHeldWallet(mock, uuid, balance, "0", currency)
+}
+
+func expectLockHeldWallet(mock sqlmock.Sqlmock, uuid, balance, held, currency string) {
+	mock.ExpectQuery("FROM wallets WHERE id = \\$1 FOR UPDATE").
+		WithArgs(uuid).
+		WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow(uuid, balance, held, currency, model.WalletActive, false, nil, "", []byte(`{}`), nil, "0", 1))
+}
+
+func expectLockWalletInStatus(mock sqlmock.Sqlmock, uuid, balance, status string, allowDeposits bool) {
+	mock.ExpectQuery("FROM wallets WHERE id = \\$1 FOR UPDATE").
+		WithArgs(uuid).
+		WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow(uuid, balance, "0", "USD", status, allowDeposits, nil, "", []byte(`{}`), nil, "0", 1))
+}
+
+// expectUpdateInPlace expects the guarded balance update of a deposit or
+// withdrawal to match and leave the wallet at balance.
+func expectUpdateInPlace(mock sqlmock.Sqlmock, uuid, balance, currency string) {
+	mock.ExpectQuery("UPDATE wallets SET balance = balance [+-] \\$1::numeric").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), uuid, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow(uuid, balance, "0", currency, model.WalletActive, false, nil, "", []byte(`{}`), nil, "0", 2))
+}
+
+// expectUpdateRefused expects the guarded balance update to match no row,
+// which leaves the operation to the locking path.
+func expectUpdateRefused(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("UPDATE wallets SET balance = balance [+-] \\$1::numeric").
+		WillReturnRows(sqlmock.NewRows(walletRowColumns))
+}
+
+// expectWebhooks expects the webhook deliveries of an operation to be queued.
+func expectWebhooks(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("INSERT INTO webhook_deliveries").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectOutboxEvent(mock sqlmock.Sqlmock, walletId string) {
+	mock.ExpectExec("INSERT INTO outbox_events").
+		WithArgs(model.EventBalanceChanged, walletId, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+// expectPosting expects the ledger entries of one posting. Each entry is the
+// row after posting_id; without entries any insert is accepted.
+func expectPosting(mock sqlmock.Sqlmock, postingId string, entries ...[]driver.Value) {
+	var args []driver.Value
+	for _, e := range entries {
+		args = append(args, postingId)
+		args = append(args, e...)
+	}
+	exec := mock.ExpectExec("INSERT INTO ledger_entries")
+	if len(args) > 0 {
+		exec = exec.WithArgs(args...)
+	}
+	exec.WillReturnResult(sqlmock.NewResult(0, int64(len(entries))))
+}
+
+func walletEntry(operationId, walletId, currency, amount string) []driver.Value {
+	return []driver.Value{operationId, sql.NullString{String: walletId, Valid: true}, sql.NullString{}, currency, amount}
+}
+
+func systemEntry(operationId, account, currency, amount string) []driver.Value {
+	return []driver.Value{operationId, sql.NullString{}, sql.NullString{String: account, Valid: true}, currency, amount}
+}
+
 func TestCreateWallet(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -24,25 +96,86 @@ func TestCreateWallet(t *testing.T) {
 	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
 	repo := NewRepository(db, logger)
 
+	owned := model.Wallet{Id: "test-uuid", Currency: "USD", OwnerId: "user-1", Label: "Main", Metadata: map[string]any{"tier": "gold"}}
+
 	t.Run("success", func(t *testing.T) {
 		mock.ExpectExec("INSERT INTO wallets").
-			WithArgs("test-uuid").
+			WithArgs("test-uuid", "USD", sql.NullString{}, "", "{}").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
-		err := repo.CreateWallet(context.Background(), "test-uuid")
+		err := repo.CreateWallet(context.Background(), model.Wallet{Id: "test-uuid", Currency: "USD"}, false)
 		assert.NoError(t, err)
 	})
 
 	t.Run("failure", func(t *testing.T) {
 		mock.ExpectExec("INSERT INTO wallets").
-			WithArgs("test-uuid").
+			WithArgs("test-uuid", "USD", sql.NullString{}, "", "{}").
 			WillReturnError(sql.ErrConnDone)
 
-		err := repo.CreateWallet(context.Background(), "test-uuid")
+		err := repo.CreateWallet(context.Background(), model.Wallet{Id: "test-uuid", Currency: "USD"}, false)
 		assert.Error(t, err)
 	})
+
+	t.Run("unique per owner", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").
+			WithArgs("user-1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs("user-1", "USD", model.WalletClosed).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec("INSERT INTO wallets").
+			WithArgs("test-uuid", "USD", sql.NullString{String: "user-1", Valid: true}, "Main", `{"tier":"gold"}`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.CreateWallet(context.Background(), owned, true)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("owner already has a wallet in the currency", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("SELECT pg_advisory_xact_lock").
+			WithArgs("user-1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs("user-1", "USD", model.WalletClosed).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		err := repo.CreateWallet(context.Background(), owned, true)
+		assert.ErrorIs(t, err, apperror.ErrWalletExists)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestListWalletsByOwner(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	mock.ExpectQuery("FROM wallets WHERE owner_id = \\$1").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows(walletRowColumns).
+			AddRow("wallet-a", "10.00", "0", "EUR", model.WalletActive, false, "user-1", "Savings", []byte(`{"tier":"gold"}`), "gold", "0", 1).
+			AddRow("wallet-b", "5.00", "0", "USD", model.WalletActive, false, "user-1", "", []byte(`{}`), nil, "0", 1))
+
+	wallets, err := repo.ListWalletsByOwner(context.Background(), "user-1")
+	assert.NoError(t, err)
+	assert.Len(t, wallets, 2)
+	assert.Equal(t, "Savings", wallets[0].Label)
+	assert.Equal(t, "gold", wallets[0].Metadata["tier"])
+	assert.Equal(t, "user-1", wallets[1].OwnerId)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
-func TestGetBalanceByUuid(t *testing.T) {
+
+func TestGetWalletByUuid(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
@@ -54,35 +187,51 @@ func TestGetBalanceByUuid(t *testing.T) {
 
 	t.Run("success", func(t *testing.T) {
 		expectedBalance := decimal.NewFromFloat(100.0)
-		mock.ExpectQuery("SELECT balance FROM wallets WHERE id = \\$1").
+		mock.ExpectQuery("SELECT (.+) FROM wallets WHERE id = \\$1").
 			WithArgs("test-uuid").
-			WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow("100.00"))
+			WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow("test-uuid", "100.00", "25.00", "EUR", model.WalletFrozen, false, "user-1", "Travel", []byte(`{"card":"1234"}`), nil, "0", 1))
 
-		balance, err := repo.GetBalanceByUuid(context.Background(), "test-uuid")
+		wallet, err := repo.GetWalletByUuid(context.Background(), "test-uuid")
 		assert.NoError(t, err)
-		assert.True(t, expectedBalance.Equal(balance), "expected balance %v, got %v", expectedBalance, balance)
+		assert.True(t, expectedBalance.Equal(wallet.Balance), "expected balance %v, got %v", expectedBalance, wallet.Balance)
+		assert.Equal(t, "EUR", wallet.Currency)
+		assert.Equal(t, "75", wallet.Available().String())
+		assert.Equal(t, model.WalletFrozen, wallet.Status)
+		assert.Equal(t, "user-1", wallet.OwnerId)
+		assert.Equal(t, map[string]any{"card": "1234"}, wallet.Metadata)
 	})
 
 	t.Run("wallet not found", func(t *testing.T) {
-		mock.ExpectQuery("SELECT balance FROM wallets WHERE id = \\$1").
+		mock.ExpectQuery("SELECT (.+) FROM wallets WHERE id = \\$1").
 			WithArgs("test-uuid").
 			WillReturnError(sql.ErrNoRows)
 
-		balance, err := repo.GetBalanceByUuid(context.Background(), "test-uuid")
-		assert.Error(t, err)
-		assert.Equal(t, decimal.Zero, balance)
+		wallet, err := repo.GetWalletByUuid(context.Background(), "test-uuid")
+		assert.ErrorIs(t, err, apperror.ErrWalletNotFound)
+		assert.Equal(t, model.Wallet{}, wallet)
 	})
 
 	t.Run("query error", func(t *testing.T) {
-		mock.ExpectQuery("SELECT balance FROM wallets WHERE id = \\$1").
+		mock.ExpectQuery("SELECT (.+) FROM wallets WHERE id = \\$1").
 			WithArgs("test-uuid").
 			WillReturnError(sql.ErrConnDone)
 
-		balance, err := repo.GetBalanceByUuid(context.Background(), "test-uuid")
+		wallet, err := repo.GetWalletByUuid(context.Background(), "test-uuid")
 		assert.Error(t, err)
-		assert.Equal(t, decimal.Zero, balance)
+		assert.NotErrorIs(t, err, apperror.ErrWalletNotFound)
+		assert.Equal(t, model.Wallet{}, wallet)
+	})
+
+	t.Run("malformed uuid", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM wallets WHERE id = \\$1").
+			WithArgs("not-a-uuid").
+			WillReturnError(&pq.Error{Code: "22P02"})
+
+		_, err := repo.GetWalletByUuid(context.Background(), "not-a-uuid")
+		assert.ErrorIs(t, err, apperror.ErrWalletNotFound)
 	})
 }
+
 func TestTransaction(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -93,57 +242,2109 @@ func TestTransaction(t *testing.T) {
 	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
 	repo := NewRepository(db, logger)
 
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	deposit := model.Transaction{Uuid: "test-uuid", OperationType: model.TransactionDeposit, Amount: decimal.NewFromFloat(100.0)}
+	withdraw := model.Transaction{Uuid: "test-uuid", OperationType: model.TransactionWithdraw, Amount: decimal.NewFromFloat(100.0)}
+	operationColumns := []string{"id", "wallet_id", "operation_type", "amount", "currency", "balance_after", "transfer_id", "reference_id", "created_at", "request_hash"}
+	// expectKeyTaken expects the lookup that sends a request whose key is
+	// already stored down the locking path.
+	expectKeyTaken := func(key string) {
+		mock.ExpectQuery("FROM operations WHERE idempotency_key = \\$1").
+			WithArgs(key).
+			WillReturnRows(sqlmock.NewRows(operationColumns).
+				AddRow("op-3", "test-uuid", model.TransactionDeposit, "100", "USD", "200.00", nil, nil, createdAt, deposit.Hash()))
+	}
+
 	t.Run("deposit success", func(t *testing.T) {
 		mock.ExpectBegin()
-		mock.ExpectQuery("SELECT balance FROM wallets WHERE id = \\$1 FOR UPDATE").
-			WithArgs("test-uuid").
-			WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow("100.00"))
-		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
-			WithArgs("200.00", "test-uuid").
-			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("UPDATE wallets SET balance = balance \\+ \\$1::numeric - \\$2::numeric").
+			WithArgs("100", "0", "test-uuid", "", int64(0)).
+			WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow("test-uuid", "200.00", "0", "USD", model.WalletActive, false, nil, "", []byte(`{}`), nil, "0", 2))
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("test-uuid", model.TransactionDeposit, "100", "USD", "200.00", sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullString{String: deposit.Hash(), Valid: true}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		expectPosting(mock, "op-1", walletEntry("op-1", "test-uuid", "USD", "100"), systemEntry("op-1", model.AccountCashIn, "USD", "-100"))
+		expectWebhooks(mock)
 		mock.ExpectCommit()
 
-		err := repo.Transaction(context.Background(), "test-uuid", decimal.NewFromFloat(100.0), model.TransactionDeposit)
+		op, err := repo.Transaction(context.Background(), deposit)
 		assert.NoError(t, err)
+		assert.Equal(t, "op-1", op.Id)
+		assert.Equal(t, createdAt, op.CreatedAt)
+		assert.True(t, decimal.NewFromInt(200).Equal(op.BalanceAfter))
 	})
 
 	t.Run("withdraw success", func(t *testing.T) {
 		mock.ExpectBegin()
-		mock.ExpectQuery("SELECT balance FROM wallets WHERE id = \\$1 FOR UPDATE").
+		mock.ExpectQuery("UPDATE wallets SET balance = balance - \\$1::numeric - \\$2::numeric").
+			WithArgs("100", "0", "test-uuid", "", int64(0)).
+			WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow("test-uuid", "100.00", "0", "USD", model.WalletActive, false, nil, "", []byte(`{}`), nil, "0", 2))
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("test-uuid", model.TransactionWithdraw, "100", "USD", "100.00", sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullString{String: withdraw.Hash(), Valid: true}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-2", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		expectPosting(mock, "op-2", walletEntry("op-2", "test-uuid", "USD", "-100"), systemEntry("op-2", model.AccountCashOut, "USD", "100"))
+		mock.ExpectExec("INSERT INTO webhook_deliveries .* UNION ALL .* jsonb_build_object.* low_balance_currency = \\$7").
+			WithArgs(model.WebhookWithdraw, model.WebhookLowBalance, "op-2", sqlmock.AnyArg(), "test-uuid", "op-2", "USD", "100.00", "100.00", "200").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		op, err := repo.Transaction(context.Background(), withdraw)
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(100).Equal(op.BalanceAfter))
+	})
+
+	t.Run("insufficient balance", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockWallet(mock, "test-uuid", "50.00", "USD")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), withdraw)
+		assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	})
+
+	t.Run("held funds are not available", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockHeldWallet(mock, "test-uuid", "150.00", "60.00", "USD")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), withdraw)
+		assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	})
+
+	t.Run("frozen wallet rejects withdrawals", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockWalletInStatus(mock, "test-uuid", "500.00", model.WalletFrozen, true)
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), withdraw)
+		assert.ErrorIs(t, err, apperror.ErrWalletFrozen)
+	})
+
+	t.Run("frozen wallet rejects deposits unless allowed", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockWalletInStatus(mock, "test-uuid", "100.00", model.WalletFrozen, false)
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), deposit)
+		assert.ErrorIs(t, err, apperror.ErrWalletFrozen)
+	})
+
+	t.Run("frozen wallet accepts allowed deposits", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE wallets SET balance = balance \\+ \\$1::numeric").
+			WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow("test-uuid", "200.00", "0", "USD", model.WalletFrozen, true, nil, "", []byte(`{}`), nil, "0", 2))
+		mock.ExpectQuery("INSERT INTO operations").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		expectPosting(mock, "op-1")
+		expectWebhooks(mock)
+		mock.ExpectCommit()
+
+		_, err := repo.Transaction(context.Background(), deposit)
+		assert.NoError(t, err)
+	})
+
+	t.Run("closed wallet rejects everything", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockWalletInStatus(mock, "test-uuid", "0", model.WalletClosed, false)
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), deposit)
+		assert.ErrorIs(t, err, apperror.ErrWalletClosed)
+	})
+
+	t.Run("wallet not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		mock.ExpectQuery("FROM wallets WHERE id = \\$1 FOR UPDATE").
 			WithArgs("test-uuid").
-			WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow("200.00"))
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), deposit)
+		assert.ErrorIs(t, err, apperror.ErrWalletNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("malformed uuid", func(t *testing.T) {
+		malformed := withdraw
+		malformed.Uuid = "not-a-uuid"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE wallets SET balance = balance - \\$1::numeric").
+			WillReturnError(&pq.Error{Code: invalidTextRepresentation})
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), malformed)
+		assert.ErrorIs(t, err, apperror.ErrWalletNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("currency mismatch", func(t *testing.T) {
+		eur := deposit
+		eur.Currency = "EUR"
+
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockWallet(mock, "test-uuid", "100.00", "USD")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), eur)
+		assert.ErrorIs(t, err, apperror.ErrCurrencyMismatch)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		conditional := deposit
+		conditional.ExpectedVersion = 2
+
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockWallet(mock, "test-uuid", "100.00", "USD")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), conditional)
+		assert.ErrorIs(t, err, apperror.ErrVersionMismatch)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("amount rounded to currency precision", func(t *testing.T) {
+		yen := model.Transaction{Uuid: "test-uuid", OperationType: model.TransactionDeposit, Amount: decimal.RequireFromString("100.6")}
+
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockWallet(mock, "test-uuid", "1000", "JPY")
 		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
-			WithArgs("100.00", "test-uuid").
+			WithArgs("1101", "test-uuid").
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("test-uuid", model.TransactionDeposit, "101", "JPY", "1101", sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullString{String: yen.Hash(), Valid: true}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		expectPosting(mock, "op-1")
+		expectWebhooks(mock)
 		mock.ExpectCommit()
 
-		err := repo.Transaction(context.Background(), "test-uuid", decimal.NewFromFloat(100.0), model.TransactionWithdraw)
+		op, err := repo.Transaction(context.Background(), yen)
 		assert.NoError(t, err)
+		assert.Equal(t, "JPY", op.Currency)
+		assert.True(t, decimal.NewFromInt(101).Equal(op.Amount))
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
-	t.Run("insufficient balance", func(t *testing.T) {
+	t.Run("amount below minor unit", func(t *testing.T) {
+		tiny := model.Transaction{Uuid: "test-uuid", OperationType: model.TransactionDeposit, Amount: decimal.RequireFromString("0.001")}
+
 		mock.ExpectBegin()
-		mock.ExpectQuery("SELECT balance FROM wallets WHERE id = \\$1 FOR UPDATE").
-			WithArgs("test-uuid").
-			WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow("50.00"))
+		expectUpdateRefused(mock)
+		expectLockWallet(mock, "test-uuid", "100.00", "USD")
 		mock.ExpectRollback()
 
-		err := repo.Transaction(context.Background(), "test-uuid", decimal.NewFromFloat(100.0), model.TransactionWithdraw)
-		assert.Error(t, err)
-		assert.Equal(t, "balance is not enough", err.Error())
+		_, err := repo.Transaction(context.Background(), tiny)
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("update error", func(t *testing.T) {
 		mock.ExpectBegin()
-		mock.ExpectQuery("SELECT balance FROM wallets WHERE id = \\$1 FOR UPDATE").
-			WithArgs("test-uuid").
-			WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow("100.00"))
-		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
-			WithArgs("200.00", "test-uuid").
+		mock.ExpectQuery("UPDATE wallets SET balance = balance \\+ \\$1::numeric").
+			WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), deposit)
+		assert.ErrorIs(t, err, sql.ErrConnDone)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("insert operation error", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateInPlace(mock, "test-uuid", "200.00", "USD")
+		mock.ExpectQuery("INSERT INTO operations").
 			WillReturnError(sql.ErrConnDone)
 		mock.ExpectRollback()
 
-		err := repo.Transaction(context.Background(), "test-uuid", decimal.NewFromFloat(100.0), model.TransactionDeposit)
+		_, err := repo.Transaction(context.Background(), deposit)
 		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("new idempotency key", func(t *testing.T) {
+		keyed := deposit
+		keyed.IdempotencyKey = "key-1"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM operations WHERE idempotency_key = \\$1").
+			WithArgs("key-1").
+			WillReturnError(sql.ErrNoRows)
+		expectUpdateInPlace(mock, "test-uuid", "200.00", "USD")
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("test-uuid", model.TransactionDeposit, "100", "USD", "200.00", sql.NullString{}, sql.NullString{}, sql.NullString{String: "key-1", Valid: true}, sql.NullString{String: keyed.Hash(), Valid: true}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-3", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		expectPosting(mock, "op-3")
+		expectWebhooks(mock)
+		mock.ExpectCommit()
+
+		op, err := repo.Transaction(context.Background(), keyed)
+		assert.NoError(t, err)
+		assert.Equal(t, "op-3", op.Id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("replayed idempotency key", func(t *testing.T) {
+		keyed := deposit
+		keyed.IdempotencyKey = "key-1"
+
+		mock.ExpectBegin()
+		expectKeyTaken("key-1")
+		expectLockWallet(mock, "test-uuid", "200.00", "USD")
+		mock.ExpectQuery("FROM operations WHERE idempotency_key = \\$1").
+			WithArgs("key-1").
+			WillReturnRows(sqlmock.NewRows(operationColumns).
+				AddRow("op-3", "test-uuid", model.TransactionDeposit, "100", "USD", "200.00", nil, nil, createdAt, keyed.Hash()))
+		mock.ExpectQuery("FROM operations WHERE reference_id = \\$1 AND operation_type = \\$2").
+			WithArgs("op-3", model.OperationFee).
+			WillReturnRows(sqlmock.NewRows(operationRowColumns))
+		mock.ExpectCommit()
+
+		op, err := repo.Transaction(context.Background(), keyed)
+		assert.NoError(t, err)
+		assert.Equal(t, "op-3", op.Id)
+		assert.True(t, decimal.NewFromInt(200).Equal(op.BalanceAfter))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("replayed idempotency key ignores the version it bumped", func(t *testing.T) {
+		keyed := deposit
+		keyed.IdempotencyKey = "key-1"
+		keyed.ExpectedVersion = 7
+
+		mock.ExpectBegin()
+		expectKeyTaken("key-1")
+		expectLockWallet(mock, "test-uuid", "200.00", "USD")
+		mock.ExpectQuery("FROM operations WHERE idempotency_key = \\$1").
+			WithArgs("key-1").
+			WillReturnRows(sqlmock.NewRows(operationColumns).
+				AddRow("op-3", "test-uuid", model.TransactionDeposit, "100", "USD", "200.00", nil, nil, createdAt, keyed.Hash()))
+		mock.ExpectQuery("FROM operations WHERE reference_id = \\$1 AND operation_type = \\$2").
+			WithArgs("op-3", model.OperationFee).
+			WillReturnRows(sqlmock.NewRows(operationRowColumns))
+		mock.ExpectCommit()
+
+		op, err := repo.Transaction(context.Background(), keyed)
+		assert.NoError(t, err)
+		assert.Equal(t, "op-3", op.Id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("idempotency key reused with different payload", func(t *testing.T) {
+		keyed := withdraw
+		keyed.IdempotencyKey = "key-1"
+
+		mock.ExpectBegin()
+		expectKeyTaken("key-1")
+		expectLockWallet(mock, "test-uuid", "200.00", "USD")
+		mock.ExpectQuery("FROM operations WHERE idempotency_key = \\$1").
+			WithArgs("key-1").
+			WillReturnRows(sqlmock.NewRows(operationColumns).
+				AddRow("op-3", "test-uuid", model.TransactionDeposit, "100", "USD", "200.00", nil, nil, createdAt, deposit.Hash()))
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), keyed)
+		assert.ErrorIs(t, err, apperror.ErrIdempotencyKeyConflict)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("idempotency key claimed concurrently", func(t *testing.T) {
+		keyed := deposit
+		keyed.IdempotencyKey = "key-1"
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM operations WHERE idempotency_key = \\$1").
+			WithArgs("key-1").
+			WillReturnError(sql.ErrNoRows)
+		expectUpdateInPlace(mock, "test-uuid", "300.00", "USD")
+		mock.ExpectQuery("INSERT INTO operations").
+			WillReturnError(&pq.Error{Code: uniqueViolation})
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		expectKeyTaken("key-1")
+		expectLockWallet(mock, "test-uuid", "200.00", "USD")
+		expectKeyTaken("key-1")
+		mock.ExpectQuery("FROM operations WHERE reference_id = \\$1 AND operation_type = \\$2").
+			WithArgs("op-3", model.OperationFee).
+			WillReturnRows(sqlmock.NewRows(operationRowColumns))
+		mock.ExpectCommit()
+
+		op, err := repo.Transaction(context.Background(), keyed)
+		assert.NoError(t, err)
+		assert.Equal(t, "op-3", op.Id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+var operationRowColumns = []string{"id", "wallet_id", "operation_type", "amount", "currency", "balance_after", "transfer_id", "reference_id", "created_at"}
+
+func expectGetOperation(mock sqlmock.Sqlmock, id, walletId, operationType, amount string) {
+	mock.ExpectQuery("FROM operations WHERE id = \\$1").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(operationRowColumns).
+			AddRow(id, walletId, operationType, amount, "USD", "100.00", nil, nil, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func expectRefunded(mock sqlmock.Sqlmock, id, total string) {
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM operations WHERE reference_id = \\$1").
+		WithArgs(id, model.TransactionRefund).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(total))
+}
+
+func TestRefund(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("full refund of a deposit debits the rest", func(t *testing.T) {
+		refund := model.Transaction{OperationType: model.TransactionRefund, ReferenceId: "op-1"}
+		filled := refund
+		filled.Uuid = "test-uuid"
+
+		mock.ExpectBegin()
+		expectGetOperation(mock, "op-1", "test-uuid", model.TransactionDeposit, "100")
+		expectLockWallet(mock, "test-uuid", "150.00", "USD")
+		expectRefunded(mock, "op-1", "30")
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("80.00", "test-uuid").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("test-uuid", model.TransactionRefund, "70", "USD", "80.00", sql.NullString{}, sql.NullString{String: "op-1", Valid: true}, sql.NullString{}, sql.NullString{String: filled.Hash(), Valid: true}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-2", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		expectPosting(mock, "op-2", walletEntry("op-2", "test-uuid", "USD", "-70"), systemEntry("op-2", model.AccountCashIn, "USD", "70"))
+		expectWebhooks(mock)
+		mock.ExpectCommit()
+
+		op, err := repo.Transaction(context.Background(), refund)
+		assert.NoError(t, err)
+		assert.Equal(t, "op-2", op.Id)
+		assert.Equal(t, "op-1", op.ReferenceId)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("partial refund of a withdrawal credits", func(t *testing.T) {
+		refund := model.Transaction{Uuid: "test-uuid", OperationType: model.TransactionRefund, ReferenceId: "op-1", Amount: decimal.NewFromInt(40)}
+
+		mock.ExpectBegin()
+		expectGetOperation(mock, "op-1", "test-uuid", model.TransactionWithdraw, "100")
+		expectLockWallet(mock, "test-uuid", "10.00", "USD")
+		expectRefunded(mock, "op-1", "0")
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("50.00", "test-uuid").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("test-uuid", model.TransactionRefund, "40", "USD", "50.00", sql.NullString{}, sql.NullString{String: "op-1", Valid: true}, sql.NullString{}, sql.NullString{String: refund.Hash(), Valid: true}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-2", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		expectPosting(mock, "op-2", walletEntry("op-2", "test-uuid", "USD", "40"), systemEntry("op-2", model.AccountCashOut, "USD", "-40"))
+		mock.ExpectCommit()
+
+		_, err := repo.Transaction(context.Background(), refund)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("more than what is left", func(t *testing.T) {
+		refund := model.Transaction{OperationType: model.TransactionRefund, ReferenceId: "op-1", Amount: decimal.NewFromInt(80)}
+
+		mock.ExpectBegin()
+		expectGetOperation(mock, "op-1", "test-uuid", model.TransactionDeposit, "100")
+		expectLockWallet(mock, "test-uuid", "150.00", "USD")
+		expectRefunded(mock, "op-1", "30")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), refund)
+		assert.ErrorIs(t, err, apperror.ErrRefundExceeded)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("transfer legs are not refundable", func(t *testing.T) {
+		refund := model.Transaction{OperationType: model.TransactionRefund, ReferenceId: "op-1"}
+
+		mock.ExpectBegin()
+		expectGetOperation(mock, "op-1", "test-uuid", model.OperationTransferIn, "100")
+		expectLockWallet(mock, "test-uuid", "150.00", "USD")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), refund)
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("operation of another wallet", func(t *testing.T) {
+		refund := model.Transaction{Uuid: "other-uuid", OperationType: model.TransactionRefund, ReferenceId: "op-1"}
+
+		mock.ExpectBegin()
+		expectGetOperation(mock, "op-1", "test-uuid", model.TransactionDeposit, "100")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), refund)
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("operation not found", func(t *testing.T) {
+		refund := model.Transaction{OperationType: model.TransactionRefund, ReferenceId: "op-1"}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM operations WHERE id = \\$1").
+			WithArgs("op-1").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), refund)
+		assert.ErrorIs(t, err, apperror.ErrOperationNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetOperationsByWalletUuid(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	columns := []string{"id", "wallet_id", "operation_type", "amount", "currency", "balance_after", "transfer_id", "reference_id", "created_at"}
+
+	t.Run("success", func(t *testing.T) {
+		createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		mock.ExpectQuery("SELECT (.+) FROM operations WHERE wallet_id = \\$1 ORDER BY created_at, id").
+			WithArgs("test-uuid").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("op-1", "test-uuid", model.TransactionDeposit, "100", "USD", "100.00", nil, nil, createdAt).
+				AddRow("op-2", "test-uuid", model.TransactionWithdraw, "30", "USD", "70.00", nil, nil, createdAt.Add(time.Minute)))
+
+		operations, err := repo.GetOperationsByWalletUuid(context.Background(), "test-uuid")
+		assert.NoError(t, err)
+		assert.Len(t, operations, 2)
+		assert.Equal(t, "op-1", operations[0].Id)
+		assert.Equal(t, model.TransactionWithdraw, operations[1].OperationType)
+		assert.True(t, decimal.NewFromInt(70).Equal(operations[1].BalanceAfter))
+		assert.Equal(t, createdAt, operations[0].CreatedAt)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM operations WHERE wallet_id = \\$1").
+			WithArgs("test-uuid").
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		operations, err := repo.GetOperationsByWalletUuid(context.Background(), "test-uuid")
+		assert.NoError(t, err)
+		assert.Empty(t, operations)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT (.+) FROM operations WHERE wallet_id = \\$1").
+			WithArgs("test-uuid").
+			WillReturnError(sql.ErrConnDone)
+
+		operations, err := repo.GetOperationsByWalletUuid(context.Background(), "test-uuid")
+		assert.Error(t, err)
+		assert.Nil(t, operations)
+	})
+}
+
+func TestListOperations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	columns := []string{"id", "wallet_id", "operation_type", "amount", "currency", "balance_after", "transfer_id", "reference_id", "created_at"}
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("wallet only", func(t *testing.T) {
+		mock.ExpectQuery("FROM operations WHERE wallet_id = \\$1 ORDER BY created_at DESC, id DESC LIMIT \\$2").
+			WithArgs("test-uuid", 10).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("op-1", "test-uuid", model.TransactionDeposit, "100", "USD", "100.00", nil, nil, createdAt))
+
+		operations, err := repo.ListOperations(context.Background(), model.OperationFilter{WalletId: "test-uuid", Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, operations, 1)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("all filters", func(t *testing.T) {
+		from := createdAt.Add(-time.Hour)
+		to := createdAt.Add(time.Hour)
+		mock.ExpectQuery("FROM operations WHERE wallet_id = \\$1 AND operation_type = \\$2 AND created_at >= \\$3 AND created_at < \\$4 "+
+			"AND \\(created_at, id\\) < \\(\\$5, \\$6\\) ORDER BY created_at DESC, id DESC LIMIT \\$7").
+			WithArgs("test-uuid", model.TransactionWithdraw, from, to, createdAt, "op-9", 10).
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		operations, err := repo.ListOperations(context.Background(), model.OperationFilter{
+			WalletId:      "test-uuid",
+			OperationType: model.TransactionWithdraw,
+			From:          from,
+			To:            to,
+			Cursor:        &model.OperationCursor{CreatedAt: createdAt, Id: "op-9"},
+			Limit:         10,
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, operations)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("all wallets", func(t *testing.T) {
+		from := createdAt.Add(-time.Hour)
+		mock.ExpectQuery("FROM operations WHERE created_at >= \\$1 ORDER BY created_at DESC, id DESC LIMIT \\$2").
+			WithArgs(from, 10).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("op-1", "wallet-a", model.TransactionDeposit, "100", "USD", "100.00", nil, nil, createdAt).
+				AddRow("op-2", "wallet-b", model.TransactionDeposit, "5", "EUR", "5.00", nil, nil, createdAt))
+
+		operations, err := repo.ListOperations(context.Background(), model.OperationFilter{From: from, Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, operations, 2)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		mock.ExpectQuery("FROM operations").
+			WillReturnError(sql.ErrConnDone)
+
+		_, err := repo.ListOperations(context.Background(), model.OperationFilter{WalletId: "test-uuid", Limit: 10})
+		assert.Error(t, err)
+	})
+}
+
+func TestGetStatement(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	columns := []string{"id", "wallet_id", "operation_type", "amount", "currency", "balance_after", "transfer_id", "reference_id", "created_at", "change"}
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM wallets WHERE id = \\$1").
+			WithArgs("test-uuid").
+			// The wallet balance is stale on purpose: statements ignore it.
+			WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow("test-uuid", "999.00", "0", "USD", model.WalletActive, false, nil, "", []byte(`{}`), nil, "0", 1))
+		mock.ExpectQuery("SELECT COALESCE\\(SUM\\(CASE (.+) FROM operations o LEFT JOIN operations r ON r.id = o.reference_id WHERE o.wallet_id = \\$1 AND o.created_at < \\$2").
+			WithArgs("test-uuid", from).
+			WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("100.00"))
+		mock.ExpectQuery("FROM operations o LEFT JOIN operations r ON r.id = o.reference_id WHERE o.wallet_id = \\$1 AND o.created_at >= \\$2 AND o.created_at < \\$3 ORDER BY o.created_at, o.id").
+			WithArgs("test-uuid", from, to).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("op-1", "test-uuid", model.TransactionWithdraw, "40.00", "USD", "60.00", nil, nil, createdAt, "-40.00").
+				AddRow("op-2", "test-uuid", model.TransactionRefund, "15.00", "USD", "75.00", nil, "op-1", createdAt, "15.00").
+				AddRow("op-3", "test-uuid", model.OperationTransferIn, "25.00", "USD", "100.00", "tr-1", nil, createdAt, "25.00"))
+		mock.ExpectCommit()
+
+		statement, err := repo.GetStatement(context.Background(), "test-uuid", from, to)
+		assert.NoError(t, err)
+		assert.Equal(t, "100", statement.OpeningBalance.String())
+		assert.Len(t, statement.Lines, 3)
+		assert.Equal(t, "60", statement.Lines[0].RunningBalance.String())
+		assert.Equal(t, "75", statement.Lines[1].RunningBalance.String())
+		assert.Equal(t, "100", statement.ClosingBalance.String())
+		assert.Equal(t, "40", statement.Totals[model.TransactionWithdraw].String())
+		assert.Equal(t, "15", statement.Totals[model.TransactionRefund].String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("wallet not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM wallets WHERE id = \\$1").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := repo.GetStatement(context.Background(), "missing", from, to)
+		assert.ErrorIs(t, err, apperror.ErrWalletNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetBalanceAsOf(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectQuery("FROM wallets WHERE id = \\$1").
+			WithArgs("test-uuid").
+			WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow("test-uuid", "999.00", "0", "EUR", model.WalletActive, false, nil, "", []byte(`{}`), nil, "0", 1))
+		mock.ExpectQuery("SELECT COALESCE\\(SUM\\(CASE (.+) WHERE o.wallet_id = \\$1 AND o.created_at <= \\$2").
+			WithArgs("test-uuid", at).
+			WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("42.50"))
+
+		balance, err := repo.GetBalanceAsOf(context.Background(), "test-uuid", at)
+		assert.NoError(t, err)
+		assert.Equal(t, "42.5", balance.Balance.String())
+		assert.Equal(t, "EUR", balance.Currency)
+		assert.Equal(t, at, balance.AsOf)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("wallet not found", func(t *testing.T) {
+		mock.ExpectQuery("FROM wallets WHERE id = \\$1").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetBalanceAsOf(context.Background(), "missing", at)
+		assert.ErrorIs(t, err, apperror.ErrWalletNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestTrialBalance(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	mock.ExpectQuery("SELECT currency, COALESCE\\(system_account, 'WALLETS'\\), SUM\\(amount\\) FROM ledger_entries GROUP BY 1, 2").
+		WillReturnRows(sqlmock.NewRows([]string{"currency", "coalesce", "sum"}).
+			AddRow("EUR", model.AccountFX, "-27.6").
+			AddRow("EUR", model.AccountWallets, "27.6").
+			AddRow("USD", model.AccountCashIn, "-100").
+			AddRow("USD", model.AccountFX, "30").
+			AddRow("USD", model.AccountWallets, "60"))
+
+	balance, err := repo.TrialBalance(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, balance.Balanced)
+	assert.Len(t, balance.Currencies, 2)
+	assert.True(t, balance.Currencies[0].Total.IsZero())
+	assert.Equal(t, "27.6", balance.Currencies[0].Accounts[model.AccountWallets].String())
+	assert.Equal(t, "-10", balance.Currencies[1].Total.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostEntriesRefusesUnbalancedPosting(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+
+	entry := model.LedgerEntry{OperationId: "op-1", WalletId: "wallet-a", Currency: "USD", Amount: decimal.NewFromInt(100)}
+	err = postEntries(context.Background(), tx, "op-1", []model.LedgerEntry{entry, model.SystemEntry(model.AccountFX, entry), entry})
+	assert.Error(t, err)
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReconcile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	finishedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	mismatchColumns := []string{"id", "currency", "balance", "coalesce"}
+	expectCompare := func() {
+		mock.ExpectQuery("SELECT count\\(\\*\\) FROM wallets").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery("FROM wallets w LEFT JOIN \\((.+)GROUP BY o.wallet_id \\) l ON l.wallet_id = w.id WHERE w.balance <> COALESCE\\(l.balance, 0\\)").
+			WillReturnRows(sqlmock.NewRows(mismatchColumns).
+				AddRow("wallet-a", "USD", "110.00", "100.00").
+				AddRow("wallet-b", "USD", "-5.00", "0"))
+	}
+
+	t.Run("report only", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCompare()
+		mock.ExpectQuery("INSERT INTO reconciliation_runs").
+			WithArgs(sqlmock.AnyArg(), 3, 2, false).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "finished_at"}).AddRow(7, finishedAt))
+		mock.ExpectExec("INSERT INTO reconciliation_mismatches").
+			WithArgs(int64(7), "wallet-a", "USD", "110", "100", false).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO reconciliation_mismatches").
+			WithArgs(int64(7), "wallet-b", "USD", "-5", "0", false).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		run, err := repo.Reconcile(context.Background(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(7), run.Id)
+		assert.Equal(t, 3, run.WalletsChecked)
+		assert.Equal(t, 2, run.MismatchCount)
+		assert.Equal(t, "10", run.Mismatches[0].Difference.String())
+		assert.False(t, run.Mismatches[0].Fixed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("fix keeps going past a wallet it cannot fix", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectCompare()
+		mock.ExpectExec("SAVEPOINT reconcile_fix").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2 AND balance = \\$3").
+			WithArgs("100", "wallet-a", "110").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("RELEASE SAVEPOINT reconcile_fix").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SAVEPOINT reconcile_fix").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2 AND balance = \\$3").
+			WithArgs("0", "wallet-b", "-5").
+			WillReturnError(&pq.Error{Code: "23514"})
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT reconcile_fix").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("INSERT INTO reconciliation_runs").
+			WithArgs(sqlmock.AnyArg(), 3, 2, true).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "finished_at"}).AddRow(8, finishedAt))
+		mock.ExpectExec("INSERT INTO reconciliation_mismatches").
+			WithArgs(int64(8), "wallet-a", "USD", "110", "100", true).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO reconciliation_mismatches").
+			WithArgs(int64(8), "wallet-b", "USD", "-5", "0", false).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		run, err := repo.Reconcile(context.Background(), true)
+		assert.NoError(t, err)
+		assert.True(t, run.Mismatches[0].Fixed)
+		assert.False(t, run.Mismatches[1].Fixed)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("get run", func(t *testing.T) {
+		mock.ExpectQuery("FROM reconciliation_runs WHERE id = \\$1").
+			WithArgs(int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "started_at", "finished_at", "wallets_checked", "mismatches", "fix"}).
+				AddRow(7, finishedAt, finishedAt, 3, 1, false))
+		mock.ExpectQuery("FROM reconciliation_mismatches WHERE run_id = \\$1").
+			WithArgs(int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"wallet_id", "currency", "stored_balance", "ledger_balance", "fixed"}).
+				AddRow("wallet-a", "USD", "110.00", "100.00", false))
+
+		run, err := repo.GetReconciliationRun(context.Background(), 7)
+		assert.NoError(t, err)
+		assert.Len(t, run.Mismatches, 1)
+		assert.Equal(t, "10", run.Mismatches[0].Difference.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("run not found", func(t *testing.T) {
+		mock.ExpectQuery("FROM reconciliation_runs WHERE id = \\$1").
+			WithArgs(int64(99)).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetReconciliationRun(context.Background(), 99)
+		assert.ErrorIs(t, err, apperror.ErrRunNotFound)
+	})
+}
+
+func TestTransfer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	sameCurrency := func(from, to string) model.Transfer {
+		return model.Transfer{
+			FromUuid:        from,
+			ToUuid:          to,
+			Amount:          decimal.NewFromInt(30),
+			FromCurrency:    "USD",
+			ConvertedAmount: decimal.NewFromInt(30),
+			ToCurrency:      "USD",
+			Rate:            decimal.NewFromInt(1),
+		}
+	}
+
+	t.Run("same wallet in another case", func(t *testing.T) {
+		transfer := sameCurrency("00000000-0000-0000-0000-00000000000a", "00000000-0000-0000-0000-00000000000A")
+
+		_, err := repo.Transfer(context.Background(), transfer)
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.ErrorIs(t, model.ValidateTransfer(transfer), apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("success locks rows in id order", func(t *testing.T) {
+		transfer := sameCurrency("wallet-b", "wallet-a")
+
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "10.00", "USD")
+		expectLockWallet(mock, "wallet-b", "100.00", "USD")
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("70.00", "wallet-b").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("40.00", "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO transfers").
+			WithArgs("wallet-b", "wallet-a", "30", "USD", "30", "USD", "1", (*time.Time)(nil)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("transfer-1", createdAt))
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("wallet-b", model.OperationTransferOut, "30", "USD", "70.00", sql.NullString{String: "transfer-1", Valid: true}, sql.NullString{}, sql.NullString{}, sql.NullString{}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, "wallet-b")
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("wallet-a", model.OperationTransferIn, "30", "USD", "40.00", sql.NullString{String: "transfer-1", Valid: true}, sql.NullString{}, sql.NullString{}, sql.NullString{}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-2", createdAt))
+		expectOutboxEvent(mock, "wallet-a")
+		expectPosting(mock, "transfer-1", walletEntry("op-1", "wallet-b", "USD", "-30"), walletEntry("op-2", "wallet-a", "USD", "30"))
+		expectWebhooks(mock)
+		mock.ExpectCommit()
+
+		result, err := repo.Transfer(context.Background(), transfer)
+		assert.NoError(t, err)
+		assert.Equal(t, "transfer-1", result.Id)
+		assert.Equal(t, createdAt, result.CreatedAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("converts between currencies", func(t *testing.T) {
+		rateAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		transfer := model.Transfer{
+			FromUuid:        "wallet-a",
+			ToUuid:          "wallet-b",
+			Amount:          decimal.NewFromInt(30),
+			FromCurrency:    "USD",
+			ConvertedAmount: decimal.RequireFromString("27.6"),
+			ToCurrency:      "EUR",
+			Rate:            decimal.RequireFromString("0.92"),
+			RateTimestamp:   &rateAt,
+		}
+
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "100.00", "USD")
+		expectLockWallet(mock, "wallet-b", "10.00", "EUR")
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("70.00", "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("37.60", "wallet-b").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO transfers").
+			WithArgs("wallet-a", "wallet-b", "30", "USD", "27.6", "EUR", "0.92", &rateAt).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("transfer-1", createdAt))
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("wallet-a", model.OperationTransferOut, "30", "USD", "70.00", sql.NullString{String: "transfer-1", Valid: true}, sql.NullString{}, sql.NullString{}, sql.NullString{}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, "wallet-a")
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("wallet-b", model.OperationTransferIn, "27.6", "EUR", "37.60", sql.NullString{String: "transfer-1", Valid: true}, sql.NullString{}, sql.NullString{}, sql.NullString{}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-2", createdAt))
+		expectOutboxEvent(mock, "wallet-b")
+		expectPosting(mock, "transfer-1",
+			walletEntry("op-1", "wallet-a", "USD", "-30"), walletEntry("op-2", "wallet-b", "EUR", "27.6"),
+			systemEntry("op-1", model.AccountFX, "USD", "30"), systemEntry("op-2", model.AccountFX, "EUR", "-27.6"))
+		expectWebhooks(mock)
+		mock.ExpectCommit()
+
+		result, err := repo.Transfer(context.Background(), transfer)
+		assert.NoError(t, err)
+		assert.True(t, result.ConvertedAmount.Equal(decimal.RequireFromString("27.6")))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("insufficient balance", func(t *testing.T) {
+		transfer := sameCurrency("wallet-a", "wallet-b")
+
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "10.00", "USD")
+		expectLockWallet(mock, "wallet-b", "100.00", "USD")
+		mock.ExpectRollback()
+
+		_, err := repo.Transfer(context.Background(), transfer)
+		assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("frozen destination", func(t *testing.T) {
+		transfer := sameCurrency("wallet-a", "wallet-b")
+
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "100.00", "USD")
+		expectLockWalletInStatus(mock, "wallet-b", "100.00", model.WalletFrozen, false)
+		mock.ExpectRollback()
+
+		_, err := repo.Transfer(context.Background(), transfer)
+		assert.ErrorIs(t, err, apperror.ErrWalletFrozen)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("wallet currency changed since pricing", func(t *testing.T) {
+		transfer := sameCurrency("wallet-a", "wallet-b")
+
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "100.00", "USD")
+		expectLockWallet(mock, "wallet-b", "100.00", "EUR")
+		mock.ExpectRollback()
+
+		_, err := repo.Transfer(context.Background(), transfer)
+		assert.ErrorIs(t, err, apperror.ErrCurrencyMismatch)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("wallet not found", func(t *testing.T) {
+		transfer := sameCurrency("wallet-a", "wallet-b")
+
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "100.00", "USD")
+		mock.ExpectQuery("FROM wallets WHERE id = \\$1 FOR UPDATE").
+			WithArgs("wallet-b").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := repo.Transfer(context.Background(), transfer)
+		assert.ErrorIs(t, err, apperror.ErrWalletNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+var holdRowColumns = []string{"id", "wallet_id", "amount", "currency", "status", "captured_amount", "operation_id", "expires_at", "created_at"}
+
+func expectLockHold(mock sqlmock.Sqlmock, id, status string, expiresAt time.Time) {
+	mock.ExpectQuery("FROM holds WHERE id = \\$1 FOR UPDATE").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(holdRowColumns).AddRow(id, "wallet-a", "40.00", "USD", status, "0", nil, expiresAt, expiresAt.Add(-time.Hour)))
+}
+
+func TestAuthorizeHold(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	hold := model.Hold{WalletId: "wallet-a", Amount: decimal.NewFromInt(40), ExpiresAt: createdAt.Add(15 * time.Minute)}
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockHeldWallet(mock, "wallet-a", "100.00", "50.00", "USD")
+		mock.ExpectExec("UPDATE wallets SET held = \\$1 WHERE id = \\$2").
+			WithArgs("90.00", "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO holds").
+			WithArgs("wallet-a", "40", "USD", hold.ExpiresAt).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("hold-1", createdAt))
+		mock.ExpectCommit()
+
+		result, err := repo.AuthorizeHold(context.Background(), hold)
+		assert.NoError(t, err)
+		assert.Equal(t, "hold-1", result.Id)
+		assert.Equal(t, model.HoldActive, result.Status)
+		assert.Equal(t, "USD", result.Currency)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("insufficient available balance", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockHeldWallet(mock, "wallet-a", "100.00", "70.00", "USD")
+		mock.ExpectRollback()
+
+		_, err := repo.AuthorizeHold(context.Background(), hold)
+		assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("open holds count towards the withdrawal limits", func(t *testing.T) {
+		mock.ExpectBegin()
+		// 200 withdrawn and 70 already held leave 30 of the daily 300.
+		expectLockLimitedHeldWallet(mock, "wallet-a", "500.00", "70.00")
+		expectOperationTotal(mock, "wallet-a", "86400 seconds", "200")
+		mock.ExpectRollback()
+
+		_, err := repo.AuthorizeHold(context.Background(), hold)
+		assert.Equal(t, apperror.CodeDailyWithdrawalExceeded, apperror.CodeOf(err))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("within the withdrawal limits", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockLimitedHeldWallet(mock, "wallet-a", "500.00", "60.00")
+		expectOperationTotal(mock, "wallet-a", "86400 seconds", "200")
+		expectOperationTotal(mock, "wallet-a", "2592000 seconds", "200")
+		mock.ExpectExec("UPDATE wallets SET held = \\$1 WHERE id = \\$2").
+			WithArgs("100.00", "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO holds").
+			WithArgs("wallet-a", "40", "USD", hold.ExpiresAt).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("hold-2", createdAt))
+		mock.ExpectCommit()
+
+		result, err := repo.AuthorizeHold(context.Background(), hold)
+		assert.NoError(t, err)
+		assert.Equal(t, "hold-2", result.Id)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestCaptureHold(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	future := time.Now().Add(time.Hour)
+
+	t.Run("partial capture releases the rest", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockHold(mock, "hold-1", model.HoldActive, future)
+		expectLockHeldWallet(mock, "wallet-a", "100.00", "40.00", "USD")
+		mock.ExpectExec("UPDATE wallets SET held = \\$1 WHERE id = \\$2").
+			WithArgs("0.00", "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("75.00", "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("wallet-a", model.OperationCapture, "25", "USD", "75.00", sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullString{}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, "wallet-a")
+		expectPosting(mock, "op-1", walletEntry("op-1", "wallet-a", "USD", "-25"), systemEntry("op-1", model.AccountCashOut, "USD", "25"))
+		mock.ExpectExec("INSERT INTO webhook_deliveries").
+			WithArgs("", model.WebhookLowBalance, "op-1", sqlmock.AnyArg(), "wallet-a", "op-1", "USD", "75.00", "75.00", "100").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE holds SET status = \\$1").
+			WithArgs(model.HoldCaptured, "25", sql.NullString{String: "op-1", Valid: true}, "hold-1").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		hold, err := repo.CaptureHold(context.Background(), "hold-1", decimal.NewFromInt(25))
+		assert.NoError(t, err)
+		assert.Equal(t, model.HoldCaptured, hold.Status)
+		assert.Equal(t, "op-1", hold.OperationId)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("capture beyond withdrawal limit", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockHold(mock, "hold-1", model.HoldActive, future)
+		expectLockLimitedHeldWallet(mock, "wallet-a", "100.00", "40.00")
+		expectOperationTotal(mock, "wallet-a", "86400 seconds", "280")
+		mock.ExpectRollback()
+
+		_, err := repo.CaptureHold(context.Background(), "hold-1", decimal.Zero)
+		assert.Equal(t, apperror.CodeDailyWithdrawalExceeded, apperror.CodeOf(err))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("amount above hold", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockHold(mock, "hold-1", model.HoldActive, future)
+		mock.ExpectRollback()
+
+		_, err := repo.CaptureHold(context.Background(), "hold-1", decimal.NewFromInt(41))
+		assert.ErrorIs(t, err, apperror.ErrValidation)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("expired hold", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockHold(mock, "hold-1", model.HoldActive, time.Now().Add(-time.Minute))
+		mock.ExpectRollback()
+
+		_, err := repo.CaptureHold(context.Background(), "hold-1", decimal.Zero)
+		assert.ErrorIs(t, err, apperror.ErrHoldNotActive)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("hold not found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM holds WHERE id = \\$1 FOR UPDATE").
+			WithArgs("not-a-uuid").
+			WillReturnError(&pq.Error{Code: invalidTextRepresentation})
+		mock.ExpectRollback()
+
+		_, err := repo.CaptureHold(context.Background(), "not-a-uuid", decimal.Zero)
+		assert.ErrorIs(t, err, apperror.ErrHoldNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestVoidHold(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	future := time.Now().Add(time.Hour)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockHold(mock, "hold-1", model.HoldActive, future)
+		expectLockHeldWallet(mock, "wallet-a", "100.00", "40.00", "USD")
+		mock.ExpectExec("UPDATE wallets SET held = \\$1 WHERE id = \\$2").
+			WithArgs("0.00", "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE holds SET status = \\$1").
+			WithArgs(model.HoldVoided, "0", sql.NullString{}, "hold-1").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		hold, err := repo.VoidHold(context.Background(), "hold-1")
+		assert.NoError(t, err)
+		assert.Equal(t, model.HoldVoided, hold.Status)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("already captured", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockHold(mock, "hold-1", model.HoldCaptured, future)
+		mock.ExpectRollback()
+
+		_, err := repo.VoidHold(context.Background(), "hold-1")
+		assert.ErrorIs(t, err, apperror.ErrHoldNotActive)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestExpireHolds(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM holds\\s+WHERE status = 'ACTIVE' AND expires_at <= \\$1").
+		WithArgs(now, 10).
+		WillReturnRows(sqlmock.NewRows(holdRowColumns).
+			AddRow("hold-1", "wallet-a", "40.00", "USD", model.HoldActive, "0", nil, now.Add(-time.Minute), now.Add(-time.Hour)).
+			AddRow("hold-2", "wallet-a", "10.00", "USD", model.HoldActive, "0", nil, now.Add(-time.Minute), now.Add(-time.Hour)))
+	expectLockHeldWallet(mock, "wallet-a", "100.00", "50.00", "USD")
+	mock.ExpectExec("UPDATE wallets SET held = \\$1 WHERE id = \\$2").
+		WithArgs("10.00", "wallet-a").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE holds SET status = \\$1").
+		WithArgs(model.HoldExpired, "0", sql.NullString{}, "hold-1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectLockHeldWallet(mock, "wallet-a", "100.00", "10.00", "USD")
+	mock.ExpectExec("UPDATE wallets SET held = \\$1 WHERE id = \\$2").
+		WithArgs("0.00", "wallet-a").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE holds SET status = \\$1").
+		WithArgs(model.HoldExpired, "0", sql.NullString{}, "hold-2").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	n, err := repo.ExpireHolds(context.Background(), now, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangeWalletStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("freeze", func(t *testing.T) {
+		change := model.StatusChange{WalletId: "wallet-a", Status: model.WalletFrozen, Reason: "fraud review", Actor: "compliance@example.com", AllowDeposits: true}
+
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "100.00", "USD")
+		mock.ExpectExec("UPDATE wallets SET status = \\$1, allow_deposits = \\$2 WHERE id = \\$3").
+			WithArgs(model.WalletFrozen, true, "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO wallet_status_changes").
+			WithArgs("wallet-a", model.WalletActive, model.WalletFrozen, "fraud review", "compliance@example.com", true).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("change-1", createdAt))
+		mock.ExpectCommit()
+
+		result, err := repo.ChangeWalletStatus(context.Background(), change)
+		assert.NoError(t, err)
+		assert.Equal(t, "change-1", result.Id)
+		assert.Equal(t, model.WalletActive, result.FromStatus)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refreeze to stop deposits", func(t *testing.T) {
+		change := model.StatusChange{WalletId: "wallet-a", Status: model.WalletFrozen, Reason: "deposits suspended", Actor: "compliance@example.com"}
+
+		mock.ExpectBegin()
+		expectLockWalletInStatus(mock, "wallet-a", "100.00", model.WalletFrozen, true)
+		mock.ExpectExec("UPDATE wallets SET status = \\$1, allow_deposits = \\$2 WHERE id = \\$3").
+			WithArgs(model.WalletFrozen, false, "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO wallet_status_changes").
+			WithArgs("wallet-a", model.WalletFrozen, model.WalletFrozen, "deposits suspended", "compliance@example.com", false).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("change-2", createdAt))
+		mock.ExpectCommit()
+
+		result, err := repo.ChangeWalletStatus(context.Background(), change)
+		assert.NoError(t, err)
+		assert.Equal(t, model.WalletFrozen, result.FromStatus)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("refreeze without a change", func(t *testing.T) {
+		change := model.StatusChange{WalletId: "wallet-a", Status: model.WalletFrozen, Reason: "again", Actor: "support"}
+
+		mock.ExpectBegin()
+		expectLockWalletInStatus(mock, "wallet-a", "100.00", model.WalletFrozen, false)
+		mock.ExpectRollback()
+
+		_, err := repo.ChangeWalletStatus(context.Background(), change)
+		assert.Equal(t, apperror.CodeInvalidTransition, apperror.CodeOf(err))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("close with balance", func(t *testing.T) {
+		change := model.StatusChange{WalletId: "wallet-a", Status: model.WalletClosed, Reason: "customer request", Actor: "support"}
+
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "0.01", "USD")
+		mock.ExpectRollback()
+
+		_, err := repo.ChangeWalletStatus(context.Background(), change)
+		assert.ErrorIs(t, err, apperror.ErrBalanceNotZero)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("closed is final", func(t *testing.T) {
+		change := model.StatusChange{WalletId: "wallet-a", Status: model.WalletActive, Reason: "reopen", Actor: "support"}
+
+		mock.ExpectBegin()
+		expectLockWalletInStatus(mock, "wallet-a", "0", model.WalletClosed, false)
+		mock.ExpectRollback()
+
+		_, err := repo.ChangeWalletStatus(context.Background(), change)
+		assert.Equal(t, apperror.CodeInvalidTransition, apperror.CodeOf(err))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+var limitPolicyRowColumns = []string{"name", "currency", "max_balance", "max_single_amount", "daily_deposit", "daily_withdrawal", "monthly_deposit", "monthly_withdrawal"}
+
+func expectLockLimitedWallet(mock sqlmock.Sqlmock, uuid, balance string) {
+	expectLockLimitedHeldWallet(mock, uuid, balance, "0")
+}
+
+// expectLockLimitedHeldWallet is expectLockLimitedWallet for a wallet with
+// held funds, which count towards its withdrawal limits.
+func expectLockLimitedHeldWallet(mock sqlmock.Sqlmock, uuid, balance, held string) {
+	mock.ExpectQuery("FROM wallets WHERE id = \\$1 FOR UPDATE").
+		WithArgs(uuid).
+		WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow(uuid, balance, held, "USD", model.WalletActive, false, nil, "", []byte(`{}`), "basic", "0", 1))
+	mock.ExpectQuery("FROM limit_policies WHERE name = \\$1").
+		WithArgs("basic").
+		WillReturnRows(sqlmock.NewRows(limitPolicyRowColumns).AddRow("basic", "USD", "1000", "500", nil, "300", nil, "2000"))
+}
+
+func expectOperationTotal(mock sqlmock.Sqlmock, uuid, window, total string) {
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM operations\\s+WHERE wallet_id = \\$1 AND operation_type = ANY\\(\\$2\\)").
+		WithArgs(uuid, sqlmock.AnyArg(), window).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(total))
+}
+
+func TestTransactionLimits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	transaction := func(op string, amount int64) model.Transaction {
+		return model.Transaction{Uuid: "test-uuid", OperationType: op, Amount: decimal.NewFromInt(amount)}
+	}
+
+	t.Run("single amount", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockLimitedWallet(mock, "test-uuid", "100.00")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), transaction(model.TransactionDeposit, 600))
+		assert.Equal(t, apperror.CodeSingleAmountExceeded, apperror.CodeOf(err))
+		assert.EqualError(t, err, "single operation limit of 500.00 USD exceeded")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("max balance", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockLimitedWallet(mock, "test-uuid", "900.00")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), transaction(model.TransactionDeposit, 200))
+		assert.Equal(t, apperror.CodeMaxBalanceExceeded, apperror.CodeOf(err))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("daily withdrawal", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockLimitedWallet(mock, "test-uuid", "900.00")
+		expectOperationTotal(mock, "test-uuid", "86400 seconds", "250")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), transaction(model.TransactionWithdraw, 100))
+		assert.Equal(t, apperror.CodeDailyWithdrawalExceeded, apperror.CodeOf(err))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockLimitedWallet(mock, "test-uuid", "900.00")
+		expectOperationTotal(mock, "test-uuid", "86400 seconds", "100")
+		expectOperationTotal(mock, "test-uuid", "2592000 seconds", "1500")
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("800.00", "test-uuid").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO operations").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		expectPosting(mock, "op-1")
+		expectWebhooks(mock)
+		mock.ExpectCommit()
+
+		_, err := repo.Transaction(context.Background(), transaction(model.TransactionWithdraw, 100))
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSetWalletLimitPolicy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "100.00", "USD")
+		mock.ExpectQuery("FROM limit_policies WHERE name = \\$1").
+			WithArgs("basic").
+			WillReturnRows(sqlmock.NewRows(limitPolicyRowColumns).AddRow("basic", "USD", "1000", nil, nil, nil, nil, nil))
+		mock.ExpectExec("UPDATE wallets SET limit_policy = \\$1 WHERE id = \\$2").
+			WithArgs(sql.NullString{String: "basic", Valid: true}, "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		wallet, err := repo.SetWalletLimitPolicy(context.Background(), "wallet-a", "basic")
+		assert.NoError(t, err)
+		assert.Equal(t, "basic", wallet.LimitPolicy)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("policy in another currency", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "100.00", "EUR")
+		mock.ExpectQuery("FROM limit_policies WHERE name = \\$1").
+			WithArgs("basic").
+			WillReturnRows(sqlmock.NewRows(limitPolicyRowColumns).AddRow("basic", "USD", "1000", nil, nil, nil, nil, nil))
+		mock.ExpectRollback()
+
+		_, err := repo.SetWalletLimitPolicy(context.Background(), "wallet-a", "basic")
+		assert.ErrorIs(t, err, apperror.ErrCurrencyMismatch)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "100.00", "USD")
+		mock.ExpectQuery("FROM limit_policies WHERE name = \\$1").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := repo.SetWalletLimitPolicy(context.Background(), "wallet-a", "missing")
+		assert.ErrorIs(t, err, apperror.ErrLimitPolicyNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func expectLockCreditWallet(mock sqlmock.Sqlmock, uuid, balance, held, creditLimit string) {
+	mock.ExpectQuery("FROM wallets WHERE id = \\$1 FOR UPDATE").
+		WithArgs(uuid).
+		WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow(uuid, balance, held, "USD", model.WalletActive, false, nil, "", []byte(`{}`), nil, creditLimit, 1))
+}
+
+func TestTransactionCreditLine(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	withdraw := func(amount int64) model.Transaction {
+		return model.Transaction{Uuid: "test-uuid", OperationType: model.TransactionWithdraw, Amount: decimal.NewFromInt(amount)}
+	}
+
+	t.Run("draws on credit", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE wallets SET balance = balance - \\$1::numeric").
+			WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow("test-uuid", "-150.00", "0", "USD", model.WalletActive, false, nil, "", []byte(`{}`), nil, "200.00", 2))
+		mock.ExpectQuery("INSERT INTO operations").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		expectPosting(mock, "op-1")
+		expectWebhooks(mock)
+		mock.ExpectCommit()
+
+		op, err := repo.Transaction(context.Background(), withdraw(200))
+		assert.NoError(t, err)
+		assert.Equal(t, "-150", op.BalanceAfter.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("beyond credit limit", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockCreditWallet(mock, "test-uuid", "-150.00", "20.00", "200.00")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), withdraw(40))
+		assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestTransactionFees(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	withdraw := model.Transaction{Uuid: "test-uuid", OperationType: model.TransactionWithdraw, Amount: decimal.NewFromInt(30), Fee: decimal.RequireFromString("1.5")}
+
+	t.Run("charges the fee as its own line", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE wallets SET balance = balance - \\$1::numeric - \\$2::numeric").
+			WithArgs("30", "1.5", "test-uuid", "", int64(0)).
+			WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow("test-uuid", "68.50", "0", "USD", model.WalletActive, false, nil, "", []byte(`{}`), nil, "0", 2))
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("test-uuid", model.TransactionWithdraw, "30", "USD", "70.00", sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullString{String: withdraw.Hash(), Valid: true}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("test-uuid", model.OperationFee, "1.5", "USD", "68.50", sql.NullString{}, sql.NullString{String: "op-1", Valid: true}, sql.NullString{}, sql.NullString{}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-2", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		expectPosting(mock, "op-1",
+			walletEntry("op-1", "test-uuid", "USD", "-30"), systemEntry("op-1", model.AccountCashOut, "USD", "30"),
+			walletEntry("op-2", "test-uuid", "USD", "-1.5"), systemEntry("op-2", model.AccountFees, "USD", "1.5"))
+		expectWebhooks(mock)
+		mock.ExpectCommit()
+
+		op, err := repo.Transaction(context.Background(), withdraw)
+		assert.NoError(t, err)
+		assert.Equal(t, "70", op.BalanceAfter.String())
+		if assert.NotNil(t, op.Fee) {
+			assert.Equal(t, "op-2", op.Fee.Id)
+			assert.Equal(t, "op-1", op.Fee.ReferenceId)
+		}
+		assert.Equal(t, "68.5", op.FinalBalance().String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("low balance is checked after the fee", func(t *testing.T) {
+		small := model.Transaction{Uuid: "test-uuid", OperationType: model.TransactionWithdraw, Amount: decimal.NewFromInt(5), Fee: decimal.NewFromInt(1)}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("UPDATE wallets SET balance = balance - \\$1::numeric - \\$2::numeric").
+			WithArgs("5", "1", "test-uuid", "", int64(0)).
+			WillReturnRows(sqlmock.NewRows(walletRowColumns).AddRow("test-uuid", "99.00", "0", "USD", model.WalletActive, false, nil, "", []byte(`{}`), nil, "0", 2))
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("test-uuid", model.TransactionWithdraw, "5", "USD", "100.00", sql.NullString{}, sql.NullString{}, sql.NullString{}, sql.NullString{String: small.Hash(), Valid: true}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("test-uuid", model.OperationFee, "1", "USD", "99.00", sql.NullString{}, sql.NullString{String: "op-1", Valid: true}, sql.NullString{}, sql.NullString{}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-2", createdAt))
+		expectOutboxEvent(mock, "test-uuid")
+		expectPosting(mock, "op-1")
+		mock.ExpectExec("INSERT INTO webhook_deliveries").
+			WithArgs(model.WebhookWithdraw, model.WebhookLowBalance, "op-1", sqlmock.AnyArg(), "test-uuid", "op-1", "USD", "99.00", "99.00", "105").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		op, err := repo.Transaction(context.Background(), small)
+		assert.NoError(t, err)
+		assert.Equal(t, "99", op.FinalBalance().String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("fee counts towards available funds", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectUpdateRefused(mock)
+		expectLockWallet(mock, "test-uuid", "31.00", "USD")
+		mock.ExpectRollback()
+
+		_, err := repo.Transaction(context.Background(), withdraw)
+		assert.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("transfer fee is charged on the source wallet", func(t *testing.T) {
+		transfer := model.Transfer{
+			FromUuid:        "wallet-a",
+			ToUuid:          "wallet-b",
+			Amount:          decimal.NewFromInt(30),
+			FromCurrency:    "USD",
+			ConvertedAmount: decimal.NewFromInt(30),
+			ToCurrency:      "USD",
+			Rate:            decimal.NewFromInt(1),
+			Fee:             decimal.NewFromInt(2),
+		}
+
+		mock.ExpectBegin()
+		expectLockWallet(mock, "wallet-a", "100.00", "USD")
+		expectLockWallet(mock, "wallet-b", "10.00", "USD")
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("68.00", "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("40.00", "wallet-b").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO transfers").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("transfer-1", createdAt))
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("wallet-a", model.OperationTransferOut, "30", "USD", "70.00", sql.NullString{String: "transfer-1", Valid: true}, sql.NullString{}, sql.NullString{}, sql.NullString{}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, "wallet-a")
+		mock.ExpectQuery("INSERT INTO operations").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-2", createdAt))
+		expectOutboxEvent(mock, "wallet-b")
+		mock.ExpectQuery("INSERT INTO operations").
+			WithArgs("wallet-a", model.OperationFee, "2", "USD", "68.00", sql.NullString{}, sql.NullString{String: "op-1", Valid: true}, sql.NullString{}, sql.NullString{}).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-3", createdAt))
+		expectOutboxEvent(mock, "wallet-a")
+		expectPosting(mock, "transfer-1",
+			walletEntry("op-1", "wallet-a", "USD", "-30"), walletEntry("op-2", "wallet-b", "USD", "30"),
+			walletEntry("op-3", "wallet-a", "USD", "-2"), systemEntry("op-3", model.AccountFees, "USD", "2"))
+		expectWebhooks(mock)
+		mock.ExpectCommit()
+
+		result, err := repo.Transfer(context.Background(), transfer)
+		assert.NoError(t, err)
+		assert.Equal(t, "2", result.Fee.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestFeeRules(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	feeRuleColumns := []string{"operation_type", "currency", "flat", "percent", "min_fee", "max_fee"}
+
+	t.Run("upsert", func(t *testing.T) {
+		rule := model.FeeRule{
+			OperationType: model.TransactionWithdraw,
+			Currency:      "USD",
+			Flat:          decimal.RequireFromString("0.5"),
+			Percent:       decimal.RequireFromString("1.5"),
+			Max:           decimal.NewNullDecimal(decimal.NewFromInt(10)),
+		}
+		mock.ExpectQuery("INSERT INTO fee_rules (.+) ON CONFLICT \\(operation_type, currency\\) DO UPDATE").
+			WithArgs(model.TransactionWithdraw, "USD", "0.5", "1.5", rule.Min, rule.Max).
+			WillReturnRows(sqlmock.NewRows(feeRuleColumns).AddRow(model.TransactionWithdraw, "USD", "0.5", "1.5", nil, "10"))
+
+		result, err := repo.UpsertFeeRule(context.Background(), rule)
+		assert.NoError(t, err)
+		assert.False(t, result.Min.Valid)
+		assert.Equal(t, "10", result.Max.Decimal.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("get missing rule", func(t *testing.T) {
+		mock.ExpectQuery("FROM fee_rules WHERE operation_type = \\$1 AND currency = \\$2").
+			WithArgs(model.FeeTransfer, "EUR").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.GetFeeRule(context.Background(), model.FeeTransfer, "EUR")
+		assert.ErrorIs(t, err, apperror.ErrFeeRuleNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("delete missing rule", func(t *testing.T) {
+		mock.ExpectExec("DELETE FROM fee_rules").
+			WithArgs(model.FeeTransfer, "EUR").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := repo.DeleteFeeRule(context.Background(), model.FeeTransfer, "EUR")
+		assert.ErrorIs(t, err, apperror.ErrFeeRuleNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSetWalletCreditLimit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	t.Run("success", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockCreditWallet(mock, "wallet-a", "-50.00", "10.00", "200.00")
+		mock.ExpectExec("UPDATE wallets SET credit_limit = \\$1 WHERE id = \\$2").
+			WithArgs("60.00", "wallet-a").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		wallet, err := repo.SetWalletCreditLimit(context.Background(), "wallet-a", decimal.NewFromInt(60))
+		assert.NoError(t, err)
+		assert.Equal(t, "0", wallet.AvailableCredit().String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("below credit in use", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockCreditWallet(mock, "wallet-a", "-50.00", "10.00", "200.00")
+		mock.ExpectRollback()
+
+		_, err := repo.SetWalletCreditLimit(context.Background(), "wallet-a", decimal.NewFromInt(50))
+		assert.Equal(t, apperror.CodeCreditLimitInUse, apperror.CodeOf(err))
+		assert.EqualError(t, err, "credit limit cannot be lower than the 60.00 USD in use")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestOutbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	eventColumns := []string{"id", "event_type", "wallet_id", "payload", "created_at", "attempts"}
+
+	t.Run("claim", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE outbox_events SET attempts = attempts \\+ 1").
+			WithArgs(100, "60 seconds").
+			WillReturnRows(sqlmock.NewRows(eventColumns).
+				AddRow(int64(7), model.EventBalanceChanged, "wallet-a", []byte(`{"id":"op-2"}`), createdAt, 2).
+				AddRow(int64(5), model.EventBalanceChanged, "wallet-a", []byte(`{"id":"op-1"}`), createdAt, 1))
+
+		events, err := repo.ClaimEvents(context.Background(), 100, time.Minute)
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.Equal(t, int64(5), events[0].Id)
+		assert.JSONEq(t, `{"id":"op-1"}`, string(events[0].Payload))
+		assert.Equal(t, 2, events[1].Attempts)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("mark published", func(t *testing.T) {
+		mock.ExpectExec("UPDATE outbox_events SET published_at = now\\(\\)").
+			WithArgs(int64(5)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.MarkEventPublished(context.Background(), 5))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		mock.ExpectExec("UPDATE outbox_events SET next_attempt_at = \\$1, last_error = \\$2 WHERE id = \\$3").
+			WithArgs(createdAt, "broker unavailable", int64(7)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		assert.NoError(t, repo.RetryEvent(context.Background(), 7, createdAt, "broker unavailable"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("delete unknown subscription", func(t *testing.T) {
+		mock.ExpectExec("DELETE FROM webhook_subscriptions WHERE id = \\$1").
+			WithArgs("missing").
+			WillReturnError(&pq.Error{Code: invalidTextRepresentation})
+
+		err := repo.DeleteWebhookSubscription(context.Background(), "missing")
+		assert.ErrorIs(t, err, apperror.ErrWebhookNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("redeliver", func(t *testing.T) {
+		columns := []string{"id", "subscription_id", "event_type", "operation_id", "payload", "status", "attempts", "last_error", "next_attempt_at", "created_at", "delivered_at"}
+		mock.ExpectQuery("UPDATE webhook_deliveries\\s+SET status = 'PENDING', attempts = 0").
+			WithArgs("delivery-1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("delivery-1", "sub-1", model.WebhookDeposit, "op-1", []byte(`{"id":"op-1"}`), model.DeliveryPending, 0, nil, createdAt, createdAt, nil))
+
+		delivery, err := repo.RedeliverWebhook(context.Background(), "delivery-1")
+		assert.NoError(t, err)
+		assert.Equal(t, model.DeliveryPending, delivery.Status)
+		assert.Nil(t, delivery.DeliveredAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("redeliver unknown delivery", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE webhook_deliveries\\s+SET status = 'PENDING'").
+			WithArgs("delivery-2").
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.RedeliverWebhook(context.Background(), "delivery-2")
+		assert.ErrorIs(t, err, apperror.ErrDeliveryNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("claim", func(t *testing.T) {
+		columns := []string{"id", "subscription_id", "event_type", "operation_id", "payload", "status", "attempts", "last_error", "next_attempt_at", "created_at", "delivered_at", "url", "secret"}
+		mock.ExpectQuery("UPDATE webhook_deliveries d SET attempts = d.attempts \\+ 1").
+			WithArgs(50, "600 seconds").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("delivery-1", "sub-1", model.WebhookDeposit, "op-1", []byte(`{}`), model.DeliveryPending, 3, "timeout", createdAt, createdAt, nil, "https://example.com/hook", "secret"))
+
+		deliveries, err := repo.ClaimWebhookDeliveries(context.Background(), 50, 10*time.Minute)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+		assert.Equal(t, "https://example.com/hook", deliveries[0].Url)
+		assert.Equal(t, "secret", deliveries[0].Secret)
+		assert.Equal(t, "timeout", deliveries[0].LastError)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+var scheduleRowColumns = []string{"id", "wallet_id", "operation_type", "amount", "currency", "cron", "status", "next_run_at",
+	"runs", "failures", "attempts", "last_error", "last_run_at", "last_operation_id", "created_at"}
+
+func TestSchedules(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	createdAt := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	next := time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)
+
+	t.Run("create", func(t *testing.T) {
+		mock.ExpectQuery("INSERT INTO schedules").
+			WithArgs("wallet-a", model.TransactionWithdraw, "9.99", "USD", sql.NullString{String: "0 9 1 * *", Valid: true}, next).
+			WillReturnRows(sqlmock.NewRows(scheduleRowColumns).
+				AddRow("schedule-1", "wallet-a", model.TransactionWithdraw, "9.99", "USD", "0 9 1 * *", model.ScheduleActive, next, 0, 0, 0, nil, nil, nil, createdAt))
+
+		schedule, err := repo.CreateSchedule(context.Background(), model.Schedule{
+			WalletId:      "wallet-a",
+			OperationType: model.TransactionWithdraw,
+			Amount:        decimal.RequireFromString("9.99"),
+			Currency:      "USD",
+			Cron:          "0 9 1 * *",
+			NextRunAt:     &next,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "schedule-1", schedule.Id)
+		assert.Equal(t, next, *schedule.NextRunAt)
+		assert.Nil(t, schedule.LastRunAt)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("get unknown schedule", func(t *testing.T) {
+		mock.ExpectQuery("FROM schedules WHERE id = \\$1").
+			WithArgs("not-a-uuid").
+			WillReturnError(&pq.Error{Code: invalidTextRepresentation})
+
+		_, err := repo.GetSchedule(context.Background(), "not-a-uuid")
+		assert.ErrorIs(t, err, apperror.ErrScheduleNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM schedules WHERE id = \\$1 FOR UPDATE").
+			WithArgs("schedule-1").
+			WillReturnRows(sqlmock.NewRows(scheduleRowColumns).
+				AddRow("schedule-1", "wallet-a", model.TransactionWithdraw, "9.99", "USD", "0 9 1 * *", model.ScheduleActive, next, 2, 0, 0, nil, createdAt, "op-1", createdAt))
+		mock.ExpectExec("UPDATE schedules SET status = 'CANCELLED'").
+			WithArgs("schedule-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		schedule, err := repo.CancelSchedule(context.Background(), "schedule-1")
+		assert.NoError(t, err)
+		assert.Equal(t, model.ScheduleCancelled, schedule.Status)
+		assert.Nil(t, schedule.NextRunAt)
+		assert.Equal(t, 2, schedule.Runs)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("cancel completed schedule", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM schedules WHERE id = \\$1 FOR UPDATE").
+			WithArgs("schedule-2").
+			WillReturnRows(sqlmock.NewRows(scheduleRowColumns).
+				AddRow("schedule-2", "wallet-a", model.TransactionDeposit, "100", "USD", nil, model.ScheduleCompleted, nil, 1, 0, 0, nil, createdAt, "op-2", createdAt))
+		mock.ExpectRollback()
+
+		_, err := repo.CancelSchedule(context.Background(), "schedule-2")
+		assert.ErrorIs(t, err, apperror.ErrScheduleNotActive)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("claim", func(t *testing.T) {
+		mock.ExpectQuery("UPDATE schedules SET attempts = attempts \\+ 1").
+			WithArgs(50, "300 seconds").
+			WillReturnRows(sqlmock.NewRows(scheduleRowColumns).
+				AddRow("schedule-1", "wallet-a", model.TransactionWithdraw, "9.99", "USD", "0 9 1 * *", model.ScheduleActive, next, 2, 0, 1, nil, nil, nil, createdAt))
+
+		schedules, err := repo.ClaimDueSchedules(context.Background(), 50, 5*time.Minute)
+		assert.NoError(t, err)
+		assert.Len(t, schedules, 1)
+		assert.Equal(t, 1, schedules[0].Attempts)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("skip missed runs", func(t *testing.T) {
+		mock.ExpectExec("UPDATE schedules SET next_run_at = \\$1, updated_at = now\\(\\) WHERE id = \\$2 AND status = 'ACTIVE'").
+			WithArgs(next, "schedule-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.SkipScheduleRuns(context.Background(), "schedule-1", next)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("complete last run", func(t *testing.T) {
+		mock.ExpectExec("UPDATE schedules SET\\s+status = CASE WHEN \\$1::timestamptz IS NULL THEN 'COMPLETED'").
+			WithArgs(nil, "op-3", "schedule-3").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.CompleteScheduleRun(context.Background(), "schedule-3", "op-3", nil)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("fail and move on", func(t *testing.T) {
+		mock.ExpectExec("UPDATE schedules SET\\s+status = CASE WHEN \\$1::timestamptz IS NULL THEN 'FAILED'").
+			WithArgs(next, "insufficient funds", "schedule-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := repo.FailScheduleRun(context.Background(), "schedule-1", "insufficient funds", &next)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	repo := NewRepository(db, logger)
+
+	const walletA = "00000000-0000-0000-0000-00000000000a"
+	const walletB = "00000000-0000-0000-0000-00000000000b"
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	transactions := []model.Transaction{
+		{Uuid: walletB, OperationType: model.TransactionDeposit, Amount: decimal.NewFromInt(10)},
+		{Uuid: walletA, OperationType: model.TransactionWithdraw, Amount: decimal.NewFromInt(500)},
+		{Uuid: walletB, OperationType: model.TransactionWithdraw, Amount: decimal.NewFromInt(5)},
+	}
+	expectLockBatch := func() {
+		mock.ExpectQuery("SELECT id FROM wallets WHERE id = ANY\\(\\$1::uuid\\[\\]\\) ORDER BY id FOR UPDATE").
+			WithArgs(pq.Array([]string{walletA, walletB})).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(walletA).AddRow(walletB))
+	}
+
+	t.Run("atomic rolls back on the first failure", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockBatch()
+		expectLockWallet(mock, walletB, "100.00", "USD")
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("110.00", walletB).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO operations").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, walletB)
+		expectPosting(mock, "op-1")
+		expectWebhooks(mock)
+		expectLockWallet(mock, walletA, "100.00", "USD")
+		mock.ExpectRollback()
+
+		results, err := repo.Batch(context.Background(), transactions, model.BatchOptions{Atomic: true})
+		assert.NoError(t, err)
+		assert.Len(t, results, 3)
+		assert.Equal(t, apperror.CodeBatchAborted, apperror.CodeOf(results[0].Err))
+		assert.ErrorIs(t, results[1].Err, apperror.ErrInsufficientFunds)
+		assert.EqualError(t, results[2].Err, "not applied because item 1 failed")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("best effort keeps the items that succeed", func(t *testing.T) {
+		mock.ExpectBegin()
+		expectLockBatch()
+		mock.ExpectExec("SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLockWallet(mock, walletB, "100.00", "USD")
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("110.00", walletB).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO operations").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, walletB)
+		expectPosting(mock, "op-1")
+		expectWebhooks(mock)
+		mock.ExpectExec("RELEASE SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLockWallet(mock, walletA, "100.00", "USD")
+		mock.ExpectExec("ROLLBACK TO SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+		expectLockWallet(mock, walletB, "110.00", "USD")
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("105.00", walletB).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO operations").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-2", createdAt))
+		expectOutboxEvent(mock, walletB)
+		expectPosting(mock, "op-2")
+		expectWebhooks(mock)
+		mock.ExpectExec("RELEASE SAVEPOINT batch_item").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		results, err := repo.Batch(context.Background(), transactions, model.BatchOptions{})
+		assert.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, "op-1", results[0].Operation.Id)
+		assert.ErrorIs(t, results[1].Err, apperror.ErrInsufficientFunds)
+		assert.NoError(t, results[2].Err)
+		assert.Equal(t, "105", results[2].Operation.BalanceAfter.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("dry run rolls back", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id FROM wallets WHERE id = ANY").
+			WithArgs(pq.Array([]string{walletB})).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(walletB))
+		expectLockWallet(mock, walletB, "100.00", "USD")
+		mock.ExpectExec("UPDATE wallets SET balance = \\$1 WHERE id = \\$2").
+			WithArgs("110.00", walletB).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("INSERT INTO operations").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("op-1", createdAt))
+		expectOutboxEvent(mock, walletB)
+		expectPosting(mock, "op-1")
+		expectWebhooks(mock)
+		mock.ExpectRollback()
+
+		results, err := repo.Batch(context.Background(), transactions[:1], model.BatchOptions{Atomic: true, DryRun: true})
+		assert.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, "110", results[0].Operation.BalanceAfter.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
@@ -0,0 +1,222 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/lib/pq"
+)
+
+const scheduleColumns = `id, wallet_id, operation_type, amount, currency, cron, status, next_run_at,
+	runs, failures, attempts, last_error, last_run_at, last_operation_id, created_at`
+
+func (r *repository) CreateSchedule(ctx context.Context, schedule model.Schedule) (model.Schedule, error) {
+	const query = `INSERT INTO schedules (wallet_id, operation_type, amount, currency, cron, next_run_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6) RETURNING ` + scheduleColumns
+
+	result, err := scanSchedule(r.db.QueryRowContext(ctx, query,
+		schedule.WalletId, schedule.OperationType, schedule.Amount.String(), schedule.Currency,
+		nullString(schedule.Cron), schedule.NextRunAt,
+	))
+	if err != nil {
+		return model.Schedule{}, fmt.Errorf("insert schedule: %w", err)
+	}
+	return result, nil
+}
+
+func (r *repository) GetSchedule(ctx context.Context, id string) (model.Schedule, error) {
+	const query = `SELECT ` + scheduleColumns + ` FROM schedules WHERE id = $1`
+
+	schedule, err := scanSchedule(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return model.Schedule{}, fmt.Errorf("get schedule: %w", scheduleError(err))
+	}
+	return schedule, nil
+}
+
+// ListSchedules returns the schedules of a wallet, oldest first, optionally
+// only those in status.
+func (r *repository) ListSchedules(ctx context.Context, walletId, status string) ([]model.Schedule, error) {
+	const query = `SELECT ` + scheduleColumns + ` FROM schedules
+		WHERE wallet_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, walletId, status)
+	if err != nil {
+		return nil, fmt.Errorf("list schedules: %w", walletError(err))
+	}
+	defer rows.Close()
+
+	schedules := []model.Schedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// CancelSchedule stops an active schedule from running again. A run already
+// claimed by the runner may still go through.
+func (r *repository) CancelSchedule(ctx context.Context, id string) (result model.Schedule, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Schedule{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	schedule, err := scanSchedule(tx.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return model.Schedule{}, fmt.Errorf("get schedule: %w", scheduleError(err))
+	}
+	if schedule.Status != model.ScheduleActive {
+		return model.Schedule{}, apperror.ErrScheduleNotActive
+	}
+
+	const query = `UPDATE schedules SET status = 'CANCELLED', next_run_at = NULL, next_attempt_at = NULL, updated_at = now() WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return model.Schedule{}, fmt.Errorf("cancel schedule: %w", err)
+	}
+
+	schedule.Status = model.ScheduleCancelled
+	schedule.NextRunAt = nil
+	return schedule, nil
+}
+
+// ClaimDueSchedules picks up to limit active schedules that are due and hides
+// them from other runners for lease.
+func (r *repository) ClaimDueSchedules(ctx context.Context, limit int, lease time.Duration) ([]model.Schedule, error) {
+	const query = `UPDATE schedules SET attempts = attempts + 1, next_attempt_at = now() + $2::interval
+		WHERE id IN (
+			SELECT id FROM schedules
+			WHERE status = 'ACTIVE' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + scheduleColumns
+
+	rows, err := r.db.QueryContext(ctx, query, limit, interval(lease))
+	if err != nil {
+		return nil, fmt.Errorf("claim schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []model.Schedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// SkipScheduleRuns moves the current occurrence of a schedule that fell behind
+// on to to, the latest one that is due, so the missed ones are not run.
+func (r *repository) SkipScheduleRuns(ctx context.Context, id string, to time.Time) error {
+	const query = `UPDATE schedules SET next_run_at = $1, updated_at = now() WHERE id = $2 AND status = 'ACTIVE'`
+
+	if _, err := r.db.ExecContext(ctx, query, to, id); err != nil {
+		return fmt.Errorf("skip schedule runs: %w", err)
+	}
+	return nil
+}
+
+// CompleteScheduleRun records that the current occurrence made operationId and
+// moves the schedule on to next, completing it when next is nil. Schedules
+// cancelled in the meantime are left alone.
+func (r *repository) CompleteScheduleRun(ctx context.Context, id, operationId string, next *time.Time) error {
+	const query = `UPDATE schedules SET
+			status = CASE WHEN $1::timestamptz IS NULL THEN 'COMPLETED' ELSE status END,
+			next_run_at = $1, next_attempt_at = $1, runs = runs + 1, attempts = 0, last_error = NULL,
+			last_run_at = now(), last_operation_id = $2, updated_at = now()
+		WHERE id = $3 AND status = 'ACTIVE'`
+
+	if _, err := r.db.ExecContext(ctx, query, next, operationId, id); err != nil {
+		return fmt.Errorf("complete schedule run: %w", err)
+	}
+	return nil
+}
+
+// RetryScheduleRun tries the current occurrence again at at.
+func (r *repository) RetryScheduleRun(ctx context.Context, id string, at time.Time, cause string) error {
+	const query = `UPDATE schedules SET next_attempt_at = $1, last_error = $2, updated_at = now()
+		WHERE id = $3 AND status = 'ACTIVE'`
+
+	if _, err := r.db.ExecContext(ctx, query, at, cause, id); err != nil {
+		return fmt.Errorf("reschedule schedule run: %w", err)
+	}
+	return nil
+}
+
+// FailScheduleRun gives up on the current occurrence and moves the schedule on
+// to next, failing it when next is nil.
+func (r *repository) FailScheduleRun(ctx context.Context, id string, cause string, next *time.Time) error {
+	const query = `UPDATE schedules SET
+			status = CASE WHEN $1::timestamptz IS NULL THEN 'FAILED' ELSE status END,
+			next_run_at = $1, next_attempt_at = $1, failures = failures + 1, attempts = 0, last_error = $2,
+			last_run_at = now(), updated_at = now()
+		WHERE id = $3 AND status = 'ACTIVE'`
+
+	if _, err := r.db.ExecContext(ctx, query, next, cause, id); err != nil {
+		return fmt.Errorf("fail schedule run: %w", err)
+	}
+	return nil
+}
+
+func scanSchedule(row rowScanner) (model.Schedule, error) {
+	var s model.Schedule
+	var cron, lastError, lastOperationId sql.NullString
+	var nextRunAt, lastRunAt sql.NullTime
+
+	err := row.Scan(&s.Id, &s.WalletId, &s.OperationType, &s.Amount, &s.Currency, &cron, &s.Status, &nextRunAt,
+		&s.Runs, &s.Failures, &s.Attempts, &lastError, &lastRunAt, &lastOperationId, &s.CreatedAt)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+	s.Cron = cron.String
+	s.LastError = lastError.String
+	s.LastOperationId = lastOperationId.String
+	if nextRunAt.Valid {
+		s.NextRunAt = &nextRunAt.Time
+	}
+	if lastRunAt.Valid {
+		s.LastRunAt = &lastRunAt.Time
+	}
+
+	return s, nil
+}
+
+// scheduleError is the schedule counterpart of walletError.
+func scheduleError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperror.ErrScheduleNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == invalidTextRepresentation {
+		return apperror.ErrScheduleNotFound
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"wallet-service/internal/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// operationChange is the signed effect of operation o on its wallet's
+// balance. A refund moves money the opposite way to the operation r it
+// references.
+const operationChange = `CASE
+		WHEN o.operation_type IN ('` + model.TransactionDeposit + `', '` + model.OperationTransferIn + `') THEN o.amount
+		WHEN o.operation_type = '` + model.TransactionRefund + `' AND r.operation_type = '` + model.TransactionWithdraw + `' THEN o.amount
+		ELSE -o.amount
+	END`
+
+const statementColumns = `o.id, o.wallet_id, o.operation_type, o.amount, o.currency, o.balance_after, o.transfer_id, o.reference_id, o.created_at`
+
+// GetStatement builds the statement of a wallet for [from, to). The opening
+// balance and every running balance are summed from the operations rather
+// than read from wallets.balance, in one snapshot so that operations written
+// meanwhile cannot skew them.
+func (r *repository) GetStatement(ctx context.Context, walletId string, from, to time.Time) (statement model.Statement, err error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return model.Statement{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	const walletQuery = `SELECT ` + walletColumns + ` FROM wallets WHERE id = $1`
+	wallet, err := scanWallet(tx.QueryRowContext(ctx, walletQuery, walletId))
+	if err != nil {
+		return model.Statement{}, walletError(err)
+	}
+
+	const openingQuery = `SELECT COALESCE(SUM(` + operationChange + `), 0)
+		FROM operations o LEFT JOIN operations r ON r.id = o.reference_id
+		WHERE o.wallet_id = $1 AND o.created_at < $2`
+
+	var opening decimal.Decimal
+	if err = tx.QueryRowContext(ctx, openingQuery, walletId, from).Scan(&opening); err != nil {
+		return model.Statement{}, fmt.Errorf("sum opening balance: %w", err)
+	}
+
+	const linesQuery = `SELECT ` + statementColumns + `, ` + operationChange + `
+		FROM operations o LEFT JOIN operations r ON r.id = o.reference_id
+		WHERE o.wallet_id = $1 AND o.created_at >= $2 AND o.created_at < $3
+		ORDER BY o.created_at, o.id`
+
+	rows, err := tx.QueryContext(ctx, linesQuery, walletId, from, to)
+	if err != nil {
+		return model.Statement{}, fmt.Errorf("list statement operations: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []model.StatementLine{}
+	for rows.Next() {
+		var line model.StatementLine
+		if line.Operation, err = scanOperation(rows, &line.Change); err != nil {
+			return model.Statement{}, fmt.Errorf("scan operation: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	if err = rows.Err(); err != nil {
+		return model.Statement{}, err
+	}
+
+	return model.NewStatement(wallet, from, to, opening, lines), nil
+}
+
+// GetBalanceAsOf sums the operations of a wallet recorded up to and including
+// at. A wallet that had no operations by then held nothing.
+func (r *repository) GetBalanceAsOf(ctx context.Context, walletId string, at time.Time) (model.HistoricalBalance, error) {
+	wallet, err := r.GetWalletByUuid(ctx, walletId)
+	if err != nil {
+		return model.HistoricalBalance{}, err
+	}
+
+	const query = `SELECT COALESCE(SUM(` + operationChange + `), 0)
+		FROM operations o LEFT JOIN operations r ON r.id = o.reference_id
+		WHERE o.wallet_id = $1 AND o.created_at <= $2`
+
+	var balance decimal.Decimal
+	if err := r.db.QueryRowContext(ctx, query, walletId, at).Scan(&balance); err != nil {
+		return model.HistoricalBalance{}, fmt.Errorf("sum balance: %w", err)
+	}
+
+	return model.HistoricalBalance{WalletId: wallet.Id, Currency: wallet.Currency, Balance: balance, AsOf: at}, nil
+}
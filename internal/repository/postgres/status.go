@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+)
+
+// ChangeWalletStatus moves the wallet to change.Status, or only updates
+// whether a frozen wallet accepts deposits, and appends the change to its
+// audit trail. The transition is checked under the wallet row lock, so
+// it cannot race with operations on the wallet.
+func (r *repository) ChangeWalletStatus(ctx context.Context, change model.StatusChange) (result model.StatusChange, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.StatusChange{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	wallet, err := lockWallet(ctx, tx, change.WalletId)
+	if err != nil {
+		return model.StatusChange{}, err
+	}
+
+	if wallet.Status == change.Status && wallet.AllowDeposits == change.AllowDeposits {
+		return model.StatusChange{}, apperror.Errorf(apperror.CodeInvalidTransition, "wallet is already %s", wallet.Status)
+	}
+	if !model.CanTransition(wallet.Status, change.Status) {
+		return model.StatusChange{}, apperror.Errorf(apperror.CodeInvalidTransition, "wallet cannot move from %s to %s", wallet.Status, change.Status)
+	}
+	if change.Status == model.WalletClosed && (!wallet.Balance.IsZero() || !wallet.Held.IsZero()) {
+		return model.StatusChange{}, apperror.ErrBalanceNotZero
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE wallets SET status = $1, allow_deposits = $2 WHERE id = $3`,
+		change.Status, change.AllowDeposits, wallet.Id)
+	if err != nil {
+		return model.StatusChange{}, fmt.Errorf("update status: %w", err)
+	}
+
+	result = change
+	result.FromStatus = wallet.Status
+	err = tx.QueryRowContext(ctx, `INSERT INTO wallet_status_changes (wallet_id, from_status, to_status, reason, actor, allow_deposits)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		wallet.Id, wallet.Status, change.Status, change.Reason, change.Actor, change.AllowDeposits,
+	).Scan(&result.Id, &result.CreatedAt)
+	if err != nil {
+		return model.StatusChange{}, fmt.Errorf("insert status change: %w", err)
+	}
+
+	return result, nil
+}
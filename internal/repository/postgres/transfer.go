@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+)
+
+// Transfer moves funds between two wallets in a single database transaction.
+// Both rows are locked in ascending id order, the order batches lock them in,
+// so concurrent transfers in opposite directions cannot deadlock.
+func (r *repository) Transfer(ctx context.Context, transfer model.Transfer) (result model.Transfer, err error) {
+	// The same wallet spelled in another case would otherwise be read twice,
+	// and the credit would overwrite the debit.
+	transfer.FromUuid = model.CanonicalWalletId(transfer.FromUuid)
+	transfer.ToUuid = model.CanonicalWalletId(transfer.ToUuid)
+	if transfer.FromUuid == transfer.ToUuid {
+		return model.Transfer{}, apperror.Errorf(apperror.CodeValidation, "source and destination wallets must differ")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Transfer{}, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	first, second := transfer.FromUuid, transfer.ToUuid
+	if second < first {
+		first, second = second, first
+	}
+
+	wallets := make(map[string]model.Wallet, 2)
+	for _, uuid := range []string{first, second} {
+		wallet, err := lockWallet(ctx, tx, uuid)
+		if err != nil {
+			return model.Transfer{}, err
+		}
+		wallets[uuid] = wallet
+	}
+	from, to := wallets[transfer.FromUuid], wallets[transfer.ToUuid]
+
+	// The service prices the transfer before the rows are locked; a wallet
+	// currency never changes, but the quote must match the wallets it is for.
+	if from.Currency != transfer.FromCurrency || to.Currency != transfer.ToCurrency {
+		return model.Transfer{}, apperror.ErrCurrencyMismatch
+	}
+
+	if err := from.CheckDebit(); err != nil {
+		return model.Transfer{}, err
+	}
+	if err := to.CheckCredit(); err != nil {
+		return model.Transfer{}, err
+	}
+
+	amount, err := roundAmount(transfer.Amount, from.Currency)
+	if err != nil {
+		return model.Transfer{}, err
+	}
+	converted, err := roundAmount(transfer.ConvertedAmount, to.Currency)
+	if err != nil {
+		return model.Transfer{}, err
+	}
+
+	if from.Available().LessThan(amount.Add(transfer.Fee)) {
+		return model.Transfer{}, apperror.ErrInsufficientFunds
+	}
+
+	from.Balance = from.Balance.Sub(amount)
+	balanceAfter := from.Balance
+	from.Balance = from.Balance.Sub(transfer.Fee)
+	to.Balance = to.Balance.Add(converted)
+
+	if err := checkLimits(ctx, tx, from, false, amount); err != nil {
+		return model.Transfer{}, err
+	}
+	if err := checkLimits(ctx, tx, to, true, converted); err != nil {
+		return model.Transfer{}, err
+	}
+
+	if err := updateBalance(ctx, tx, from); err != nil {
+		return model.Transfer{}, err
+	}
+	if err := updateBalance(ctx, tx, to); err != nil {
+		return model.Transfer{}, err
+	}
+
+	result = transfer
+	result.Amount = amount
+	result.ConvertedAmount = converted
+	err = tx.QueryRowContext(ctx, `INSERT INTO transfers (from_wallet_id, to_wallet_id, amount, from_currency, converted_amount, to_currency, rate, rate_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`,
+		transfer.FromUuid, transfer.ToUuid, amount.String(), from.Currency, converted.String(), to.Currency, transfer.Rate.String(), transfer.RateTimestamp,
+	).Scan(&result.Id, &result.CreatedAt)
+	if err != nil {
+		return model.Transfer{}, fmt.Errorf("insert transfer: %w", err)
+	}
+
+	legs := []model.Operation{
+		{WalletId: from.Id, OperationType: model.OperationTransferOut, Amount: amount, Currency: from.Currency, BalanceAfter: balanceAfter, TransferId: result.Id},
+		{WalletId: to.Id, OperationType: model.OperationTransferIn, Amount: converted, Currency: to.Currency, BalanceAfter: to.Balance, TransferId: result.Id},
+	}
+	for i := range legs {
+		if err := insertOperation(ctx, tx, &legs[i], "", ""); err != nil {
+			return model.Transfer{}, fmt.Errorf("insert operation: %w", err)
+		}
+	}
+
+	// Within a currency the money moves straight between the wallets; between
+	// currencies each leg is settled against FX.
+	out, in := model.WalletEntry(legs[0], false), model.WalletEntry(legs[1], true)
+	entries := []model.LedgerEntry{out, in}
+	if from.Currency != to.Currency {
+		entries = append(entries, model.SystemEntry(model.AccountFX, out), model.SystemEntry(model.AccountFX, in))
+	}
+	if transfer.Fee.IsPositive() {
+		feeEntries, err := chargeFee(ctx, tx, from, &legs[0], transfer.Fee)
+		if err != nil {
+			return model.Transfer{}, err
+		}
+		entries = append(entries, feeEntries...)
+	}
+	if err := postEntries(ctx, tx, result.Id, entries); err != nil {
+		return model.Transfer{}, err
+	}
+	if err := enqueueWebhooks(ctx, tx, legs[0], balanceAfter.Add(amount)); err != nil {
+		return model.Transfer{}, err
+	}
+
+	return result, nil
+}
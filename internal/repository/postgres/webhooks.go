@@ -0,0 +1,261 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const deliveryColumns = `id, subscription_id, event_type, operation_id, payload, status, attempts, last_error, next_attempt_at, created_at, delivered_at`
+
+// webhookDeliveryPage bounds how many deliveries a listing returns.
+const webhookDeliveryPage = 100
+
+func (r *repository) CreateWebhookSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+	const query = `INSERT INTO webhook_subscriptions (url, event_types, secret, low_balance_threshold, low_balance_currency)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, sub.Url, pq.Array(sub.EventTypes), sub.Secret, sub.LowBalanceThreshold, nullString(sub.LowBalanceCurrency)).
+		Scan(&sub.Id, &sub.CreatedAt)
+	if err != nil {
+		return model.WebhookSubscription{}, fmt.Errorf("insert webhook subscription: %w", err)
+	}
+	return sub, nil
+}
+
+// ListWebhookSubscriptions returns every subscription without its secret.
+func (r *repository) ListWebhookSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	const query = `SELECT id, url, event_types, low_balance_threshold, low_balance_currency, created_at FROM webhook_subscriptions ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []model.WebhookSubscription{}
+	for rows.Next() {
+		var sub model.WebhookSubscription
+		var currency sql.NullString
+		if err := rows.Scan(&sub.Id, &sub.Url, pq.Array(&sub.EventTypes), &sub.LowBalanceThreshold, &currency, &sub.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan webhook subscription: %w", err)
+		}
+		sub.LowBalanceCurrency = currency.String
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// DeleteWebhookSubscription removes the subscription along with its deliveries.
+func (r *repository) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", webhookError(err, apperror.ErrWebhookNotFound))
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", err)
+	}
+	if n == 0 {
+		return apperror.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// enqueueWebhooks queues, in the transaction that records op, the event
+// announcing op for every subscription that asked for it, and a low-balance
+// event for every subscription whose threshold, in the currency of op, the
+// wallet dropped below between balanceBefore and the balance left once op
+// and its fee are applied. Queuing the same operation twice is a no-op.
+func enqueueWebhooks(ctx context.Context, tx *sql.Tx, op model.Operation, balanceBefore decimal.Decimal) error {
+	balanceAfter := op.FinalBalance()
+	eventType, _ := model.WebhookEventFor(op.OperationType)
+	if eventType == "" && !balanceAfter.LessThan(balanceBefore) {
+		return nil
+	}
+
+	const query = `INSERT INTO webhook_deliveries (subscription_id, event_type, operation_id, payload)
+		SELECT id, $1::text, $3::uuid, $4::jsonb FROM webhook_subscriptions WHERE $1 = ANY(event_types)
+		UNION ALL
+		SELECT id, $2::text, $3::uuid, jsonb_build_object(
+			'walletId', $5::text, 'operationId', $6::text, 'currency', $7::text,
+			'balance', $8::text, 'threshold', low_balance_threshold::text)
+		FROM webhook_subscriptions
+		WHERE $2 = ANY(event_types) AND low_balance_currency = $7
+			AND $9::decimal < low_balance_threshold AND $10::decimal >= low_balance_threshold
+		ON CONFLICT ON CONSTRAINT one_delivery_per_event DO NOTHING`
+
+	payload, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+	balance := model.FormatAmount(balanceAfter, op.Currency)
+	_, err = tx.ExecContext(ctx, query,
+		eventType, model.WebhookLowBalance, op.Id, string(payload),
+		op.WalletId, op.Id, op.Currency, balance, balance, balanceBefore.String())
+	if err != nil {
+		return fmt.Errorf("enqueue webhooks: %w", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the latest deliveries of a subscription,
+// newest first, optionally only those in status.
+func (r *repository) ListWebhookDeliveries(ctx context.Context, subscriptionId, status string) ([]model.WebhookDelivery, error) {
+	const query = `SELECT ` + deliveryColumns + ` FROM webhook_deliveries
+		WHERE subscription_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC LIMIT $3`
+
+	rows, err := r.db.QueryContext(ctx, query, subscriptionId, status, webhookDeliveryPage)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", webhookError(err, apperror.ErrWebhookNotFound))
+	}
+	return scanDeliveries(rows)
+}
+
+// RedeliverWebhook puts a delivery back in the queue with a fresh set of
+// attempts, whatever state it is in.
+func (r *repository) RedeliverWebhook(ctx context.Context, id string) (model.WebhookDelivery, error) {
+	const query = `UPDATE webhook_deliveries
+		SET status = 'PENDING', attempts = 0, last_error = NULL, next_attempt_at = now(), delivered_at = NULL
+		WHERE id = $1 RETURNING ` + deliveryColumns
+
+	delivery, err := scanDelivery(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return model.WebhookDelivery{}, fmt.Errorf("redeliver webhook: %w", webhookError(err, apperror.ErrDeliveryNotFound))
+	}
+	return delivery, nil
+}
+
+// ClaimWebhookDeliveries picks up to limit pending deliveries that are due,
+// along with the URL and secret of their subscription, and hides them from
+// other dispatchers for lease.
+func (r *repository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	const query = `UPDATE webhook_deliveries d SET attempts = d.attempts + 1, next_attempt_at = now() + $2::interval
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'PENDING' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.subscription_id, d.event_type, d.operation_id, d.payload, d.status, d.attempts,
+			d.last_error, d.next_attempt_at, d.created_at, d.delivered_at, s.url, s.secret`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, interval(lease))
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		var d model.WebhookDelivery
+		if err := scanDeliveryInto(rows, &d, &d.Url, &d.Secret); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *repository) MarkWebhookDelivered(ctx context.Context, id string) error {
+	const query = `UPDATE webhook_deliveries SET status = 'DELIVERED', last_error = NULL, delivered_at = now() WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("mark webhook delivered: %w", err)
+	}
+	return nil
+}
+
+// RetryWebhookDelivery reschedules a failed delivery for at.
+func (r *repository) RetryWebhookDelivery(ctx context.Context, id string, at time.Time, cause string) error {
+	const query = `UPDATE webhook_deliveries SET next_attempt_at = $1, last_error = $2 WHERE id = $3`
+
+	if _, err := r.db.ExecContext(ctx, query, at, cause, id); err != nil {
+		return fmt.Errorf("reschedule webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// DeadLetterWebhookDelivery gives up on a delivery until it is redelivered.
+func (r *repository) DeadLetterWebhookDelivery(ctx context.Context, id string, cause string) error {
+	const query = `UPDATE webhook_deliveries SET status = 'DEAD', last_error = $1 WHERE id = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, cause, id); err != nil {
+		return fmt.Errorf("dead-letter webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func scanDelivery(row rowScanner) (model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	if err := scanDeliveryInto(row, &d); err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	return d, nil
+}
+
+// scanDeliveryInto reads deliveryColumns into d, followed by extra columns.
+func scanDeliveryInto(row rowScanner, d *model.WebhookDelivery, extra ...any) error {
+	var payload []byte
+	var lastError sql.NullString
+	var deliveredAt sql.NullTime
+
+	dest := []any{&d.Id, &d.SubscriptionId, &d.EventType, &d.OperationId, &payload, &d.Status, &d.Attempts,
+		&lastError, &d.NextAttemptAt, &d.CreatedAt, &deliveredAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	d.Payload = payload
+	d.LastError = lastError.String
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return nil
+}
+
+func scanDeliveries(rows *sql.Rows) ([]model.WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// webhookError maps a missing row or a malformed id to notFound.
+func webhookError(err error, notFound error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == invalidTextRepresentation {
+		return notFound
+	}
+	return err
+}
@@ -7,11 +7,49 @@ import (
 )
 
 func SetupRouter(handler handler.Handler) *fiber.App {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		ErrorHandler: handler.ErrorHandler,
+	})
 
 	app.Post("api/v1/wallets", handler.CreateWallet)
+	app.Get("api/v1/wallets", handler.ListWallets)
 	app.Post("api/v1/wallet", handler.Transaction)
+	app.Post("api/v1/wallet/transfer", handler.Transfer)
+	app.Post("api/v1/wallet/batch", handler.Batch)
+	app.Post("api/v1/wallet/refund", handler.Refund)
+	app.Post("api/v1/wallet/holds/:id/capture", handler.CaptureHold)
+	app.Post("api/v1/wallet/holds/:id/void", handler.VoidHold)
+	app.Post("api/v1/wallet/:uuid/holds", handler.AuthorizeHold)
+	app.Get("api/v1/wallet/schedules/:id", handler.GetSchedule)
+	app.Post("api/v1/wallet/schedules/:id/cancel", handler.CancelSchedule)
+	app.Post("api/v1/wallet/:uuid/schedules", handler.CreateSchedule)
+	app.Get("api/v1/wallet/:uuid/schedules", handler.ListSchedules)
 	app.Get("api/v1/wallet/:uuid", handler.GetWallet)
+	app.Get("api/v1/wallet/:uuid/transactions", handler.ListTransactions)
+	app.Get("api/v1/wallet/:uuid/statement", handler.GetStatement)
+	app.Get("api/v1/wallet/:uuid/fee-quote", handler.QuoteFee)
+	app.Post("api/v1/webhooks", handler.CreateWebhook)
+	app.Get("api/v1/webhooks", handler.ListWebhooks)
+	app.Delete("api/v1/webhooks/:id", handler.DeleteWebhook)
+	app.Get("api/v1/webhooks/:id/deliveries", handler.ListWebhookDeliveries)
+	app.Post("api/v1/webhooks/deliveries/:id/redeliver", handler.RedeliverWebhook)
+
+	admin := app.Group("api/v1/admin")
+	admin.Post("wallets/:uuid/freeze", handler.FreezeWallet)
+	admin.Post("wallets/:uuid/unfreeze", handler.UnfreezeWallet)
+	admin.Post("wallets/:uuid/close", handler.CloseWallet)
+	admin.Put("wallets/:uuid/limit-policy", handler.SetWalletLimitPolicy)
+	admin.Put("wallets/:uuid/credit-limit", handler.SetWalletCreditLimit)
+	admin.Put("limit-policies/:name", handler.UpsertLimitPolicy)
+	admin.Get("operations/export", handler.ExportOperations)
+	admin.Post("operations/import", handler.ImportOperations)
+	admin.Post("reconciliations", handler.RunReconciliation)
+	admin.Get("reconciliations", handler.ListReconciliationRuns)
+	admin.Get("reconciliations/:id", handler.GetReconciliationRun)
+	admin.Get("trial-balance", handler.TrialBalance)
+	admin.Get("fee-rules", handler.ListFeeRules)
+	admin.Put("fee-rules/:operationType/:currency", handler.UpsertFeeRule)
+	admin.Delete("fee-rules/:operationType/:currency", handler.DeleteFeeRule)
 
 	return app
 }
@@ -2,8 +2,14 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/exchange"
 	"wallet-service/internal/model"
 	"wallet-service/internal/repository/postgres"
 
@@ -12,49 +18,621 @@ import (
 )
 
 type Service interface {
-	CreateWallet(ctx context.Context) (string, error)
-	Transaction(ctx context.Context, transactionRequest model.Transaction) error
-	GetBalanceByUuid(ctx context.Context, uuid string) (decimal.Decimal, error)
+	CreateWallet(ctx context.Context, wallet model.Wallet) (model.Wallet, error)
+	Transaction(ctx context.Context, transactionRequest model.Transaction) (model.Operation, error)
+	Batch(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) ([]model.BatchItemResult, error)
+	Transfer(ctx context.Context, transfer model.Transfer) (model.Transfer, error)
+	GetWalletByUuid(ctx context.Context, uuid string) (model.Wallet, error)
+	GetBalanceAsOf(ctx context.Context, uuid string, at time.Time) (model.HistoricalBalance, error)
+	ListWalletsByOwner(ctx context.Context, ownerId string) ([]model.Wallet, error)
+	UpsertLimitPolicy(ctx context.Context, policy model.LimitPolicy) (model.LimitPolicy, error)
+	SetWalletLimitPolicy(ctx context.Context, walletId, name string) (model.Wallet, error)
+	SetWalletCreditLimit(ctx context.Context, walletId string, limit decimal.Decimal) (model.Wallet, error)
+	ListOperations(ctx context.Context, filter model.OperationFilter) (model.OperationPage, error)
+	ExportOperations(ctx context.Context, filter model.OperationFilter, fn func([]model.Operation) error) error
+	GetStatement(ctx context.Context, walletId string, from, to time.Time) (model.Statement, error)
+	AuthorizeHold(ctx context.Context, hold model.Hold) (model.Hold, error)
+	CaptureHold(ctx context.Context, id string, amount decimal.Decimal) (model.Hold, error)
+	VoidHold(ctx context.Context, id string) (model.Hold, error)
+	ChangeWalletStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error)
+	CreateWebhookSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id string) error
+	ListWebhookDeliveries(ctx context.Context, subscriptionId, status string) ([]model.WebhookDelivery, error)
+	RedeliverWebhook(ctx context.Context, id string) (model.WebhookDelivery, error)
+	Reconcile(ctx context.Context, fix bool) (model.ReconciliationRun, error)
+	ListReconciliationRuns(ctx context.Context) ([]model.ReconciliationRun, error)
+	GetReconciliationRun(ctx context.Context, id int64) (model.ReconciliationRun, error)
+	TrialBalance(ctx context.Context) (model.TrialBalance, error)
+	UpsertFeeRule(ctx context.Context, rule model.FeeRule) (model.FeeRule, error)
+	ListFeeRules(ctx context.Context) ([]model.FeeRule, error)
+	DeleteFeeRule(ctx context.Context, operationType, currency string) error
+	QuoteFee(ctx context.Context, quote model.FeeQuote) (model.FeeQuote, error)
+	CreateSchedule(ctx context.Context, schedule model.Schedule) (model.Schedule, error)
+	GetSchedule(ctx context.Context, id string) (model.Schedule, error)
+	ListSchedules(ctx context.Context, walletId, status string) ([]model.Schedule, error)
+	CancelSchedule(ctx context.Context, id string) (model.Schedule, error)
 }
 
 type service struct {
-	repo   postgres.Repository
-	logger *slog.Logger
+	repo           postgres.Repository
+	rates          exchange.RateProvider
+	holdTTL        time.Duration
+	uniquePerOwner bool
+	maxBatchItems  int
+	logger         *slog.Logger
 }
 
-func NewService(repo postgres.Repository, logger *slog.Logger) Service {
-	return &service{
-		repo:   repo,
-		logger: logger,
+type Option func(*service)
+
+// WithRateProvider enables transfers between wallets in different currencies.
+func WithRateProvider(rates exchange.RateProvider) Option {
+	return func(s *service) {
+		s.rates = rates
+	}
+}
+
+// WithHoldTTL sets how long an authorized hold lives before it expires.
+func WithHoldTTL(ttl time.Duration) Option {
+	return func(s *service) {
+		s.holdTTL = ttl
+	}
+}
+
+// WithUniqueWalletPerOwner limits every owner to one open wallet per currency.
+func WithUniqueWalletPerOwner() Option {
+	return func(s *service) {
+		s.uniquePerOwner = true
+	}
+}
+
+// WithMaxBatchItems sets the largest batch Batch accepts.
+func WithMaxBatchItems(n int) Option {
+	return func(s *service) {
+		s.maxBatchItems = n
+	}
+}
+
+func NewService(repo postgres.Repository, logger *slog.Logger, opts ...Option) Service {
+	s := &service{
+		repo:          repo,
+		holdTTL:       model.DefaultHoldTTL,
+		maxBatchItems: model.DefaultMaxBatchItems,
+		logger:        logger,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
-func (s *service) CreateWallet(ctx context.Context) (string, error) {
-	uuid := uuid.New().String()
-	if err := s.repo.CreateWallet(ctx, uuid); err != nil {
+func (s *service) CreateWallet(ctx context.Context, wallet model.Wallet) (model.Wallet, error) {
+	wallet.Id = uuid.New().String()
+	wallet.Balance = decimal.Zero
+	wallet.Status = model.WalletActive
+	if err := s.repo.CreateWallet(ctx, wallet, s.uniquePerOwner); err != nil {
 		s.logger.Error("failed to create wallet", slog.Any("error", err))
-		return "", fmt.Errorf("create wallet: %w", err)
+		return model.Wallet{}, fmt.Errorf("create wallet: %w", err)
+	}
+	return wallet, nil
+}
+
+func (s *service) Transaction(ctx context.Context, transactionRequest model.Transaction) (model.Operation, error) {
+	transactionRequest, err := s.priceTransaction(ctx, transactionRequest)
+	if err != nil {
+		return model.Operation{}, err
+	}
+
+	return s.repo.Transaction(ctx, transactionRequest)
+}
+
+// Batch applies transactions together, all or nothing when atomic. Item
+// failures are reported in the results; the error is only set when the batch
+// could not run at all.
+func (s *service) Batch(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) ([]model.BatchItemResult, error) {
+	if len(transactions) > s.maxBatchItems {
+		return nil, apperror.Errorf(apperror.CodeValidation, "a batch can have at most %d items", s.maxBatchItems)
+	}
+
+	priced := make([]model.Transaction, len(transactions))
+	for i, t := range transactions {
+		var err error
+		if priced[i], err = s.priceTransaction(ctx, t); err != nil {
+			return nil, fmt.Errorf("batch: %w", err)
+		}
+	}
+
+	results, err := s.repo.Batch(ctx, priced, opts)
+	if err != nil {
+		return nil, fmt.Errorf("batch: %w", err)
+	}
+	return results, nil
+}
+
+// priceTransaction sets the fee of a withdrawal from the fee rule for the
+// currency of its wallet. A wallet that does not exist is left for the
+// repository to reject along with the rest of the transaction.
+func (s *service) priceTransaction(ctx context.Context, transaction model.Transaction) (model.Transaction, error) {
+	if transaction.OperationType != model.TransactionWithdraw {
+		return transaction, nil
+	}
+
+	wallet, err := s.repo.GetWalletByUuid(ctx, transaction.Uuid)
+	if errors.Is(err, apperror.ErrWalletNotFound) {
+		return transaction, nil
+	}
+	if err != nil {
+		return model.Transaction{}, err
+	}
+
+	fee, err := s.fee(ctx, transaction.OperationType, wallet.Currency, transaction.Amount)
+	if err != nil {
+		return model.Transaction{}, err
 	}
-	return uuid, nil
+	if fee.IsPositive() {
+		transaction.Fee = fee
+	}
+	return transaction, nil
 }
 
-func (s *service) Transaction(ctx context.Context, transactionRequest model.Transaction) error {
+// fee prices an operation of operationType moving amount in currency. An
+// operation without a fee rule for its type and currency is free.
+func (s *service) fee(ctx context.Context, operationType, currency string, amount decimal.Decimal) (decimal.Decimal, error) {
+	rule, err := s.repo.GetFeeRule(ctx, operationType, currency)
+	if errors.Is(err, apperror.ErrFeeRuleNotFound) {
+		return decimal.Zero, nil
+	}
+	if err != nil {
+		s.logger.Error("failed to get fee rule", slog.String("operation_type", operationType), slog.String("currency", currency), slog.Any("error", err))
+		return decimal.Zero, fmt.Errorf("get fee rule: %w", err)
+	}
+	return rule.Fee(model.RoundAmount(amount, currency)), nil
+}
 
-	err := s.repo.Transaction(ctx, transactionRequest.Uuid, transactionRequest.Amount, transactionRequest.OperationType)
+func (s *service) Transfer(ctx context.Context, transfer model.Transfer) (model.Transfer, error) {
+	transfer, err := s.priceTransfer(ctx, transfer)
 	if err != nil {
-		return err
+		return model.Transfer{}, err
+	}
+	fee, err := s.fee(ctx, model.FeeTransfer, transfer.FromCurrency, transfer.Amount)
+	if err != nil {
+		return model.Transfer{}, err
+	}
+	if fee.IsPositive() {
+		transfer.Fee = fee
 	}
 
-	return nil
+	result, err := s.repo.Transfer(ctx, transfer)
+	if err != nil {
+		return model.Transfer{}, err
+	}
 
+	return result, nil
 }
 
-func (s *service) GetBalanceByUuid(ctx context.Context, uuid string) (decimal.Decimal, error) {
+// priceTransfer fills in the currencies of both wallets and the amount the
+// destination receives, converting through the rate provider when they differ.
+func (s *service) priceTransfer(ctx context.Context, transfer model.Transfer) (model.Transfer, error) {
+	from, err := s.repo.GetWalletByUuid(ctx, transfer.FromUuid)
+	if err != nil {
+		return model.Transfer{}, err
+	}
+	to, err := s.repo.GetWalletByUuid(ctx, transfer.ToUuid)
+	if err != nil {
+		return model.Transfer{}, err
+	}
+
+	transfer.FromCurrency = from.Currency
+	transfer.ToCurrency = to.Currency
+	transfer.Amount = model.RoundAmount(transfer.Amount, from.Currency)
+	transfer.ConvertedAmount = transfer.Amount
+	transfer.Rate = decimal.NewFromInt(1)
+	transfer.RateTimestamp = nil
 
-	balance, err := s.repo.GetBalanceByUuid(ctx, uuid)
+	if from.Currency == to.Currency {
+		return transfer, nil
+	}
+	if s.rates == nil {
+		return model.Transfer{}, apperror.ErrCurrencyMismatch
+	}
+
+	rate, err := s.rates.Rate(ctx, from.Currency, to.Currency)
 	if err != nil {
-		return decimal.Zero, err
+		s.logger.Warn("failed to get exchange rate", slog.String("from", from.Currency), slog.String("to", to.Currency), slog.Any("error", err))
+		return model.Transfer{}, err
 	}
 
+	transfer.Rate = rate.Value
+	transfer.RateTimestamp = &rate.Timestamp
+	transfer.ConvertedAmount = model.RoundAmount(transfer.Amount.Mul(rate.Value), to.Currency)
+
+	return transfer, nil
+}
+
+func (s *service) GetWalletByUuid(ctx context.Context, uuid string) (model.Wallet, error) {
+
+	wallet, err := s.repo.GetWalletByUuid(ctx, uuid)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	return wallet, nil
+}
+
+// GetBalanceAsOf reconstructs what the wallet held at the given moment from
+// its recorded operations. Moments in the future are rejected, since their
+// balance is not known yet.
+func (s *service) GetBalanceAsOf(ctx context.Context, uuid string, at time.Time) (model.HistoricalBalance, error) {
+	if at.After(time.Now()) {
+		return model.HistoricalBalance{}, apperror.Errorf(apperror.CodeValidation, "asOf must not be in the future")
+	}
+
+	balance, err := s.repo.GetBalanceAsOf(ctx, uuid, at)
+	if err != nil {
+		return model.HistoricalBalance{}, err
+	}
 	return balance, nil
 }
+
+func (s *service) ListWalletsByOwner(ctx context.Context, ownerId string) ([]model.Wallet, error) {
+	wallets, err := s.repo.ListWalletsByOwner(ctx, ownerId)
+	if err != nil {
+		s.logger.Error("failed to list wallets", slog.Any("error", err))
+		return nil, fmt.Errorf("list wallets: %w", err)
+	}
+
+	return wallets, nil
+}
+
+func (s *service) ListOperations(ctx context.Context, filter model.OperationFilter) (model.OperationPage, error) {
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	operations, err := s.repo.ListOperations(ctx, filter)
+	if err != nil {
+		s.logger.Error("failed to list operations", slog.Any("error", err))
+		return model.OperationPage{}, fmt.Errorf("list operations: %w", err)
+	}
+
+	page := model.OperationPage{Operations: operations}
+	if len(operations) > limit {
+		page.Operations = operations[:limit]
+		last := page.Operations[limit-1]
+		page.NextCursor = model.OperationCursor{CreatedAt: last.CreatedAt, Id: last.Id}.Encode()
+	}
+
+	return page, nil
+}
+
+// exportPageSize is how many operations an export reads from the database at once.
+const exportPageSize = 500
+
+// ExportOperations passes every operation matching filter to fn, a page at a
+// time and newest first, and stops at the first error fn returns.
+func (s *service) ExportOperations(ctx context.Context, filter model.OperationFilter, fn func([]model.Operation) error) error {
+	filter.Limit = exportPageSize
+
+	for {
+		operations, err := s.repo.ListOperations(ctx, filter)
+		if err != nil {
+			s.logger.Error("failed to export operations", slog.Any("error", err))
+			return fmt.Errorf("export operations: %w", err)
+		}
+		if len(operations) > 0 {
+			if err := fn(operations); err != nil {
+				return err
+			}
+		}
+		if len(operations) < filter.Limit {
+			return nil
+		}
+
+		last := operations[len(operations)-1]
+		filter.Cursor = &model.OperationCursor{CreatedAt: last.CreatedAt, Id: last.Id}
+	}
+}
+
+func (s *service) GetStatement(ctx context.Context, walletId string, from, to time.Time) (model.Statement, error) {
+	statement, err := s.repo.GetStatement(ctx, walletId, from, to)
+	if err != nil {
+		return model.Statement{}, err
+	}
+	return statement, nil
+}
+
+func (s *service) AuthorizeHold(ctx context.Context, hold model.Hold) (model.Hold, error) {
+	hold.ExpiresAt = time.Now().Add(s.holdTTL)
+
+	result, err := s.repo.AuthorizeHold(ctx, hold)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	return result, nil
+}
+
+func (s *service) CaptureHold(ctx context.Context, id string, amount decimal.Decimal) (model.Hold, error) {
+	hold, err := s.repo.CaptureHold(ctx, id, amount)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+func (s *service) VoidHold(ctx context.Context, id string) (model.Hold, error) {
+	hold, err := s.repo.VoidHold(ctx, id)
+	if err != nil {
+		return model.Hold{}, err
+	}
+
+	return hold, nil
+}
+
+func (s *service) ChangeWalletStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error) {
+	result, err := s.repo.ChangeWalletStatus(ctx, change)
+	if err != nil {
+		return model.StatusChange{}, err
+	}
+
+	s.logger.Info("wallet status changed",
+		slog.String("wallet", result.WalletId),
+		slog.String("from", result.FromStatus),
+		slog.String("to", result.Status),
+		slog.String("actor", result.Actor),
+	)
+	return result, nil
+}
+
+func (s *service) UpsertLimitPolicy(ctx context.Context, policy model.LimitPolicy) (model.LimitPolicy, error) {
+	result, err := s.repo.UpsertLimitPolicy(ctx, policy)
+	if err != nil {
+		return model.LimitPolicy{}, err
+	}
+
+	return result, nil
+}
+
+func (s *service) SetWalletLimitPolicy(ctx context.Context, walletId, name string) (model.Wallet, error) {
+	wallet, err := s.repo.SetWalletLimitPolicy(ctx, walletId, name)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	return wallet, nil
+}
+
+func (s *service) SetWalletCreditLimit(ctx context.Context, walletId string, limit decimal.Decimal) (model.Wallet, error) {
+	wallet, err := s.repo.SetWalletCreditLimit(ctx, walletId, limit)
+	if err != nil {
+		return model.Wallet{}, err
+	}
+
+	s.logger.Info("wallet credit limit changed",
+		slog.String("wallet", wallet.Id),
+		slog.String("creditLimit", wallet.CreditLimit.String()),
+	)
+	return wallet, nil
+}
+
+// webhookSecretBytes is the entropy of a generated webhook secret.
+const webhookSecretBytes = 32
+
+// CreateWebhookSubscription stores the subscription, generating its secret
+// when none is given. The secret is only ever returned here.
+func (s *service) CreateWebhookSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+	if sub.Secret == "" {
+		secret := make([]byte, webhookSecretBytes)
+		if _, err := rand.Read(secret); err != nil {
+			return model.WebhookSubscription{}, fmt.Errorf("generate webhook secret: %w", err)
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+
+	result, err := s.repo.CreateWebhookSubscription(ctx, sub)
+	if err != nil {
+		return model.WebhookSubscription{}, err
+	}
+
+	return result, nil
+}
+
+func (s *service) ListWebhookSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	subs, err := s.repo.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+func (s *service) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	return s.repo.DeleteWebhookSubscription(ctx, id)
+}
+
+func (s *service) ListWebhookDeliveries(ctx context.Context, subscriptionId, status string) ([]model.WebhookDelivery, error) {
+	deliveries, err := s.repo.ListWebhookDeliveries(ctx, subscriptionId, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (s *service) RedeliverWebhook(ctx context.Context, id string) (model.WebhookDelivery, error) {
+	delivery, err := s.repo.RedeliverWebhook(ctx, id)
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+
+	s.logger.Info("webhook delivery requeued", slog.String("delivery", delivery.Id))
+	return delivery, nil
+}
+
+// Reconcile compares every wallet's stored balance with its ledger and logs
+// each mismatch. Balances are only overwritten when fix is set.
+func (s *service) Reconcile(ctx context.Context, fix bool) (model.ReconciliationRun, error) {
+	run, err := s.repo.Reconcile(ctx, fix)
+	if err != nil {
+		s.logger.Error("failed to reconcile balances", slog.Any("error", err))
+		return model.ReconciliationRun{}, fmt.Errorf("reconcile: %w", err)
+	}
+
+	for _, m := range run.Mismatches {
+		s.logger.Warn("balance mismatch",
+			slog.Int64("run_id", run.Id),
+			slog.String("wallet_id", m.WalletId),
+			slog.String("currency", m.Currency),
+			slog.String("stored_balance", m.StoredBalance.String()),
+			slog.String("ledger_balance", m.LedgerBalance.String()),
+			slog.String("difference", m.Difference.String()),
+			slog.Bool("fixed", m.Fixed),
+		)
+	}
+	s.logger.Info("reconciliation finished",
+		slog.Int64("run_id", run.Id),
+		slog.Int("wallets", run.WalletsChecked),
+		slog.Int("mismatches", run.MismatchCount),
+		slog.Bool("fix", fix),
+	)
+	return run, nil
+}
+
+func (s *service) ListReconciliationRuns(ctx context.Context) ([]model.ReconciliationRun, error) {
+	runs, err := s.repo.ListReconciliationRuns(ctx, model.DefaultReconciliationRuns)
+	if err != nil {
+		s.logger.Error("failed to list reconciliation runs", slog.Any("error", err))
+		return nil, fmt.Errorf("list reconciliation runs: %w", err)
+	}
+	return runs, nil
+}
+
+func (s *service) GetReconciliationRun(ctx context.Context, id int64) (model.ReconciliationRun, error) {
+	run, err := s.repo.GetReconciliationRun(ctx, id)
+	if err != nil {
+		return model.ReconciliationRun{}, err
+	}
+	return run, nil
+}
+
+// TrialBalance sums the ledger per currency and account. Books that do not
+// net to zero mean a posting slipped past the balance check, so it is logged.
+func (s *service) TrialBalance(ctx context.Context) (model.TrialBalance, error) {
+	balance, err := s.repo.TrialBalance(ctx)
+	if err != nil {
+		s.logger.Error("failed to compute trial balance", slog.Any("error", err))
+		return model.TrialBalance{}, fmt.Errorf("trial balance: %w", err)
+	}
+
+	for _, c := range balance.Currencies {
+		if !c.Total.IsZero() {
+			s.logger.Error("trial balance does not net to zero",
+				slog.String("currency", c.Currency),
+				slog.String("total", c.Total.String()),
+			)
+		}
+	}
+	return balance, nil
+}
+
+func (s *service) UpsertFeeRule(ctx context.Context, rule model.FeeRule) (model.FeeRule, error) {
+	result, err := s.repo.UpsertFeeRule(ctx, rule)
+	if err != nil {
+		return model.FeeRule{}, err
+	}
+
+	return result, nil
+}
+
+func (s *service) ListFeeRules(ctx context.Context) ([]model.FeeRule, error) {
+	rules, err := s.repo.ListFeeRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (s *service) DeleteFeeRule(ctx context.Context, operationType, currency string) error {
+	return s.repo.DeleteFeeRule(ctx, operationType, currency)
+}
+
+// QuoteFee prices an operation on the wallet without making it, with the same
+// rules Transaction and Transfer charge.
+func (s *service) QuoteFee(ctx context.Context, quote model.FeeQuote) (model.FeeQuote, error) {
+	wallet, err := s.repo.GetWalletByUuid(ctx, quote.WalletId)
+	if err != nil {
+		return model.FeeQuote{}, err
+	}
+
+	quote.Currency = wallet.Currency
+	quote.Amount = model.RoundAmount(quote.Amount, wallet.Currency)
+	quote.Fee, err = s.fee(ctx, quote.OperationType, wallet.Currency, quote.Amount)
+	if err != nil {
+		return model.FeeQuote{}, err
+	}
+	quote.Total = quote.Amount.Add(quote.Fee)
+
+	return quote, nil
+}
+
+// CreateSchedule stores a one-off or recurring operation on the wallet, in the
+// wallet's currency. A recurring schedule first runs the next time its cron
+// fires.
+func (s *service) CreateSchedule(ctx context.Context, schedule model.Schedule) (model.Schedule, error) {
+	wallet, err := s.repo.GetWalletByUuid(ctx, schedule.WalletId)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+	if wallet.Status == model.WalletClosed {
+		return model.Schedule{}, apperror.ErrWalletClosed
+	}
+	if schedule.Currency != "" && schedule.Currency != wallet.Currency {
+		return model.Schedule{}, apperror.ErrCurrencyMismatch
+	}
+
+	schedule.Currency = wallet.Currency
+	schedule.Amount = model.RoundAmount(schedule.Amount, wallet.Currency)
+	if !schedule.Amount.IsPositive() {
+		return model.Schedule{}, apperror.Errorf(apperror.CodeValidation, "amount is smaller than the minor unit of %s", wallet.Currency)
+	}
+	if schedule.Cron != "" {
+		schedule.NextRunAt = schedule.Next(time.Now())
+	}
+
+	result, err := s.repo.CreateSchedule(ctx, schedule)
+	if err != nil {
+		s.logger.Error("failed to create schedule", slog.String("wallet", schedule.WalletId), slog.Any("error", err))
+		return model.Schedule{}, err
+	}
+
+	return result, nil
+}
+
+func (s *service) GetSchedule(ctx context.Context, id string) (model.Schedule, error) {
+	schedule, err := s.repo.GetSchedule(ctx, id)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+
+	return schedule, nil
+}
+
+func (s *service) ListSchedules(ctx context.Context, walletId, status string) ([]model.Schedule, error) {
+	schedules, err := s.repo.ListSchedules(ctx, walletId, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (s *service) CancelSchedule(ctx context.Context, id string) (model.Schedule, error) {
+	schedule, err := s.repo.CancelSchedule(ctx, id)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+
+	s.logger.Info("schedule cancelled", slog.String("schedule", schedule.Id), slog.String("wallet", schedule.WalletId))
+	return schedule, nil
+}
@@ -1,10 +1,15 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"testing"
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/exchange"
 	"wallet-service/internal/model"
 
 	"log/slog"
@@ -15,29 +20,250 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-type Repository interface {
-	CreateWallet(ctx context.Context, uuid string) error
-	Transaction(ctx context.Context, uuid string, amount decimal.Decimal, operationType string) error
-	GetBalanceByUuid(ctx context.Context, uuid string) (decimal.Decimal, error)
-}
-
 type mockRepository struct {
 	mock.Mock
 }
 
-func (m *mockRepository) CreateWallet(ctx context.Context, uuid string) error {
+func (m *mockRepository) CreateWallet(ctx context.Context, wallet model.Wallet, uniquePerOwner bool) error {
+	args := m.Called(ctx, wallet, uniquePerOwner)
+	return args.Error(0)
+}
+
+func (m *mockRepository) ListWalletsByOwner(ctx context.Context, ownerId string) ([]model.Wallet, error) {
+	args := m.Called(ctx, ownerId)
+	return args.Get(0).([]model.Wallet), args.Error(1)
+}
+
+func (m *mockRepository) Transaction(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+	args := m.Called(ctx, transaction)
+	return args.Get(0).(model.Operation), args.Error(1)
+}
+
+func (m *mockRepository) GetWalletByUuid(ctx context.Context, uuid string) (model.Wallet, error) {
+	args := m.Called(ctx, uuid)
+	return args.Get(0).(model.Wallet), args.Error(1)
+}
+
+func (m *mockRepository) GetOperationsByWalletUuid(ctx context.Context, uuid string) ([]model.Operation, error) {
 	args := m.Called(ctx, uuid)
+	return args.Get(0).([]model.Operation), args.Error(1)
+}
+
+func (m *mockRepository) ListOperations(ctx context.Context, filter model.OperationFilter) ([]model.Operation, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]model.Operation), args.Error(1)
+}
+
+func (m *mockRepository) Reconcile(ctx context.Context, fix bool) (model.ReconciliationRun, error) {
+	args := m.Called(ctx, fix)
+	return args.Get(0).(model.ReconciliationRun), args.Error(1)
+}
+
+func (m *mockRepository) ListReconciliationRuns(ctx context.Context, limit int) ([]model.ReconciliationRun, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]model.ReconciliationRun), args.Error(1)
+}
+
+func (m *mockRepository) GetReconciliationRun(ctx context.Context, id int64) (model.ReconciliationRun, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(model.ReconciliationRun), args.Error(1)
+}
+
+func (m *mockRepository) TrialBalance(ctx context.Context) (model.TrialBalance, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(model.TrialBalance), args.Error(1)
+}
+
+func (m *mockRepository) UpsertFeeRule(ctx context.Context, rule model.FeeRule) (model.FeeRule, error) {
+	args := m.Called(ctx, rule)
+	return args.Get(0).(model.FeeRule), args.Error(1)
+}
+
+func (m *mockRepository) ListFeeRules(ctx context.Context) ([]model.FeeRule, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]model.FeeRule), args.Error(1)
+}
+
+func (m *mockRepository) GetFeeRule(ctx context.Context, operationType, currency string) (model.FeeRule, error) {
+	args := m.Called(ctx, operationType, currency)
+	return args.Get(0).(model.FeeRule), args.Error(1)
+}
+
+func (m *mockRepository) DeleteFeeRule(ctx context.Context, operationType, currency string) error {
+	args := m.Called(ctx, operationType, currency)
+	return args.Error(0)
+}
+
+func (m *mockRepository) CreateSchedule(ctx context.Context, schedule model.Schedule) (model.Schedule, error) {
+	args := m.Called(ctx, schedule)
+	return args.Get(0).(model.Schedule), args.Error(1)
+}
+
+func (m *mockRepository) GetSchedule(ctx context.Context, id string) (model.Schedule, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(model.Schedule), args.Error(1)
+}
+
+func (m *mockRepository) ListSchedules(ctx context.Context, walletId, status string) ([]model.Schedule, error) {
+	args := m.Called(ctx, walletId, status)
+	return args.Get(0).([]model.Schedule), args.Error(1)
+}
+
+func (m *mockRepository) CancelSchedule(ctx context.Context, id string) (model.Schedule, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(model.Schedule), args.Error(1)
+}
+
+func (m *mockRepository) ClaimDueSchedules(ctx context.Context, limit int, lease time.Duration) ([]model.Schedule, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]model.Schedule), args.Error(1)
+}
+
+func (m *mockRepository) SkipScheduleRuns(ctx context.Context, id string, to time.Time) error {
+	args := m.Called(ctx, id, to)
 	return args.Error(0)
 }
 
-func (m *mockRepository) Transaction(ctx context.Context, uuid string, amount decimal.Decimal, operationType string) error {
-	args := m.Called(ctx, uuid, amount, operationType)
+func (m *mockRepository) CompleteScheduleRun(ctx context.Context, id, operationId string, next *time.Time) error {
+	args := m.Called(ctx, id, operationId, next)
 	return args.Error(0)
 }
 
-func (m *mockRepository) GetBalanceByUuid(ctx context.Context, uuid string) (decimal.Decimal, error) {
-	args := m.Called(ctx, uuid)
-	return args.Get(0).(decimal.Decimal), args.Error(1)
+func (m *mockRepository) RetryScheduleRun(ctx context.Context, id string, at time.Time, cause string) error {
+	args := m.Called(ctx, id, at, cause)
+	return args.Error(0)
+}
+
+func (m *mockRepository) FailScheduleRun(ctx context.Context, id string, cause string, next *time.Time) error {
+	args := m.Called(ctx, id, cause, next)
+	return args.Error(0)
+}
+
+func (m *mockRepository) GetBalanceAsOf(ctx context.Context, walletId string, at time.Time) (model.HistoricalBalance, error) {
+	args := m.Called(ctx, walletId, at)
+	return args.Get(0).(model.HistoricalBalance), args.Error(1)
+}
+
+func (m *mockRepository) GetStatement(ctx context.Context, walletId string, from, to time.Time) (model.Statement, error) {
+	args := m.Called(ctx, walletId, from, to)
+	return args.Get(0).(model.Statement), args.Error(1)
+}
+
+func (m *mockRepository) Transfer(ctx context.Context, transfer model.Transfer) (model.Transfer, error) {
+	args := m.Called(ctx, transfer)
+	return args.Get(0).(model.Transfer), args.Error(1)
+}
+
+func (m *mockRepository) AuthorizeHold(ctx context.Context, hold model.Hold) (model.Hold, error) {
+	args := m.Called(ctx, hold)
+	return args.Get(0).(model.Hold), args.Error(1)
+}
+
+func (m *mockRepository) CaptureHold(ctx context.Context, id string, amount decimal.Decimal) (model.Hold, error) {
+	args := m.Called(ctx, id, amount)
+	return args.Get(0).(model.Hold), args.Error(1)
+}
+
+func (m *mockRepository) VoidHold(ctx context.Context, id string) (model.Hold, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(model.Hold), args.Error(1)
+}
+
+func (m *mockRepository) UpsertLimitPolicy(ctx context.Context, policy model.LimitPolicy) (model.LimitPolicy, error) {
+	args := m.Called(ctx, policy)
+	return args.Get(0).(model.LimitPolicy), args.Error(1)
+}
+
+func (m *mockRepository) SetWalletLimitPolicy(ctx context.Context, walletId, name string) (model.Wallet, error) {
+	args := m.Called(ctx, walletId, name)
+	return args.Get(0).(model.Wallet), args.Error(1)
+}
+
+func (m *mockRepository) SetWalletCreditLimit(ctx context.Context, walletId string, limit decimal.Decimal) (model.Wallet, error) {
+	args := m.Called(ctx, walletId, limit)
+	return args.Get(0).(model.Wallet), args.Error(1)
+}
+
+func (m *mockRepository) ChangeWalletStatus(ctx context.Context, change model.StatusChange) (model.StatusChange, error) {
+	args := m.Called(ctx, change)
+	return args.Get(0).(model.StatusChange), args.Error(1)
+}
+
+func (m *mockRepository) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]model.Event, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]model.Event), args.Error(1)
+}
+
+func (m *mockRepository) MarkEventPublished(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockRepository) RetryEvent(ctx context.Context, id int64, at time.Time, cause string) error {
+	args := m.Called(ctx, id, at, cause)
+	return args.Error(0)
+}
+
+func (m *mockRepository) CreateWebhookSubscription(ctx context.Context, sub model.WebhookSubscription) (model.WebhookSubscription, error) {
+	args := m.Called(ctx, sub)
+	return args.Get(0).(model.WebhookSubscription), args.Error(1)
+}
+
+func (m *mockRepository) ListWebhookSubscriptions(ctx context.Context) ([]model.WebhookSubscription, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]model.WebhookSubscription), args.Error(1)
+}
+
+func (m *mockRepository) DeleteWebhookSubscription(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockRepository) ListWebhookDeliveries(ctx context.Context, subscriptionId, status string) ([]model.WebhookDelivery, error) {
+	args := m.Called(ctx, subscriptionId, status)
+	return args.Get(0).([]model.WebhookDelivery), args.Error(1)
+}
+
+func (m *mockRepository) RedeliverWebhook(ctx context.Context, id string) (model.WebhookDelivery, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(model.WebhookDelivery), args.Error(1)
+}
+
+func (m *mockRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]model.WebhookDelivery), args.Error(1)
+}
+
+func (m *mockRepository) MarkWebhookDelivered(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockRepository) RetryWebhookDelivery(ctx context.Context, id string, at time.Time, cause string) error {
+	args := m.Called(ctx, id, at, cause)
+	return args.Error(0)
+}
+
+func (m *mockRepository) DeadLetterWebhookDelivery(ctx context.Context, id string, cause string) error {
+	args := m.Called(ctx, id, cause)
+	return args.Error(0)
+}
+
+func (m *mockRepository) Batch(ctx context.Context, transactions []model.Transaction, opts model.BatchOptions) ([]model.BatchItemResult, error) {
+	args := m.Called(ctx, transactions, opts)
+	return args.Get(0).([]model.BatchItemResult), args.Error(1)
+}
+
+func (m *mockRepository) ExpireHolds(ctx context.Context, now time.Time, limit int) (int, error) {
+	args := m.Called(ctx, now, limit)
+	return args.Int(0), args.Error(1)
+}
+
+// expectFreeWithdrawal stubs the lookups that price a withdrawal from a USD
+// wallet without a fee rule.
+func expectFreeWithdrawal(m *mockRepository, ctx context.Context, walletId string) {
+	m.On("GetWalletByUuid", ctx, walletId).Return(model.Wallet{Id: walletId, Currency: "USD"}, nil)
+	m.On("GetFeeRule", ctx, model.TransactionWithdraw, "USD").Return(model.FeeRule{}, apperror.ErrFeeRuleNotFound)
 }
 
 func TestCreateWallet(t *testing.T) {
@@ -47,17 +273,18 @@ func TestCreateWallet(t *testing.T) {
 		service := NewService(mockRepo, logger)
 		ctx := context.Background()
 
-		var calledUUID string
-		mockRepo.On("CreateWallet", ctx, mock.AnythingOfType("string")).Run(func(args mock.Arguments) {
-			calledUUID = args.String(1)
+		var called model.Wallet
+		mockRepo.On("CreateWallet", ctx, mock.AnythingOfType("model.Wallet"), false).Run(func(args mock.Arguments) {
+			called = args.Get(1).(model.Wallet)
 		}).Return(nil)
 
-		uuidStr, err := service.CreateWallet(ctx)
+		wallet, err := service.CreateWallet(ctx, model.Wallet{Currency: "EUR"})
 		require.NoError(t, err)
-		require.NotEmpty(t, uuidStr)
-		require.Equal(t, calledUUID, uuidStr)
+		require.NotEmpty(t, wallet.Id)
+		require.Equal(t, called, wallet)
+		require.Equal(t, "EUR", wallet.Currency)
 
-		_, err = uuid.Parse(uuidStr)
+		_, err = uuid.Parse(wallet.Id)
 		require.NoError(t, err)
 	})
 
@@ -68,15 +295,27 @@ func TestCreateWallet(t *testing.T) {
 		ctx := context.Background()
 
 		expectedErr := errors.New("database error")
-		mockRepo.On("CreateWallet", ctx, mock.AnythingOfType("string")).Return(expectedErr)
+		mockRepo.On("CreateWallet", ctx, mock.AnythingOfType("model.Wallet"), false).Return(expectedErr)
 
-		uuidStr, err := service.CreateWallet(ctx)
+		wallet, err := service.CreateWallet(ctx, model.Wallet{Currency: "USD"})
 		require.Error(t, err)
-		require.Empty(t, uuidStr)
+		require.Empty(t, wallet.Id)
 		require.Contains(t, err.Error(), "create wallet: database error")
 	})
 }
 
+func TestCreateWalletUniquePerOwner(t *testing.T) {
+	mockRepo := new(mockRepository)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := NewService(mockRepo, logger, WithUniqueWalletPerOwner())
+	ctx := context.Background()
+
+	mockRepo.On("CreateWallet", ctx, mock.AnythingOfType("model.Wallet"), true).Return(apperror.ErrWalletExists)
+
+	_, err := service.CreateWallet(ctx, model.Wallet{Currency: "USD", OwnerId: "user-1"})
+	require.ErrorIs(t, err, apperror.ErrWalletExists)
+}
+
 func TestTransaction(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(mockRepository)
@@ -90,10 +329,12 @@ func TestTransaction(t *testing.T) {
 			OperationType: "credit",
 		}
 
-		mockRepo.On("Transaction", ctx, transactionRequest.Uuid, transactionRequest.Amount, transactionRequest.OperationType).Return(nil)
+		expectedOp := model.Operation{Id: "op-1", WalletId: transactionRequest.Uuid}
+		mockRepo.On("Transaction", ctx, transactionRequest).Return(expectedOp, nil)
 
-		err := service.Transaction(ctx, transactionRequest)
+		op, err := service.Transaction(ctx, transactionRequest)
 		require.NoError(t, err)
+		require.Equal(t, expectedOp, op)
 	})
 
 	t.Run("error", func(t *testing.T) {
@@ -109,15 +350,298 @@ func TestTransaction(t *testing.T) {
 		}
 
 		expectedErr := errors.New("insufficient funds")
-		mockRepo.On("Transaction", ctx, transactionRequest.Uuid, transactionRequest.Amount, transactionRequest.OperationType).Return(expectedErr)
+		mockRepo.On("Transaction", ctx, transactionRequest).Return(model.Operation{}, expectedErr)
 
-		err := service.Transaction(ctx, transactionRequest)
+		_, err := service.Transaction(ctx, transactionRequest)
 		require.Error(t, err)
 		require.Equal(t, expectedErr, err)
 	})
 }
 
-func TestGetBalanceByUuid(t *testing.T) {
+func TestTransactionFees(t *testing.T) {
+	withdraw := model.Transaction{Uuid: "wallet-a", Amount: decimal.NewFromInt(30), OperationType: model.TransactionWithdraw}
+
+	t.Run("withdrawal is charged by the rule of its currency", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		rule := model.FeeRule{OperationType: model.TransactionWithdraw, Currency: "EUR", Flat: decimal.RequireFromString("0.5"), Percent: decimal.NewFromInt(1)}
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-a").Return(model.Wallet{Id: "wallet-a", Currency: "EUR"}, nil)
+		mockRepo.On("GetFeeRule", ctx, model.TransactionWithdraw, "EUR").Return(rule, nil)
+
+		var charged model.Transaction
+		mockRepo.On("Transaction", ctx, mock.AnythingOfType("model.Transaction")).Run(func(args mock.Arguments) {
+			charged = args.Get(1).(model.Transaction)
+		}).Return(model.Operation{Id: "op-1", OperationType: model.TransactionWithdraw}, nil)
+
+		_, err := service.Transaction(ctx, withdraw)
+		require.NoError(t, err)
+		require.Equal(t, "0.8", charged.Fee.String())
+	})
+
+	t.Run("bounds and rounds the fee", func(t *testing.T) {
+		rule := model.FeeRule{
+			Currency: "USD",
+			Percent:  decimal.NewFromInt(2),
+			Min:      decimal.NewNullDecimal(decimal.NewFromInt(1)),
+			Max:      decimal.NewNullDecimal(decimal.NewFromInt(5)),
+		}
+		require.Equal(t, "1", rule.Fee(decimal.NewFromInt(10)).String())
+		require.Equal(t, "3", rule.Fee(decimal.NewFromInt(150)).String())
+		require.Equal(t, "5", rule.Fee(decimal.NewFromInt(1000)).String())
+
+		yen := model.FeeRule{Currency: "JPY", Percent: decimal.RequireFromString("1.5")}
+		require.Equal(t, "15", yen.Fee(decimal.NewFromInt(1001)).String())
+	})
+
+	t.Run("rule lookup failure fails the operation", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-a").Return(model.Wallet{Id: "wallet-a", Currency: "USD"}, nil)
+		mockRepo.On("GetFeeRule", ctx, model.TransactionWithdraw, "USD").Return(model.FeeRule{}, errors.New("database error"))
+
+		_, err := service.Transaction(ctx, withdraw)
+		require.Error(t, err)
+		mockRepo.AssertNotCalled(t, "Transaction", mock.Anything, mock.Anything)
+	})
+}
+
+func TestQuoteFee(t *testing.T) {
+	t.Run("quotes the fee and total", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		rule := model.FeeRule{OperationType: model.FeeTransfer, Currency: "USD", Percent: decimal.RequireFromString("2.5"), Max: decimal.NewNullDecimal(decimal.NewFromInt(5))}
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-a").Return(model.Wallet{Id: "wallet-a", Currency: "USD"}, nil)
+		mockRepo.On("GetFeeRule", ctx, model.FeeTransfer, "USD").Return(rule, nil)
+
+		quote, err := service.QuoteFee(ctx, model.FeeQuote{WalletId: "wallet-a", OperationType: model.FeeTransfer, Amount: decimal.NewFromInt(300)})
+		require.NoError(t, err)
+		require.Equal(t, "USD", quote.Currency)
+		require.Equal(t, "5", quote.Fee.String())
+		require.Equal(t, "305", quote.Total.String())
+	})
+
+	t.Run("free without a rule", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		expectFreeWithdrawal(mockRepo, ctx, "wallet-a")
+
+		quote, err := service.QuoteFee(ctx, model.FeeQuote{WalletId: "wallet-a", OperationType: model.TransactionWithdraw, Amount: decimal.NewFromInt(300)})
+		require.NoError(t, err)
+		require.True(t, quote.Fee.IsZero())
+		require.Equal(t, "300", quote.Total.String())
+	})
+}
+
+func TestCreateWebhookSubscription(t *testing.T) {
+	mockRepo := new(mockRepository)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := NewService(mockRepo, logger)
+	ctx := context.Background()
+
+	var called model.WebhookSubscription
+	mockRepo.On("CreateWebhookSubscription", ctx, mock.AnythingOfType("model.WebhookSubscription")).Run(func(args mock.Arguments) {
+		called = args.Get(1).(model.WebhookSubscription)
+	}).Return(model.WebhookSubscription{Id: "sub-1"}, nil)
+
+	_, err := service.CreateWebhookSubscription(ctx, model.WebhookSubscription{Url: "https://example.com/hook", EventTypes: []string{model.WebhookDeposit}})
+	require.NoError(t, err)
+	require.Len(t, called.Secret, 2*webhookSecretBytes)
+}
+
+func TestBatch(t *testing.T) {
+	t.Run("too many items", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger, WithMaxBatchItems(2))
+
+		_, err := service.Batch(context.Background(), make([]model.Transaction, 3), model.BatchOptions{Atomic: true})
+		require.ErrorIs(t, err, apperror.ErrValidation)
+		mockRepo.AssertNotCalled(t, "Batch")
+	})
+
+	t.Run("reports item results", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		transactions := []model.Transaction{
+			{Uuid: "wallet-a", OperationType: model.TransactionDeposit, Amount: decimal.NewFromInt(10)},
+			{Uuid: "wallet-b", OperationType: model.TransactionWithdraw, Amount: decimal.NewFromInt(10)},
+		}
+		applied := model.Operation{Id: "op-1", WalletId: "wallet-a", OperationType: model.TransactionDeposit, Amount: decimal.NewFromInt(10), BalanceAfter: decimal.NewFromInt(10)}
+		results := []model.BatchItemResult{{Operation: applied}, {Err: apperror.ErrInsufficientFunds}}
+		expectFreeWithdrawal(mockRepo, ctx, "wallet-b")
+		mockRepo.On("Batch", ctx, transactions, model.BatchOptions{}).Return(results, nil)
+
+		got, err := service.Batch(ctx, transactions, model.BatchOptions{})
+		require.NoError(t, err)
+		require.Equal(t, results, got)
+		mockRepo.AssertExpectations(t)
+	})
+	t.Run("dry run", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		transactions := []model.Transaction{{Uuid: "wallet-a", OperationType: model.TransactionDeposit, Amount: decimal.NewFromInt(10)}}
+		opts := model.BatchOptions{Atomic: true, DryRun: true}
+		results := []model.BatchItemResult{{Operation: model.Operation{Id: "op-1", OperationType: model.TransactionDeposit}}}
+		mockRepo.On("Batch", ctx, transactions, opts).Return(results, nil)
+
+		got, err := service.Batch(ctx, transactions, opts)
+		require.NoError(t, err)
+		require.Equal(t, results, got)
+	})
+}
+
+func TestExportOperations(t *testing.T) {
+	mockRepo := new(mockRepository)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := NewService(mockRepo, logger)
+	ctx := context.Background()
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	full := make([]model.Operation, exportPageSize)
+	for i := range full {
+		full[i] = model.Operation{Id: fmt.Sprintf("op-%d", i), CreatedAt: createdAt}
+	}
+	last := []model.Operation{{Id: "op-last", CreatedAt: createdAt}}
+
+	mockRepo.On("ListOperations", ctx, model.OperationFilter{WalletId: "wallet-a", Limit: exportPageSize}).Return(full, nil).Once()
+	cursor := &model.OperationCursor{CreatedAt: createdAt, Id: full[exportPageSize-1].Id}
+	mockRepo.On("ListOperations", ctx, model.OperationFilter{WalletId: "wallet-a", Cursor: cursor, Limit: exportPageSize}).Return(last, nil).Once()
+
+	var exported int
+	err := service.ExportOperations(ctx, model.OperationFilter{WalletId: "wallet-a"}, func(operations []model.Operation) error {
+		exported += len(operations)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, exportPageSize+1, exported)
+	mockRepo.AssertExpectations(t)
+}
+
+type stubRateProvider struct {
+	rate exchange.Rate
+	err  error
+}
+
+func (p stubRateProvider) Rate(ctx context.Context, from, to string) (exchange.Rate, error) {
+	return p.rate, p.err
+}
+
+func TestTransfer(t *testing.T) {
+	transfer := model.Transfer{FromUuid: "wallet-a", ToUuid: "wallet-b", Amount: decimal.NewFromInt(30)}
+	priced := transfer
+	priced.Amount = model.RoundAmount(transfer.Amount, "USD")
+	priced.FromCurrency = "USD"
+	priced.ConvertedAmount = priced.Amount
+	priced.ToCurrency = "USD"
+	priced.Rate = decimal.NewFromInt(1)
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-a").Return(model.Wallet{Id: "wallet-a", Currency: "USD"}, nil)
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-b").Return(model.Wallet{Id: "wallet-b", Currency: "USD"}, nil)
+		mockRepo.On("GetFeeRule", ctx, model.FeeTransfer, "USD").Return(model.FeeRule{}, apperror.ErrFeeRuleNotFound)
+		expected := priced
+		expected.Id = "transfer-1"
+		mockRepo.On("Transfer", ctx, priced).Return(expected, nil)
+
+		result, err := service.Transfer(ctx, transfer)
+		require.NoError(t, err)
+		require.Equal(t, expected, result)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-a").Return(model.Wallet{Id: "wallet-a", Currency: "USD"}, nil)
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-b").Return(model.Wallet{Id: "wallet-b", Currency: "USD"}, nil)
+		mockRepo.On("GetFeeRule", ctx, model.FeeTransfer, "USD").Return(model.FeeRule{}, apperror.ErrFeeRuleNotFound)
+		expectedErr := errors.New("insufficient funds")
+		mockRepo.On("Transfer", ctx, priced).Return(model.Transfer{}, expectedErr)
+
+		_, err := service.Transfer(ctx, transfer)
+		require.Equal(t, expectedErr, err)
+	})
+
+	t.Run("converts with rate provider", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		rateAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		rates := stubRateProvider{rate: exchange.Rate{From: "USD", To: "JPY", Value: decimal.RequireFromString("151.237"), Timestamp: rateAt}}
+		service := NewService(mockRepo, logger, WithRateProvider(rates))
+		ctx := context.Background()
+
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-a").Return(model.Wallet{Id: "wallet-a", Currency: "USD"}, nil)
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-b").Return(model.Wallet{Id: "wallet-b", Currency: "JPY"}, nil)
+		mockRepo.On("GetFeeRule", ctx, model.FeeTransfer, "USD").Return(model.FeeRule{}, apperror.ErrFeeRuleNotFound)
+
+		var called model.Transfer
+		mockRepo.On("Transfer", ctx, mock.AnythingOfType("model.Transfer")).Run(func(args mock.Arguments) {
+			called = args.Get(1).(model.Transfer)
+		}).Return(model.Transfer{Id: "transfer-1"}, nil)
+
+		_, err := service.Transfer(ctx, transfer)
+		require.NoError(t, err)
+		require.Equal(t, "JPY", called.ToCurrency)
+		require.Equal(t, "4537", called.ConvertedAmount.String())
+		require.Equal(t, "151.237", called.Rate.String())
+		require.Equal(t, &rateAt, called.RateTimestamp)
+	})
+
+	t.Run("different currencies without rate provider", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-a").Return(model.Wallet{Id: "wallet-a", Currency: "USD"}, nil)
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-b").Return(model.Wallet{Id: "wallet-b", Currency: "EUR"}, nil)
+
+		_, err := service.Transfer(ctx, transfer)
+		require.ErrorIs(t, err, apperror.ErrCurrencyMismatch)
+		mockRepo.AssertNotCalled(t, "Transfer", mock.Anything, mock.Anything)
+	})
+
+	t.Run("rate unavailable", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger, WithRateProvider(stubRateProvider{err: apperror.ErrRateUnavailable}))
+		ctx := context.Background()
+
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-a").Return(model.Wallet{Id: "wallet-a", Currency: "USD"}, nil)
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-b").Return(model.Wallet{Id: "wallet-b", Currency: "EUR"}, nil)
+
+		_, err := service.Transfer(ctx, transfer)
+		require.ErrorIs(t, err, apperror.ErrRateUnavailable)
+		mockRepo.AssertNotCalled(t, "Transfer", mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetWalletByUuid(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(mockRepository)
 		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
@@ -125,12 +649,12 @@ func TestGetBalanceByUuid(t *testing.T) {
 		ctx := context.Background()
 
 		uuidStr := "some-uuid"
-		expectedBalance := decimal.NewFromFloat(100.0)
-		mockRepo.On("GetBalanceByUuid", ctx, uuidStr).Return(expectedBalance, nil)
+		expectedWallet := model.Wallet{Id: uuidStr, Balance: decimal.NewFromFloat(100.0), Currency: "USD"}
+		mockRepo.On("GetWalletByUuid", ctx, uuidStr).Return(expectedWallet, nil)
 
-		balance, err := service.GetBalanceByUuid(ctx, uuidStr)
+		wallet, err := service.GetWalletByUuid(ctx, uuidStr)
 		require.NoError(t, err)
-		require.Equal(t, expectedBalance, balance)
+		require.Equal(t, expectedWallet, wallet)
 	})
 
 	t.Run("error", func(t *testing.T) {
@@ -141,11 +665,237 @@ func TestGetBalanceByUuid(t *testing.T) {
 
 		uuidStr := "some-uuid"
 		expectedErr := errors.New("wallet not found")
-		mockRepo.On("GetBalanceByUuid", ctx, uuidStr).Return(decimal.Zero, expectedErr)
+		mockRepo.On("GetWalletByUuid", ctx, uuidStr).Return(model.Wallet{}, expectedErr)
 
-		balance, err := service.GetBalanceByUuid(ctx, uuidStr)
+		wallet, err := service.GetWalletByUuid(ctx, uuidStr)
 		require.Error(t, err)
 		require.Equal(t, expectedErr, err)
-		require.True(t, balance.Equal(decimal.Zero))
+		require.True(t, wallet.Balance.Equal(decimal.Zero))
+	})
+}
+
+func TestGetBalanceAsOf(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		expected := model.HistoricalBalance{WalletId: "some-uuid", Currency: "USD", Balance: decimal.NewFromInt(70), AsOf: at}
+		mockRepo.On("GetBalanceAsOf", ctx, "some-uuid", at).Return(expected, nil)
+
+		balance, err := service.GetBalanceAsOf(ctx, "some-uuid", at)
+		require.NoError(t, err)
+		require.Equal(t, expected, balance)
+	})
+
+	t.Run("future", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+
+		_, err := service.GetBalanceAsOf(context.Background(), "some-uuid", time.Now().Add(time.Hour))
+		require.ErrorIs(t, err, apperror.ErrValidation)
+		mockRepo.AssertNotCalled(t, "GetBalanceAsOf")
+	})
+}
+
+func TestListOperations(t *testing.T) {
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	operations := []model.Operation{
+		{Id: "op-3", CreatedAt: createdAt.Add(2 * time.Minute)},
+		{Id: "op-2", CreatedAt: createdAt.Add(time.Minute)},
+		{Id: "op-1", CreatedAt: createdAt},
+	}
+
+	t.Run("has next page", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		filter := model.OperationFilter{WalletId: "some-uuid", Limit: 2}
+		mockRepo.On("ListOperations", ctx, model.OperationFilter{WalletId: "some-uuid", Limit: 3}).Return(operations, nil)
+
+		page, err := service.ListOperations(ctx, filter)
+		require.NoError(t, err)
+		require.Equal(t, operations[:2], page.Operations)
+
+		cursor, err := model.DecodeOperationCursor(page.NextCursor)
+		require.NoError(t, err)
+		require.Equal(t, "op-2", cursor.Id)
+		require.True(t, cursor.CreatedAt.Equal(operations[1].CreatedAt))
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		filter := model.OperationFilter{WalletId: "some-uuid", Limit: 3}
+		mockRepo.On("ListOperations", ctx, model.OperationFilter{WalletId: "some-uuid", Limit: 4}).Return(operations, nil)
+
+		page, err := service.ListOperations(ctx, filter)
+		require.NoError(t, err)
+		require.Equal(t, operations, page.Operations)
+		require.Empty(t, page.NextCursor)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		mockRepo.On("ListOperations", ctx, mock.Anything).Return([]model.Operation(nil), errors.New("database error"))
+
+		_, err := service.ListOperations(ctx, model.OperationFilter{WalletId: "some-uuid", Limit: 2})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "list operations: database error")
+	})
+}
+
+func TestAuthorizeHold(t *testing.T) {
+	t.Run("sets expiry from ttl", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger, WithHoldTTL(time.Hour))
+		ctx := context.Background()
+
+		var called model.Hold
+		mockRepo.On("AuthorizeHold", ctx, mock.AnythingOfType("model.Hold")).Run(func(args mock.Arguments) {
+			called = args.Get(1).(model.Hold)
+		}).Return(model.Hold{Id: "hold-1"}, nil)
+
+		before := time.Now()
+		hold, err := service.AuthorizeHold(ctx, model.Hold{WalletId: "wallet-a", Amount: decimal.NewFromInt(40)})
+		require.NoError(t, err)
+		require.Equal(t, "hold-1", hold.Id)
+		require.WithinDuration(t, before.Add(time.Hour), called.ExpiresAt, time.Second)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		mockRepo.On("AuthorizeHold", ctx, mock.AnythingOfType("model.Hold")).Return(model.Hold{}, apperror.ErrInsufficientFunds)
+
+		_, err := service.AuthorizeHold(ctx, model.Hold{WalletId: "wallet-a", Amount: decimal.NewFromInt(40)})
+		require.ErrorIs(t, err, apperror.ErrInsufficientFunds)
+	})
+}
+
+func TestCreateSchedule(t *testing.T) {
+	t.Run("recurring starts at the next occurrence", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-a").Return(model.Wallet{Id: "wallet-a", Currency: "JPY", Status: model.WalletActive}, nil)
+		var stored model.Schedule
+		mockRepo.On("CreateSchedule", ctx, mock.AnythingOfType("model.Schedule")).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(model.Schedule)
+		}).Return(model.Schedule{Id: "schedule-1"}, nil)
+
+		result, err := service.CreateSchedule(ctx, model.Schedule{
+			WalletId:      "wallet-a",
+			OperationType: model.TransactionWithdraw,
+			Amount:        decimal.RequireFromString("999.4"),
+			Cron:          "0 9 1 * *",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "schedule-1", result.Id)
+		require.Equal(t, "JPY", stored.Currency)
+		require.Equal(t, "999", stored.Amount.String())
+		require.NotNil(t, stored.NextRunAt)
+		require.Equal(t, 1, stored.NextRunAt.Day())
+		require.Equal(t, 9, stored.NextRunAt.Hour())
+		require.True(t, stored.NextRunAt.After(time.Now()))
+	})
+
+	t.Run("currency mismatch", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-a").Return(model.Wallet{Id: "wallet-a", Currency: "USD", Status: model.WalletActive}, nil)
+
+		_, err := service.CreateSchedule(ctx, model.Schedule{WalletId: "wallet-a", OperationType: model.TransactionDeposit, Amount: decimal.NewFromInt(1), Currency: "EUR", Cron: "@daily"})
+		require.ErrorIs(t, err, apperror.ErrCurrencyMismatch)
+		mockRepo.AssertNotCalled(t, "CreateSchedule", mock.Anything, mock.Anything)
+	})
+
+	t.Run("closed wallet", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		mockRepo.On("GetWalletByUuid", ctx, "wallet-a").Return(model.Wallet{Id: "wallet-a", Currency: "USD", Status: model.WalletClosed}, nil)
+
+		_, err := service.CreateSchedule(ctx, model.Schedule{WalletId: "wallet-a", OperationType: model.TransactionDeposit, Amount: decimal.NewFromInt(1), Cron: "@daily"})
+		require.ErrorIs(t, err, apperror.ErrWalletClosed)
+	})
+}
+
+func TestReconcile(t *testing.T) {
+	t.Run("logs every mismatch", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&logs, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		run := model.ReconciliationRun{Id: 7, WalletsChecked: 3, MismatchCount: 1, Mismatches: []model.BalanceMismatch{{
+			WalletId:      "wallet-a",
+			Currency:      "USD",
+			StoredBalance: decimal.NewFromInt(110),
+			LedgerBalance: decimal.NewFromInt(100),
+			Difference:    decimal.NewFromInt(10),
+		}}}
+		mockRepo.On("Reconcile", ctx, false).Return(run, nil)
+
+		got, err := service.Reconcile(ctx, false)
+		require.NoError(t, err)
+		require.Equal(t, run, got)
+		require.Contains(t, logs.String(), `"msg":"balance mismatch","run_id":7,"wallet_id":"wallet-a","currency":"USD","stored_balance":"110","ledger_balance":"100","difference":"10","fixed":false`)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mockRepo := new(mockRepository)
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+		service := NewService(mockRepo, logger)
+		ctx := context.Background()
+
+		mockRepo.On("Reconcile", ctx, true).Return(model.ReconciliationRun{}, errors.New("database error"))
+
+		_, err := service.Reconcile(ctx, true)
+		require.Error(t, err)
 	})
 }
+
+func TestTrialBalance(t *testing.T) {
+	mockRepo := new(mockRepository)
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	service := NewService(mockRepo, logger)
+	ctx := context.Background()
+
+	balance := model.TrialBalance{Currencies: []model.CurrencyBalance{
+		{Currency: "EUR", Total: decimal.Zero},
+		{Currency: "USD", Total: decimal.NewFromInt(5)},
+	}}
+	mockRepo.On("TrialBalance", ctx).Return(balance, nil)
+
+	got, err := service.TrialBalance(ctx)
+	require.NoError(t, err)
+	require.Equal(t, balance, got)
+	require.Contains(t, logs.String(), `"msg":"trial balance does not net to zero","currency":"USD","total":"5"`)
+	require.NotContains(t, logs.String(), `"currency":"EUR"`)
+}
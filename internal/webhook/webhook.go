@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+	"wallet-service/internal/model"
+)
+
+// Headers sent with every delivery. The signature covers the timestamp and
+// the body, so receivers can reject replays of old deliveries.
+const (
+	HeaderId        = "Webhook-Id"
+	HeaderEvent     = "Webhook-Event"
+	HeaderTimestamp = "Webhook-Timestamp"
+	HeaderSignature = "Webhook-Signature"
+)
+
+// Sign returns the value of HeaderSignature for body sent at timestamp:
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" under secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid HeaderSignature for body.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// envelope is the body POSTed to subscribers.
+type envelope struct {
+	Id        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// ErrPrivateAddress is returned for a delivery whose URL resolves to an
+// address model.IsPublicAddress refuses.
+var ErrPrivateAddress = errors.New("webhook receiver is not at a public address")
+
+// Sender POSTs deliveries to subscriber URLs.
+type Sender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewSender returns a Sender that only connects to public addresses. The
+// check runs on the address a connection is actually made to, after DNS
+// resolution and on every redirect, so a host that resolves to an internal
+// address is refused however the subscription named it.
+func NewSender(timeout time.Duration) *Sender {
+	return newSender(timeout, false)
+}
+
+func newSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, address)
+			}
+			if !allowPrivate && !model.IsPublicAddress(addr.Addr()) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, addr.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the connection on the sender's behalf, out of reach
+	// of the address check.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Sender{
+		client: &http.Client{Timeout: timeout, Transport: transport},
+		now:    time.Now,
+	}
+}
+
+// Send delivers d and succeeds only on a 2xx response.
+func (s *Sender) Send(ctx context.Context, d model.WebhookDelivery) error {
+	body, err := json.Marshal(envelope{Id: d.Id, Type: d.EventType, CreatedAt: d.CreatedAt, Data: d.Payload})
+	if err != nil {
+		return fmt.Errorf("encode webhook: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	timestamp := s.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderId, d.Id)
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook receiver responded with %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"wallet-service/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	signature := Sign("secret", 1700000000, []byte(`{"id":"1"}`))
+	assert.Equal(t, "sha256=", signature[:7])
+	assert.Len(t, signature, 7+64)
+	assert.True(t, Verify("secret", 1700000000, []byte(`{"id":"1"}`), signature))
+	assert.False(t, Verify("secret", 1700000001, []byte(`{"id":"1"}`), signature))
+	assert.False(t, Verify("other", 1700000000, []byte(`{"id":"1"}`), signature))
+}
+
+func TestSenderSend(t *testing.T) {
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	delivery := model.WebhookDelivery{
+		Id:        "delivery-1",
+		EventType: model.WebhookDeposit,
+		Payload:   json.RawMessage(`{"id":"op-1","amount":"100"}`),
+		CreatedAt: createdAt,
+		Secret:    "secret",
+	}
+
+	t.Run("signed delivery", func(t *testing.T) {
+		var header http.Header
+		var body []byte
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer receiver.Close()
+
+		d := delivery
+		d.Url = receiver.URL
+		require.NoError(t, newSender(time.Second, true).Send(context.Background(), d))
+
+		assert.Equal(t, "delivery-1", header.Get(HeaderId))
+		assert.Equal(t, model.WebhookDeposit, header.Get(HeaderEvent))
+		timestamp, err := strconv.ParseInt(header.Get(HeaderTimestamp), 10, 64)
+		require.NoError(t, err)
+		assert.True(t, Verify("secret", timestamp, body, header.Get(HeaderSignature)))
+
+		var got envelope
+		require.NoError(t, json.Unmarshal(body, &got))
+		assert.Equal(t, "delivery-1", got.Id)
+		assert.Equal(t, createdAt, got.CreatedAt)
+		assert.JSONEq(t, `{"id":"op-1","amount":"100"}`, string(got.Data))
+	})
+
+	t.Run("receiver error", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer receiver.Close()
+
+		d := delivery
+		d.Url = receiver.URL
+		err := newSender(time.Second, true).Send(context.Background(), d)
+		assert.EqualError(t, err, "webhook receiver responded with 503")
+	})
+
+	t.Run("private receiver", func(t *testing.T) {
+		called := false
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+		defer receiver.Close()
+
+		// The receiver listens on loopback, as would a name resolving to it.
+		d := delivery
+		d.Url = strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)
+		err := NewSender(time.Second).Send(context.Background(), d)
+		assert.ErrorIs(t, err, ErrPrivateAddress)
+		assert.False(t, called)
+	})
+}
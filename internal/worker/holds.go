@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// holdSweepBatch bounds how many holds a single sweep releases in one
+// database transaction.
+const holdSweepBatch = 100
+
+type HoldExpirer interface {
+	ExpireHolds(ctx context.Context, now time.Time, limit int) (int, error)
+}
+
+// HoldSweeper periodically releases holds whose TTL has passed, returning the
+// reserved funds to the available balance of their wallets.
+type HoldSweeper struct {
+	holds    HoldExpirer
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+func NewHoldSweeper(holds HoldExpirer, interval time.Duration, logger *slog.Logger) *HoldSweeper {
+	return &HoldSweeper{
+		holds:    holds,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run sweeps once per interval until ctx is cancelled.
+func (s *HoldSweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Sweep(ctx)
+		}
+	}
+}
+
+// Sweep releases every hold that has expired by now, one batch at a time.
+func (s *HoldSweeper) Sweep(ctx context.Context) int {
+	total := 0
+	for ctx.Err() == nil {
+		n, err := s.holds.ExpireHolds(ctx, time.Now(), holdSweepBatch)
+		if err != nil {
+			s.logger.Error("failed to expire holds", slog.Any("error", err))
+			break
+		}
+		total += n
+		if n < holdSweepBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		s.logger.Info("expired holds", slog.Int("count", total))
+	}
+	return total
+}
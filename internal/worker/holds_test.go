@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubExpirer struct {
+	batches []int
+	err     error
+	calls   int
+}
+
+func (s *stubExpirer) ExpireHolds(ctx context.Context, now time.Time, limit int) (int, error) {
+	s.calls++
+	if s.err != nil {
+		return 0, s.err
+	}
+	if len(s.batches) == 0 {
+		return 0, nil
+	}
+	n := s.batches[0]
+	s.batches = s.batches[1:]
+	return n, nil
+}
+
+func TestHoldSweeperSweep(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("drains full batches", func(t *testing.T) {
+		expirer := &stubExpirer{batches: []int{holdSweepBatch, holdSweepBatch, 3}}
+		sweeper := NewHoldSweeper(expirer, time.Minute, logger)
+
+		n := sweeper.Sweep(context.Background())
+		require.Equal(t, 2*holdSweepBatch+3, n)
+		require.Equal(t, 3, expirer.calls)
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		expirer := &stubExpirer{err: errors.New("database error")}
+		sweeper := NewHoldSweeper(expirer, time.Minute, logger)
+
+		n := sweeper.Sweep(context.Background())
+		require.Zero(t, n)
+		require.Equal(t, 1, expirer.calls)
+	})
+}
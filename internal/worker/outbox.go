@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/publisher"
+)
+
+const (
+	// outboxBatch bounds how many events a single claim picks up.
+	outboxBatch = 100
+	// outboxLease is how long a claimed event stays hidden from other relays.
+	// It must comfortably exceed the time needed to publish a batch.
+	outboxLease = time.Minute
+
+	outboxMinBackoff = time.Second
+	outboxMaxBackoff = 5 * time.Minute
+)
+
+type EventStore interface {
+	ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]model.Event, error)
+	MarkEventPublished(ctx context.Context, id int64) error
+	RetryEvent(ctx context.Context, id int64, at time.Time, cause string) error
+}
+
+// OutboxRelay delivers the events written to the outbox through a Publisher.
+// Failed deliveries are retried with exponential backoff.
+type OutboxRelay struct {
+	events    EventStore
+	publisher publisher.Publisher
+	interval  time.Duration
+	logger    *slog.Logger
+}
+
+func NewOutboxRelay(events EventStore, publisher publisher.Publisher, interval time.Duration, logger *slog.Logger) *OutboxRelay {
+	return &OutboxRelay{
+		events:    events,
+		publisher: publisher,
+		interval:  interval,
+		logger:    logger,
+	}
+}
+
+// Run relays once per interval until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Relay(ctx)
+		}
+	}
+}
+
+// Relay publishes every event that is due, one batch at a time, and returns
+// how many were delivered.
+func (r *OutboxRelay) Relay(ctx context.Context) int {
+	total := 0
+	for ctx.Err() == nil {
+		events, err := r.events.ClaimEvents(ctx, outboxBatch, outboxLease)
+		if err != nil {
+			r.logger.Error("failed to claim events", slog.Any("error", err))
+			break
+		}
+		for _, event := range events {
+			if r.deliver(ctx, event) {
+				total++
+			}
+		}
+		if len(events) < outboxBatch {
+			break
+		}
+	}
+	return total
+}
+
+func (r *OutboxRelay) deliver(ctx context.Context, event model.Event) bool {
+	if err := r.publisher.Publish(ctx, event); err != nil {
+		retryAt := time.Now().Add(backoff(event.Attempts))
+		r.logger.Warn("failed to publish event",
+			slog.Int64("event", event.Id),
+			slog.Int("attempts", event.Attempts),
+			slog.Time("retryAt", retryAt),
+			slog.Any("error", err),
+		)
+		if err := r.events.RetryEvent(ctx, event.Id, retryAt, err.Error()); err != nil {
+			r.logger.Error("failed to reschedule event", slog.Int64("event", event.Id), slog.Any("error", err))
+		}
+		return false
+	}
+
+	// If this fails the lease runs out and the event is delivered again.
+	if err := r.events.MarkEventPublished(ctx, event.Id); err != nil {
+		r.logger.Error("failed to mark event published", slog.Int64("event", event.Id), slog.Any("error", err))
+	}
+	return true
+}
+
+// backoff is the delay before the next attempt after attempts failed ones:
+// outboxMinBackoff doubled for every earlier failure, up to outboxMaxBackoff.
+func backoff(attempts int) time.Duration {
+	delay := outboxMinBackoff
+	for i := 1; i < attempts && delay < outboxMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxMaxBackoff)
+}
@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+	"wallet-service/internal/model"
+	"wallet-service/internal/publisher"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubEventStore struct {
+	batches   [][]model.Event
+	published []int64
+	retried   map[int64]time.Time
+}
+
+func (s *stubEventStore) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]model.Event, error) {
+	if len(s.batches) == 0 {
+		return nil, nil
+	}
+	events := s.batches[0]
+	s.batches = s.batches[1:]
+	return events, nil
+}
+
+func (s *stubEventStore) MarkEventPublished(ctx context.Context, id int64) error {
+	s.published = append(s.published, id)
+	return nil
+}
+
+func (s *stubEventStore) RetryEvent(ctx context.Context, id int64, at time.Time, cause string) error {
+	if s.retried == nil {
+		s.retried = map[int64]time.Time{}
+	}
+	s.retried[id] = at
+	return nil
+}
+
+func TestOutboxRelay(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("publishes and marks events", func(t *testing.T) {
+		store := &stubEventStore{batches: [][]model.Event{{
+			{Id: 1, Type: model.EventBalanceChanged, WalletId: "wallet-a", Attempts: 1},
+			{Id: 2, Type: model.EventBalanceChanged, WalletId: "wallet-b", Attempts: 1},
+		}}}
+		events := publisher.NewMemoryPublisher()
+		relay := NewOutboxRelay(store, events, time.Second, logger)
+
+		n := relay.Relay(context.Background())
+		require.Equal(t, 2, n)
+		assert.Equal(t, []int64{1, 2}, store.published)
+		require.Len(t, events.Events(), 2)
+		assert.Equal(t, "wallet-b", events.Events()[1].WalletId)
+	})
+
+	t.Run("reschedules failed deliveries", func(t *testing.T) {
+		store := &stubEventStore{batches: [][]model.Event{{{Id: 3, Attempts: 3}}}}
+		events := publisher.NewMemoryPublisher()
+		events.FailWith(errors.New("broker unavailable"))
+		relay := NewOutboxRelay(store, events, time.Second, logger)
+
+		before := time.Now()
+		n := relay.Relay(context.Background())
+		require.Zero(t, n)
+		assert.Empty(t, store.published)
+		assert.WithinDuration(t, before.Add(4*time.Second), store.retried[3], time.Second)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 2*time.Second, backoff(2))
+	assert.Equal(t, 8*time.Second, backoff(4))
+	assert.Equal(t, outboxMaxBackoff, backoff(100))
+}
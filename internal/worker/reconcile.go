@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"wallet-service/internal/model"
+)
+
+type BalanceReconciler interface {
+	Reconcile(ctx context.Context, fix bool) (model.ReconciliationRun, error)
+}
+
+// ReconciliationJob periodically compares every wallet's stored balance with
+// its ledger. It only overwrites balances when built with fix set.
+type ReconciliationJob struct {
+	reconciler BalanceReconciler
+	interval   time.Duration
+	fix        bool
+	logger     *slog.Logger
+}
+
+func NewReconciliationJob(reconciler BalanceReconciler, interval time.Duration, fix bool, logger *slog.Logger) *ReconciliationJob {
+	return &ReconciliationJob{
+		reconciler: reconciler,
+		interval:   interval,
+		fix:        fix,
+		logger:     logger,
+	}
+}
+
+// Run reconciles once per interval until ctx is cancelled.
+func (j *ReconciliationJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.Reconcile(ctx)
+		}
+	}
+}
+
+// Reconcile runs a single reconciliation and returns the number of
+// mismatched wallets it found. The reconciler reports each of them.
+func (j *ReconciliationJob) Reconcile(ctx context.Context) int {
+	run, err := j.reconciler.Reconcile(ctx, j.fix)
+	if err != nil {
+		j.logger.Error("reconciliation failed", slog.Any("error", err))
+		return 0
+	}
+	return run.MismatchCount
+}
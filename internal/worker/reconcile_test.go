@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+	"wallet-service/internal/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+type stubReconciler struct {
+	run model.ReconciliationRun
+	err error
+	fix []bool
+}
+
+func (s *stubReconciler) Reconcile(ctx context.Context, fix bool) (model.ReconciliationRun, error) {
+	s.fix = append(s.fix, fix)
+	return s.run, s.err
+}
+
+func TestReconciliationJobReconcile(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("reports mismatches without fixing", func(t *testing.T) {
+		reconciler := &stubReconciler{run: model.ReconciliationRun{Id: 1, WalletsChecked: 10, MismatchCount: 2}}
+		job := NewReconciliationJob(reconciler, time.Hour, false, logger)
+
+		require.Equal(t, 2, job.Reconcile(context.Background()))
+		require.Equal(t, []bool{false}, reconciler.fix)
+	})
+
+	t.Run("fixes only when configured to", func(t *testing.T) {
+		reconciler := &stubReconciler{}
+		job := NewReconciliationJob(reconciler, time.Hour, true, logger)
+
+		job.Reconcile(context.Background())
+		require.Equal(t, []bool{true}, reconciler.fix)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		reconciler := &stubReconciler{err: errors.New("database error")}
+		job := NewReconciliationJob(reconciler, time.Hour, false, logger)
+
+		require.Zero(t, job.Reconcile(context.Background()))
+	})
+}
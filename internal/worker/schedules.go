@@ -0,0 +1,159 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+)
+
+const (
+	// scheduleBatch bounds how many schedules a single claim picks up.
+	scheduleBatch = 50
+	// scheduleLease must exceed the time needed to run a batch.
+	scheduleLease = 5 * time.Minute
+	// maxScheduleAttempts is how many times an occurrence is tried before it
+	// is given up on.
+	maxScheduleAttempts = 5
+)
+
+type ScheduleStore interface {
+	ClaimDueSchedules(ctx context.Context, limit int, lease time.Duration) ([]model.Schedule, error)
+	SkipScheduleRuns(ctx context.Context, id string, to time.Time) error
+	CompleteScheduleRun(ctx context.Context, id, operationId string, next *time.Time) error
+	RetryScheduleRun(ctx context.Context, id string, at time.Time, cause string) error
+	FailScheduleRun(ctx context.Context, id string, cause string, next *time.Time) error
+}
+
+type TransactionExecutor interface {
+	Transaction(ctx context.Context, transaction model.Transaction) (model.Operation, error)
+}
+
+// ScheduleRunner makes the operations of schedules that are due. A recurring
+// schedule that fell behind, e.g. while the service was down, runs only its
+// latest missed occurrence. A failed run is retried with exponential backoff;
+// once it runs out of attempts, a recurring schedule skips to its next
+// occurrence and a one-off one fails.
+type ScheduleRunner struct {
+	schedules ScheduleStore
+	executor  TransactionExecutor
+	interval  time.Duration
+	logger    *slog.Logger
+}
+
+func NewScheduleRunner(schedules ScheduleStore, executor TransactionExecutor, interval time.Duration, logger *slog.Logger) *ScheduleRunner {
+	return &ScheduleRunner{
+		schedules: schedules,
+		executor:  executor,
+		interval:  interval,
+		logger:    logger,
+	}
+}
+
+// Run executes due schedules once per interval until ctx is cancelled.
+func (r *ScheduleRunner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.RunDue(ctx)
+		}
+	}
+}
+
+// RunDue executes every schedule that is due, one batch at a time, and returns
+// how many runs went through.
+func (r *ScheduleRunner) RunDue(ctx context.Context) int {
+	total := 0
+	for ctx.Err() == nil {
+		schedules, err := r.schedules.ClaimDueSchedules(ctx, scheduleBatch, scheduleLease)
+		if err != nil {
+			r.logger.Error("failed to claim schedules", slog.Any("error", err))
+			break
+		}
+		for _, schedule := range schedules {
+			if r.run(ctx, schedule) {
+				total++
+			}
+		}
+		if len(schedules) < scheduleBatch {
+			break
+		}
+	}
+	return total
+}
+
+func (r *ScheduleRunner) run(ctx context.Context, schedule model.Schedule) bool {
+	// Catching up starts only on the first try at an occurrence: a retried
+	// one may already have gone through under its own idempotency key.
+	if schedule.Attempts <= 1 {
+		latest := schedule.LatestDue(time.Now())
+		if !latest.Equal(*schedule.NextRunAt) {
+			if err := r.schedules.SkipScheduleRuns(ctx, schedule.Id, *latest); err != nil {
+				r.logger.Error("failed to skip missed schedule runs", slog.String("schedule", schedule.Id), slog.Any("error", err))
+				return false
+			}
+			r.logger.Warn("skipped missed schedule runs",
+				slog.String("schedule", schedule.Id),
+				slog.Time("from", *schedule.NextRunAt),
+				slog.Time("to", *latest),
+			)
+			schedule.NextRunAt = latest
+		}
+	}
+
+	// The next occurrence follows the one being run, not the time it runs at,
+	// so a late run does not shift the schedule.
+	next := schedule.Next(*schedule.NextRunAt)
+
+	op, err := r.executor.Transaction(ctx, schedule.Transaction())
+	if err == nil {
+		if err := r.schedules.CompleteScheduleRun(ctx, schedule.Id, op.Id, next); err != nil {
+			r.logger.Error("failed to complete schedule run", slog.String("schedule", schedule.Id), slog.Any("error", err))
+		}
+		return true
+	}
+
+	if permanent(err) || schedule.Attempts >= maxScheduleAttempts {
+		if permanent(err) {
+			next = nil
+		}
+		r.logger.Warn("schedule run failed",
+			slog.String("schedule", schedule.Id),
+			slog.Int("attempts", schedule.Attempts),
+			slog.Bool("final", next == nil),
+			slog.Any("error", err),
+		)
+		if err := r.schedules.FailScheduleRun(ctx, schedule.Id, err.Error(), next); err != nil {
+			r.logger.Error("failed to record schedule failure", slog.String("schedule", schedule.Id), slog.Any("error", err))
+		}
+		return false
+	}
+
+	retryAt := time.Now().Add(backoff(schedule.Attempts))
+	r.logger.Warn("failed to run schedule",
+		slog.String("schedule", schedule.Id),
+		slog.Int("attempts", schedule.Attempts),
+		slog.Time("retryAt", retryAt),
+		slog.Any("error", err),
+	)
+	if err := r.schedules.RetryScheduleRun(ctx, schedule.Id, retryAt, err.Error()); err != nil {
+		r.logger.Error("failed to reschedule schedule run", slog.String("schedule", schedule.Id), slog.Any("error", err))
+	}
+	return false
+}
+
+// permanent reports whether err means the schedule can never run again, so
+// retrying it or moving on to the next occurrence is pointless.
+func permanent(err error) bool {
+	switch apperror.CodeOf(err) {
+	case apperror.CodeWalletNotFound, apperror.CodeWalletClosed, apperror.CodeCurrencyMismatch, apperror.CodeValidation:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+	"wallet-service/internal/apperror"
+	"wallet-service/internal/model"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubScheduleStore struct {
+	batches   [][]model.Schedule
+	skipped   map[string]time.Time
+	completed map[string]*time.Time
+	retried   map[string]time.Time
+	failed    map[string]*time.Time
+}
+
+func (s *stubScheduleStore) ClaimDueSchedules(ctx context.Context, limit int, lease time.Duration) ([]model.Schedule, error) {
+	if len(s.batches) == 0 {
+		return nil, nil
+	}
+	schedules := s.batches[0]
+	s.batches = s.batches[1:]
+	return schedules, nil
+}
+
+func (s *stubScheduleStore) SkipScheduleRuns(ctx context.Context, id string, to time.Time) error {
+	s.skipped[id] = to
+	return nil
+}
+
+func (s *stubScheduleStore) CompleteScheduleRun(ctx context.Context, id, operationId string, next *time.Time) error {
+	s.completed[id] = next
+	return nil
+}
+
+func (s *stubScheduleStore) RetryScheduleRun(ctx context.Context, id string, at time.Time, cause string) error {
+	s.retried[id] = at
+	return nil
+}
+
+func (s *stubScheduleStore) FailScheduleRun(ctx context.Context, id string, cause string, next *time.Time) error {
+	s.failed[id] = next
+	return nil
+}
+
+type stubExecutor struct {
+	errs map[string]error
+	keys []string
+}
+
+func (e *stubExecutor) Transaction(ctx context.Context, transaction model.Transaction) (model.Operation, error) {
+	e.keys = append(e.keys, transaction.IdempotencyKey)
+	if err := e.errs[transaction.Uuid]; err != nil {
+		return model.Operation{}, err
+	}
+	return model.Operation{Id: "op-" + transaction.Uuid}, nil
+}
+
+func TestScheduleRunner(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	due := time.Date(2036, 11, 1, 9, 0, 0, 0, time.UTC)
+	nextMonth := time.Date(2036, 12, 1, 9, 0, 0, 0, time.UTC)
+	schedule := func(id, cron string, attempts int) model.Schedule {
+		return model.Schedule{
+			Id:            id,
+			WalletId:      id,
+			OperationType: model.TransactionWithdraw,
+			Amount:        decimal.RequireFromString("9.99"),
+			Cron:          cron,
+			NextRunAt:     &due,
+			Attempts:      attempts,
+		}
+	}
+
+	store := &stubScheduleStore{
+		batches: [][]model.Schedule{{
+			schedule("monthly", "0 9 1 * *", 1),
+			schedule("once", "", 1),
+			schedule("retry", "0 9 1 * *", 2),
+			schedule("skip", "0 9 1 * *", maxScheduleAttempts),
+			schedule("give-up", "", maxScheduleAttempts),
+			schedule("closed", "0 9 1 * *", 1),
+		}},
+		skipped:   map[string]time.Time{},
+		completed: map[string]*time.Time{},
+		retried:   map[string]time.Time{},
+		failed:    map[string]*time.Time{},
+	}
+	executor := &stubExecutor{errs: map[string]error{
+		"retry":   apperror.ErrInsufficientFunds,
+		"skip":    apperror.ErrInsufficientFunds,
+		"give-up": errors.New("database error"),
+		"closed":  apperror.ErrWalletClosed,
+	}}
+	runner := NewScheduleRunner(store, executor, time.Second, logger)
+
+	before := time.Now()
+	n := runner.RunDue(context.Background())
+	require.Equal(t, 2, n)
+
+	assert.Equal(t, "schedule/monthly/2036-11-01T09:00:00Z", executor.keys[0])
+	require.Contains(t, store.completed, "monthly")
+	assert.Equal(t, nextMonth, *store.completed["monthly"])
+	require.Contains(t, store.completed, "once")
+	assert.Nil(t, store.completed["once"])
+
+	assert.WithinDuration(t, before.Add(2*time.Second), store.retried["retry"], time.Second)
+
+	require.Contains(t, store.failed, "skip")
+	assert.Equal(t, nextMonth, *store.failed["skip"])
+	require.Contains(t, store.failed, "give-up")
+	assert.Nil(t, store.failed["give-up"])
+	require.Contains(t, store.failed, "closed")
+	assert.Nil(t, store.failed["closed"])
+}
+
+func TestScheduleRunnerCatchUp(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	missed := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	now := time.Now().UTC()
+	latest := time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, time.UTC)
+	if latest.After(now) {
+		latest = latest.AddDate(0, 0, -1)
+	}
+	schedule := func(id string, attempts int) model.Schedule {
+		return model.Schedule{
+			Id:            id,
+			WalletId:      id,
+			OperationType: model.TransactionDeposit,
+			Amount:        decimal.RequireFromString("5"),
+			Cron:          "0 9 * * *",
+			NextRunAt:     &missed,
+			Attempts:      attempts,
+		}
+	}
+
+	store := &stubScheduleStore{
+		batches:   [][]model.Schedule{{schedule("behind", 1), schedule("retried", 2)}},
+		skipped:   map[string]time.Time{},
+		completed: map[string]*time.Time{},
+		retried:   map[string]time.Time{},
+		failed:    map[string]*time.Time{},
+	}
+	executor := &stubExecutor{}
+	runner := NewScheduleRunner(store, executor, time.Second, logger)
+
+	require.Equal(t, 2, runner.RunDue(context.Background()))
+
+	// Only the latest missed occurrence runs, and the schedule moves on from it.
+	assert.Equal(t, latest, store.skipped["behind"])
+	assert.Equal(t, "schedule/behind/"+latest.Format(time.RFC3339), executor.keys[0])
+	assert.Equal(t, latest.AddDate(0, 0, 1), *store.completed["behind"])
+
+	// A retried occurrence is finished first, as it may already have gone through.
+	assert.NotContains(t, store.skipped, "retried")
+	assert.Equal(t, "schedule/retried/2025-01-01T09:00:00Z", executor.keys[1])
+}
@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"wallet-service/internal/model"
+)
+
+const (
+	// webhookBatch bounds how many deliveries a single claim picks up.
+	webhookBatch = 50
+	// webhookLease must exceed the time needed to send a batch, including
+	// receiver timeouts.
+	webhookLease = 10 * time.Minute
+	// maxWebhookAttempts is how many times a delivery is tried before it is
+	// dead-lettered.
+	maxWebhookAttempts = 10
+)
+
+type WebhookStore interface {
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	MarkWebhookDelivered(ctx context.Context, id string) error
+	RetryWebhookDelivery(ctx context.Context, id string, at time.Time, cause string) error
+	DeadLetterWebhookDelivery(ctx context.Context, id string, cause string) error
+}
+
+type WebhookSender interface {
+	Send(ctx context.Context, delivery model.WebhookDelivery) error
+}
+
+// WebhookDispatcher sends queued webhook deliveries, retrying failures with
+// exponential backoff and dead-lettering those that keep failing.
+type WebhookDispatcher struct {
+	deliveries WebhookStore
+	sender     WebhookSender
+	interval   time.Duration
+	logger     *slog.Logger
+}
+
+func NewWebhookDispatcher(deliveries WebhookStore, sender WebhookSender, interval time.Duration, logger *slog.Logger) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		deliveries: deliveries,
+		sender:     sender,
+		interval:   interval,
+		logger:     logger,
+	}
+}
+
+// Run dispatches once per interval until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.Dispatch(ctx)
+		}
+	}
+}
+
+// Dispatch sends every delivery that is due, one batch at a time, and returns
+// how many were accepted by their receivers.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context) int {
+	total := 0
+	for ctx.Err() == nil {
+		deliveries, err := d.deliveries.ClaimWebhookDeliveries(ctx, webhookBatch, webhookLease)
+		if err != nil {
+			d.logger.Error("failed to claim webhook deliveries", slog.Any("error", err))
+			break
+		}
+		for _, delivery := range deliveries {
+			if d.send(ctx, delivery) {
+				total++
+			}
+		}
+		if len(deliveries) < webhookBatch {
+			break
+		}
+	}
+	return total
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, delivery model.WebhookDelivery) bool {
+	err := d.sender.Send(ctx, delivery)
+	if err == nil {
+		if err := d.deliveries.MarkWebhookDelivered(ctx, delivery.Id); err != nil {
+			d.logger.Error("failed to mark webhook delivered", slog.String("delivery", delivery.Id), slog.Any("error", err))
+		}
+		return true
+	}
+
+	if delivery.Attempts >= maxWebhookAttempts {
+		d.logger.Warn("webhook delivery dead-lettered",
+			slog.String("delivery", delivery.Id),
+			slog.Int("attempts", delivery.Attempts),
+			slog.Any("error", err),
+		)
+		if err := d.deliveries.DeadLetterWebhookDelivery(ctx, delivery.Id, err.Error()); err != nil {
+			d.logger.Error("failed to dead-letter webhook delivery", slog.String("delivery", delivery.Id), slog.Any("error", err))
+		}
+		return false
+	}
+
+	retryAt := time.Now().Add(backoff(delivery.Attempts))
+	d.logger.Warn("failed to send webhook",
+		slog.String("delivery", delivery.Id),
+		slog.Int("attempts", delivery.Attempts),
+		slog.Time("retryAt", retryAt),
+		slog.Any("error", err),
+	)
+	if err := d.deliveries.RetryWebhookDelivery(ctx, delivery.Id, retryAt, err.Error()); err != nil {
+		d.logger.Error("failed to reschedule webhook delivery", slog.String("delivery", delivery.Id), slog.Any("error", err))
+	}
+	return false
+}
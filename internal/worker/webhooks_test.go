@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+	"wallet-service/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubWebhookStore struct {
+	batches   [][]model.WebhookDelivery
+	delivered []string
+	retried   map[string]time.Time
+	dead      []string
+}
+
+func (s *stubWebhookStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	if len(s.batches) == 0 {
+		return nil, nil
+	}
+	deliveries := s.batches[0]
+	s.batches = s.batches[1:]
+	return deliveries, nil
+}
+
+func (s *stubWebhookStore) MarkWebhookDelivered(ctx context.Context, id string) error {
+	s.delivered = append(s.delivered, id)
+	return nil
+}
+
+func (s *stubWebhookStore) RetryWebhookDelivery(ctx context.Context, id string, at time.Time, cause string) error {
+	if s.retried == nil {
+		s.retried = map[string]time.Time{}
+	}
+	s.retried[id] = at
+	return nil
+}
+
+func (s *stubWebhookStore) DeadLetterWebhookDelivery(ctx context.Context, id string, cause string) error {
+	s.dead = append(s.dead, id)
+	return nil
+}
+
+// stubWebhookSender fails deliveries to URLs ending in /fail.
+type stubWebhookSender struct {
+	received []string
+}
+
+func (s *stubWebhookSender) Send(ctx context.Context, delivery model.WebhookDelivery) error {
+	s.received = append(s.received, delivery.Id)
+	if strings.HasSuffix(delivery.Url, "/fail") {
+		return errors.New("webhook receiver responded with 500")
+	}
+	return nil
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store := &stubWebhookStore{batches: [][]model.WebhookDelivery{{
+		{Id: "ok", Url: "https://partner.example/ok", Secret: "s", Attempts: 1},
+		{Id: "retry", Url: "https://partner.example/fail", Secret: "s", Attempts: 2},
+		{Id: "dead", Url: "https://partner.example/fail", Secret: "s", Attempts: maxWebhookAttempts},
+	}}}
+	sender := &stubWebhookSender{}
+	dispatcher := NewWebhookDispatcher(store, sender, time.Second, logger)
+
+	before := time.Now()
+	n := dispatcher.Dispatch(context.Background())
+	require.Equal(t, 1, n)
+
+	assert.Equal(t, []string{"ok", "retry", "dead"}, sender.received)
+	assert.Equal(t, []string{"ok"}, store.delivered)
+	assert.WithinDuration(t, before.Add(2*time.Second), store.retried["retry"], time.Second)
+	assert.Equal(t, []string{"dead"}, store.dead)
+}
@@ -0,0 +1,3 @@
+DROP TRIGGER operations_append_only ON operations;
+DROP FUNCTION operations_append_only;
+DROP TABLE operations;
@@ -0,0 +1,26 @@
+CREATE TABLE operations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    operation_type VARCHAR(16) NOT NULL,
+    amount DECIMAL NOT NULL,
+    balance_after DECIMAL NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT positive_amount CHECK (amount > 0)
+);
+
+CREATE INDEX operations_wallet_id_created_at_idx ON operations (wallet_id, created_at);
+
+-- Wallets funded before the ledger existed open it with a deposit of their
+-- balance, so that their balance can be rebuilt from operations.
+INSERT INTO operations (wallet_id, operation_type, amount, balance_after)
+SELECT id, 'DEPOSIT', balance, balance FROM wallets WHERE balance > 0;
+
+CREATE FUNCTION operations_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'operations ledger is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER operations_append_only
+    BEFORE UPDATE OR DELETE ON operations
+    FOR EACH ROW EXECUTE FUNCTION operations_append_only();
@@ -0,0 +1,5 @@
+DROP INDEX operations_idempotency_key_idx;
+
+ALTER TABLE operations
+    DROP COLUMN request_hash,
+    DROP COLUMN idempotency_key;
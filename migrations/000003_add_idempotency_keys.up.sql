@@ -0,0 +1,5 @@
+ALTER TABLE operations
+    ADD COLUMN idempotency_key VARCHAR(255),
+    ADD COLUMN request_hash CHAR(64);
+
+CREATE UNIQUE INDEX operations_idempotency_key_idx ON operations (idempotency_key) WHERE idempotency_key IS NOT NULL;
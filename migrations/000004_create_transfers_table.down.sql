@@ -0,0 +1,3 @@
+ALTER TABLE operations DROP COLUMN transfer_id;
+
+DROP TABLE transfers;
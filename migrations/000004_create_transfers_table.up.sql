@@ -0,0 +1,15 @@
+CREATE TABLE transfers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    from_wallet_id UUID NOT NULL REFERENCES wallets (id),
+    to_wallet_id UUID NOT NULL REFERENCES wallets (id),
+    amount DECIMAL NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT positive_amount CHECK (amount > 0),
+    CONSTRAINT distinct_wallets CHECK (from_wallet_id <> to_wallet_id)
+);
+
+CREATE TRIGGER transfers_append_only
+    BEFORE UPDATE OR DELETE ON transfers
+    FOR EACH ROW EXECUTE FUNCTION operations_append_only();
+
+ALTER TABLE operations ADD COLUMN transfer_id UUID REFERENCES transfers (id);
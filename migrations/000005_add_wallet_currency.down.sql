@@ -0,0 +1,3 @@
+ALTER TABLE operations DROP COLUMN currency;
+
+ALTER TABLE wallets DROP COLUMN currency;
@@ -0,0 +1,10 @@
+ALTER TABLE wallets ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE wallets ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE operations DISABLE TRIGGER operations_append_only;
+
+ALTER TABLE operations ADD COLUMN currency CHAR(3);
+UPDATE operations o SET currency = w.currency FROM wallets w WHERE w.id = o.wallet_id;
+ALTER TABLE operations ALTER COLUMN currency SET NOT NULL;
+
+ALTER TABLE operations ENABLE TRIGGER operations_append_only;
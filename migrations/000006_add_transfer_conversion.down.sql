@@ -0,0 +1,6 @@
+ALTER TABLE transfers
+    DROP COLUMN rate_at,
+    DROP COLUMN rate,
+    DROP COLUMN to_currency,
+    DROP COLUMN converted_amount,
+    DROP COLUMN from_currency;
@@ -0,0 +1,26 @@
+ALTER TABLE transfers
+    ADD COLUMN from_currency CHAR(3),
+    ADD COLUMN converted_amount DECIMAL,
+    ADD COLUMN to_currency CHAR(3),
+    ADD COLUMN rate DECIMAL,
+    ADD COLUMN rate_at TIMESTAMPTZ;
+
+ALTER TABLE transfers DISABLE TRIGGER transfers_append_only;
+
+UPDATE transfers t
+SET from_currency = f.currency,
+    converted_amount = t.amount,
+    to_currency = d.currency,
+    rate = 1
+FROM wallets f, wallets d
+WHERE f.id = t.from_wallet_id AND d.id = t.to_wallet_id;
+
+ALTER TABLE transfers ENABLE TRIGGER transfers_append_only;
+
+ALTER TABLE transfers
+    ALTER COLUMN from_currency SET NOT NULL,
+    ALTER COLUMN converted_amount SET NOT NULL,
+    ALTER COLUMN to_currency SET NOT NULL,
+    ALTER COLUMN rate SET NOT NULL,
+    ADD CONSTRAINT positive_converted_amount CHECK (converted_amount > 0),
+    ADD CONSTRAINT positive_rate CHECK (rate > 0);
@@ -0,0 +1,3 @@
+DROP TABLE holds;
+
+ALTER TABLE wallets DROP COLUMN held;
@@ -0,0 +1,22 @@
+ALTER TABLE wallets
+    ADD COLUMN held DECIMAL NOT NULL DEFAULT 0,
+    ADD CONSTRAINT held_within_balance CHECK (held >= 0 AND held <= balance);
+
+CREATE TABLE holds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    amount DECIMAL NOT NULL,
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'ACTIVE',
+    captured_amount DECIMAL NOT NULL DEFAULT 0,
+    operation_id UUID REFERENCES operations (id),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT positive_amount CHECK (amount > 0),
+    CONSTRAINT captured_within_amount CHECK (captured_amount >= 0 AND captured_amount <= amount),
+    CONSTRAINT valid_status CHECK (status IN ('ACTIVE', 'CAPTURED', 'VOIDED', 'EXPIRED'))
+);
+
+CREATE INDEX holds_wallet_id_idx ON holds (wallet_id);
+CREATE INDEX holds_active_expires_at_idx ON holds (expires_at) WHERE status = 'ACTIVE';
@@ -0,0 +1,3 @@
+ALTER TABLE operations ADD COLUMN reference_id UUID REFERENCES operations (id);
+
+CREATE INDEX operations_reference_id_idx ON operations (reference_id) WHERE reference_id IS NOT NULL;
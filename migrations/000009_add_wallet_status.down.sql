@@ -0,0 +1,5 @@
+DROP TABLE wallet_status_changes;
+
+ALTER TABLE wallets
+    DROP COLUMN allow_deposits,
+    DROP COLUMN status;
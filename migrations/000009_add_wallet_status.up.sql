@@ -0,0 +1,20 @@
+ALTER TABLE wallets
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'ACTIVE',
+    ADD COLUMN allow_deposits BOOLEAN NOT NULL DEFAULT false,
+    ADD CONSTRAINT valid_status CHECK (status IN ('ACTIVE', 'FROZEN', 'CLOSED'));
+
+CREATE TABLE wallet_status_changes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    from_status VARCHAR(16) NOT NULL,
+    to_status VARCHAR(16) NOT NULL,
+    reason TEXT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX wallet_status_changes_wallet_id_idx ON wallet_status_changes (wallet_id, created_at);
+
+CREATE TRIGGER wallet_status_changes_append_only
+    BEFORE UPDATE OR DELETE ON wallet_status_changes
+    FOR EACH ROW EXECUTE FUNCTION operations_append_only();
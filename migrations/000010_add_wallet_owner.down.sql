@@ -0,0 +1,4 @@
+ALTER TABLE wallets
+    DROP COLUMN metadata,
+    DROP COLUMN label,
+    DROP COLUMN owner_id;
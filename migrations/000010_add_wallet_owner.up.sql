@@ -0,0 +1,6 @@
+ALTER TABLE wallets
+    ADD COLUMN owner_id VARCHAR(255),
+    ADD COLUMN label VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX wallets_owner_id_idx ON wallets (owner_id) WHERE owner_id IS NOT NULL;
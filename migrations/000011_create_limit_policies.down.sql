@@ -0,0 +1,5 @@
+DROP INDEX operations_wallet_id_type_created_at_idx;
+
+ALTER TABLE wallets DROP COLUMN limit_policy;
+
+DROP TABLE limit_policies;
@@ -0,0 +1,15 @@
+CREATE TABLE limit_policies (
+    name VARCHAR(64) PRIMARY KEY,
+    currency CHAR(3) NOT NULL,
+    max_balance DECIMAL,
+    max_single_amount DECIMAL,
+    daily_deposit DECIMAL,
+    daily_withdrawal DECIMAL,
+    monthly_deposit DECIMAL,
+    monthly_withdrawal DECIMAL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE wallets ADD COLUMN limit_policy VARCHAR(64) REFERENCES limit_policies (name);
+
+CREATE INDEX operations_wallet_id_type_created_at_idx ON operations (wallet_id, operation_type, created_at);
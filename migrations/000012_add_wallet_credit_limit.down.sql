@@ -0,0 +1,9 @@
+ALTER TABLE wallets
+    DROP CONSTRAINT held_within_balance,
+    ADD CONSTRAINT held_within_balance CHECK (held >= 0 AND held <= balance);
+
+ALTER TABLE wallets
+    DROP CONSTRAINT balance_within_credit_limit,
+    ADD CONSTRAINT non_negative_balance CHECK (balance >= 0);
+
+ALTER TABLE wallets DROP COLUMN credit_limit;
@@ -0,0 +1,11 @@
+ALTER TABLE wallets
+    ADD COLUMN credit_limit DECIMAL NOT NULL DEFAULT 0,
+    ADD CONSTRAINT non_negative_credit_limit CHECK (credit_limit >= 0);
+
+ALTER TABLE wallets
+    DROP CONSTRAINT non_negative_balance,
+    ADD CONSTRAINT balance_within_credit_limit CHECK (balance >= -credit_limit);
+
+ALTER TABLE wallets
+    DROP CONSTRAINT held_within_balance,
+    ADD CONSTRAINT held_within_balance CHECK (held >= 0 AND held <= balance + credit_limit);
@@ -0,0 +1,13 @@
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT,
+    published_at TIMESTAMPTZ
+);
+
+CREATE INDEX outbox_events_pending_idx ON outbox_events (next_attempt_at, id) WHERE published_at IS NULL;
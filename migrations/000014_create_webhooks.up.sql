@@ -0,0 +1,27 @@
+CREATE TABLE webhook_subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    url TEXT NOT NULL,
+    event_types TEXT[] NOT NULL,
+    secret TEXT NOT NULL,
+    low_balance_threshold DECIMAL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id UUID NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_type VARCHAR(64) NOT NULL,
+    operation_id UUID NOT NULL REFERENCES operations (id),
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'PENDING',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMPTZ,
+    CONSTRAINT valid_status CHECK (status IN ('PENDING', 'DELIVERED', 'DEAD')),
+    -- Replaying an idempotent request must not notify twice.
+    CONSTRAINT one_delivery_per_event UNIQUE (subscription_id, event_type, operation_id)
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'PENDING';
@@ -0,0 +1,2 @@
+DROP TABLE reconciliation_mismatches;
+DROP TABLE reconciliation_runs;
@@ -0,0 +1,18 @@
+CREATE TABLE reconciliation_runs (
+    id BIGSERIAL PRIMARY KEY,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    wallets_checked INTEGER NOT NULL,
+    mismatches INTEGER NOT NULL,
+    fix BOOLEAN NOT NULL
+);
+
+CREATE TABLE reconciliation_mismatches (
+    run_id BIGINT NOT NULL REFERENCES reconciliation_runs (id) ON DELETE CASCADE,
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    currency VARCHAR(3) NOT NULL,
+    stored_balance DECIMAL NOT NULL,
+    ledger_balance DECIMAL NOT NULL,
+    fixed BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (run_id, wallet_id)
+);
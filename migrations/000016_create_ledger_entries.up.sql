@@ -0,0 +1,78 @@
+-- Every operation is also booked as a posting: entries against wallets and
+-- system accounts that sum to zero in each currency. Money enters through
+-- CASH_IN, leaves through CASH_OUT and changes currency through FX, so the
+-- whole ledger nets to zero as well.
+CREATE TABLE ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    posting_id UUID NOT NULL,
+    operation_id UUID NOT NULL REFERENCES operations (id),
+    wallet_id UUID REFERENCES wallets (id),
+    system_account VARCHAR(16),
+    currency CHAR(3) NOT NULL,
+    amount DECIMAL NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT one_account CHECK ((wallet_id IS NULL) <> (system_account IS NULL)),
+    CONSTRAINT valid_system_account CHECK (system_account IN ('CASH_IN', 'CASH_OUT', 'FEES', 'FX')),
+    CONSTRAINT non_zero_amount CHECK (amount <> 0)
+);
+
+CREATE INDEX ledger_entries_posting_id_idx ON ledger_entries (posting_id);
+CREATE INDEX ledger_entries_wallet_id_idx ON ledger_entries (wallet_id) WHERE wallet_id IS NOT NULL;
+
+CREATE TRIGGER ledger_entries_append_only
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION operations_append_only();
+
+-- Book the operations written so far. A posting is the operation itself, or
+-- the transfer for both of its legs; a refund goes back through the system
+-- account of the operation it undoes, and a transfer between currencies
+-- passes through FX while one within a currency moves straight between its
+-- wallets.
+INSERT INTO ledger_entries (posting_id, operation_id, wallet_id, currency, amount, created_at)
+SELECT COALESCE(o.transfer_id, o.id), o.id, o.wallet_id, o.currency,
+    CASE
+        WHEN o.operation_type IN ('DEPOSIT', 'TRANSFER_IN') THEN o.amount
+        WHEN o.operation_type = 'REFUND' AND r.operation_type = 'WITHDRAW' THEN o.amount
+        ELSE -o.amount
+    END,
+    o.created_at
+FROM operations o LEFT JOIN operations r ON r.id = o.reference_id;
+
+INSERT INTO ledger_entries (posting_id, operation_id, system_account, currency, amount, created_at)
+SELECT e.posting_id, e.operation_id,
+    CASE
+        WHEN o.operation_type IN ('TRANSFER_OUT', 'TRANSFER_IN') THEN 'FX'
+        WHEN o.operation_type = 'DEPOSIT' OR r.operation_type = 'DEPOSIT' THEN 'CASH_IN'
+        ELSE 'CASH_OUT'
+    END,
+    e.currency, -e.amount, e.created_at
+FROM ledger_entries e
+JOIN operations o ON o.id = e.operation_id
+LEFT JOIN operations r ON r.id = o.reference_id
+LEFT JOIN transfers t ON t.id = o.transfer_id
+WHERE t.id IS NULL OR t.from_currency <> t.to_currency;
+
+CREATE FUNCTION ledger_posting_balanced() RETURNS trigger AS $$
+DECLARE
+    unbalanced RECORD;
+BEGIN
+    SELECT currency, SUM(amount) AS total INTO unbalanced
+    FROM ledger_entries
+    WHERE posting_id = NEW.posting_id
+    GROUP BY currency
+    HAVING SUM(amount) <> 0
+    LIMIT 1;
+
+    IF FOUND THEN
+        RAISE EXCEPTION 'posting % does not balance in %: %', NEW.posting_id, unbalanced.currency, unbalanced.total
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Deferred to commit, so a posting is checked once all of its entries are in.
+CREATE CONSTRAINT TRIGGER ledger_posting_balanced
+    AFTER INSERT ON ledger_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION ledger_posting_balanced();
@@ -0,0 +1,15 @@
+-- Fees charged on withdrawals and transfers, per operation type and currency.
+CREATE TABLE fee_rules (
+    operation_type VARCHAR(16) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    flat DECIMAL NOT NULL DEFAULT 0,
+    percent DECIMAL NOT NULL DEFAULT 0,
+    min_fee DECIMAL,
+    max_fee DECIMAL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (operation_type, currency),
+    CONSTRAINT valid_operation_type CHECK (operation_type IN ('WITHDRAW', 'TRANSFER')),
+    CONSTRAINT valid_percent CHECK (percent >= 0 AND percent <= 100),
+    CONSTRAINT non_negative_fee CHECK (flat >= 0 AND min_fee >= 0 AND max_fee >= 0),
+    CONSTRAINT min_within_max CHECK (min_fee <= max_fee)
+);
@@ -0,0 +1,29 @@
+-- Deposits and withdrawals made later, once or on a recurring cron schedule.
+CREATE TABLE schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    operation_type VARCHAR(16) NOT NULL,
+    amount DECIMAL NOT NULL,
+    currency CHAR(3) NOT NULL,
+    cron VARCHAR(128),
+    status VARCHAR(16) NOT NULL DEFAULT 'ACTIVE',
+    next_run_at TIMESTAMPTZ,
+    -- When the runner may pick the schedule up: next_run_at, pushed back by
+    -- a claim's lease or a retry.
+    next_attempt_at TIMESTAMPTZ,
+    runs INTEGER NOT NULL DEFAULT 0,
+    failures INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    last_run_at TIMESTAMPTZ,
+    last_operation_id UUID REFERENCES operations (id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT positive_amount CHECK (amount > 0),
+    CONSTRAINT valid_operation_type CHECK (operation_type IN ('DEPOSIT', 'WITHDRAW')),
+    CONSTRAINT valid_status CHECK (status IN ('ACTIVE', 'COMPLETED', 'FAILED', 'CANCELLED')),
+    CONSTRAINT active_has_next_run CHECK (status <> 'ACTIVE' OR (next_run_at IS NOT NULL AND next_attempt_at IS NOT NULL))
+);
+
+CREATE INDEX schedules_wallet_id_idx ON schedules (wallet_id);
+CREATE INDEX schedules_due_idx ON schedules (next_attempt_at) WHERE status = 'ACTIVE';
@@ -0,0 +1,3 @@
+DROP TRIGGER wallets_bump_version ON wallets;
+DROP FUNCTION wallets_bump_version();
+ALTER TABLE wallets DROP COLUMN version;
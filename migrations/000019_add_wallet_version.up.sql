@@ -0,0 +1,14 @@
+-- Every change to a wallet bumps its version, so clients can make an operation
+-- conditional on the wallet being as they last saw it.
+ALTER TABLE wallets ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+CREATE FUNCTION wallets_bump_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallets_bump_version
+    BEFORE UPDATE ON wallets
+    FOR EACH ROW EXECUTE FUNCTION wallets_bump_version();
@@ -0,0 +1,7 @@
+-- A threshold only means something in the currency it was set in; wallets in
+-- other currencies never trigger it. Thresholds set so far were meant for the
+-- default currency.
+ALTER TABLE webhook_subscriptions ADD COLUMN low_balance_currency CHAR(3);
+UPDATE webhook_subscriptions SET low_balance_currency = 'USD' WHERE low_balance_threshold IS NOT NULL;
+ALTER TABLE webhook_subscriptions
+    ADD CONSTRAINT low_balance_threshold_currency CHECK ((low_balance_threshold IS NULL) = (low_balance_currency IS NULL));
@@ -0,0 +1 @@
+ALTER TABLE operations ALTER COLUMN created_at SET DEFAULT now();
@@ -0,0 +1,5 @@
+-- now() is the start of the transaction, so an operation and its fee line
+-- shared a timestamp and a long transaction could stamp its operations before
+-- ones that committed ahead of it. Operations are inserted under the lock on
+-- their wallet, so the time of the insert orders them as they were applied.
+ALTER TABLE operations ALTER COLUMN created_at SET DEFAULT clock_timestamp();
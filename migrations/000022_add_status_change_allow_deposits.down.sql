@@ -0,0 +1 @@
+ALTER TABLE wallet_status_changes DROP COLUMN allow_deposits;
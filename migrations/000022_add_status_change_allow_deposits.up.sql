@@ -0,0 +1,3 @@
+-- A frozen wallet can be frozen again to change whether it accepts deposits,
+-- so the audit trail records the flag along with the status.
+ALTER TABLE wallet_status_changes ADD COLUMN allow_deposits BOOLEAN NOT NULL DEFAULT false;